@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/mattn/go-isatty"
@@ -13,17 +14,50 @@ import (
 	"github.com/agrski/greg/pkg/auth"
 	fetchTypes "github.com/agrski/greg/pkg/fetch/types"
 	"github.com/agrski/greg/pkg/match"
+	"github.com/agrski/greg/pkg/present/console"
+	"github.com/agrski/greg/pkg/present/tabular"
 	"github.com/agrski/greg/pkg/types"
 )
 
 type VerbosityLevel int
 
+const (
+	// grepColoursVariable is read for compatibility with grep, before greg's own settings.
+	grepColoursVariable = "GREP_COLORS"
+	coloursVariable     = "GREG_COLORS"
+	coloursFileName     = "colours"
+)
+
 const (
 	VerbosityQuiet VerbosityLevel = iota
 	VerbosityNormal
 	VerbosityHigh
 )
 
+type OutputFormat string
+
+const (
+	FormatConsole     OutputFormat = "console"
+	FormatJSONL       OutputFormat = "jsonl"
+	FormatSARIF       OutputFormat = "sarif"
+	FormatCSV         OutputFormat = "csv"
+	FormatTSV         OutputFormat = "tsv"
+	FormatHTML        OutputFormat = "html"
+	FormatVimgrep     OutputFormat = "vimgrep"
+	FormatInteractive OutputFormat = "interactive"
+)
+
+var supportedFormats = [...]OutputFormat{
+	FormatConsole,
+	FormatJSONL,
+	FormatSARIF,
+	FormatCSV,
+	FormatTSV,
+	FormatHTML,
+	FormatVimgrep,
+	FormatInteractive,
+}
+
 const (
 	httpScheme = "https"
 	githubHost = "github.com"
@@ -31,6 +65,20 @@ const (
 
 var supportedHosts = [...]fetchTypes.HostName{githubHost}
 
+// listFlag collects the values of a flag which may be given several times.
+type listFlag []string
+
+var _ flag.Value = (*listFlag)(nil)
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 type rawArgs struct {
 	// Application behaviour
 	host            string
@@ -38,25 +86,81 @@ type rawArgs struct {
 	repo            string
 	url             string
 	filetypes       string
+	filetypeAdds    listFlag
+	includePaths    string
+	excludePaths    string
 	searchPattern   string
 	accessToken     string
 	accessTokenFile string
 	caseInsensitive bool
+	contextAfter    int
+	contextBefore   int
+	contextAround   int
+	maxCount        int
+	maxResults      int
+	multiline       bool
+	query           bool
+	fuzzyDistance   int
+	scope           string
+	structural      bool
+	goSymbols       bool
+	secrets         bool
+	secretRulesFile string
+	archives        bool
+	binary          bool
+	detectEncoding  bool
 	// Presentation/display behaviour
-	quiet    bool
-	verbose  bool
-	colour   bool
-	noColour bool
+	quiet        bool
+	verbose      bool
+	colour       bool
+	noColour     bool
+	coloursFile  string
+	showSecrets  bool
+	format       string
+	ruleID       string
+	columns      string
+	noHeader     bool
+	permalinks   bool
+	noHyperlinks bool
+	localRoot    string
+	stats        bool
 }
 
 type Args struct {
-	location        fetchTypes.Location
-	searchPattern   string
-	filetypes       []types.FileExtension
-	tokenSource     oauth2.TokenSource
-	caseInsensitive bool
-	verbosity       VerbosityLevel
-	enableColour    bool
+	location            fetchTypes.Location
+	searchPattern       string
+	filetypes           []types.FileExtension
+	fileTypeDefinitions match.FileTypes
+	pathFilter          *match.PathFilter
+	tokenSource         oauth2.TokenSource
+	caseInsensitive     bool
+	contextBefore       uint
+	contextAfter        uint
+	maxCount            uint
+	maxResults          uint
+	multiline           bool
+	query               bool
+	fuzzyDistance       uint
+	scope               match.Scope
+	structural          bool
+	goSymbols           bool
+	secrets             bool
+	secretRules         []*match.SecretRule
+	archives            bool
+	binary              bool
+	detectEncoding      bool
+	verbosity           VerbosityLevel
+	enableColour        bool
+	theme               console.Theme
+	redactSecrets       bool
+	format              OutputFormat
+	ruleID              string
+	columns             []tabular.Column
+	header              bool
+	permalinks          bool
+	hyperlinks          bool
+	localRoot           string
+	stats               bool
 }
 
 func GetArgs() (*Args, error) {
@@ -75,7 +179,46 @@ func GetArgs() (*Args, error) {
 		return nil, err
 	}
 
-	pattern, err := getSearchPattern(raw.searchPattern)
+	err = checkMatchingModes(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	pattern := ""
+	if !raw.secrets {
+		pattern, err = getSearchPattern(raw.searchPattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if raw.multiline {
+		pattern = unescapeLineBreaks(pattern)
+	}
+	if raw.query {
+		if _, err := match.ParseQuery(pattern); err != nil {
+			return nil, err
+		}
+	} else if raw.structural {
+		if _, err := match.ParseStructuralPattern(pattern); err != nil {
+			return nil, err
+		}
+	} else if raw.goSymbols {
+		if _, err := match.ParseGoQuery(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	fuzzyDistance, err := getFuzzyDistance(raw.fuzzyDistance, pattern, raw.query)
+	if err != nil {
+		return nil, err
+	}
+
+	scope, err := match.ParseScope(raw.scope)
+	if err != nil {
+		return nil, err
+	}
+
+	secretRules, err := getSecretRules(raw.secretRulesFile)
 	if err != nil {
 		return nil, err
 	}
@@ -87,18 +230,91 @@ func GetArgs() (*Args, error) {
 
 	filetypes := getFiletypes(raw.filetypes)
 
+	fileTypeDefinitions, err := getFileTypeDefinitions(raw.filetypeAdds)
+	if err != nil {
+		return nil, err
+	}
+
+	pathFilter, err := getPathFilter(raw.includePaths, raw.excludePaths)
+	if err != nil {
+		return nil, err
+	}
+
+	contextBefore, contextAfter, err := getContextLines(raw.contextBefore, raw.contextAfter, raw.contextAround)
+	if err != nil {
+		return nil, err
+	}
+
+	maxCount, maxResults, err := getMaxCounts(raw.maxCount, raw.maxResults)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := getFormat(raw.format)
+	if err != nil {
+		return nil, err
+	}
+	if !isEmpty(raw.ruleID) && format != FormatSARIF {
+		return nil, errors.New("rule ID can only be used with SARIF output")
+	}
+
+	columns, err := getColumns(raw.columns, raw.noHeader, format)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isEmpty(raw.localRoot) && format != FormatVimgrep {
+		return nil, errors.New("local root can only be used with vimgrep output")
+	}
+
+	if format == FormatInteractive && !(isTerminal(os.Stdin) && isTerminal(os.Stdout)) {
+		return nil, errors.New("interactive output needs a terminal for both input and output")
+	}
+
 	verbosity := getVerbosity(raw.quiet, raw.verbose)
 
 	enableColour := getColourEnabled(raw.colour, raw.noColour)
 
+	theme, err := getTheme(raw.coloursFile)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Args{
-		location:        location,
-		searchPattern:   pattern,
-		filetypes:       filetypes,
-		tokenSource:     tokenSource,
-		caseInsensitive: raw.caseInsensitive,
-		verbosity:       verbosity,
-		enableColour:    enableColour,
+		location:            location,
+		searchPattern:       pattern,
+		filetypes:           filetypes,
+		fileTypeDefinitions: fileTypeDefinitions,
+		pathFilter:          pathFilter,
+		tokenSource:         tokenSource,
+		caseInsensitive:     raw.caseInsensitive,
+		contextBefore:       contextBefore,
+		contextAfter:        contextAfter,
+		maxCount:            maxCount,
+		maxResults:          maxResults,
+		multiline:           raw.multiline,
+		query:               raw.query,
+		fuzzyDistance:       fuzzyDistance,
+		scope:               scope,
+		structural:          raw.structural,
+		goSymbols:           raw.goSymbols,
+		secrets:             raw.secrets,
+		secretRules:         secretRules,
+		archives:            raw.archives,
+		binary:              raw.binary,
+		detectEncoding:      raw.detectEncoding,
+		verbosity:           verbosity,
+		enableColour:        enableColour,
+		theme:               theme,
+		redactSecrets:       !raw.showSecrets,
+		format:              format,
+		ruleID:              strings.TrimSpace(raw.ruleID),
+		columns:             columns,
+		header:              !raw.noHeader,
+		permalinks:          raw.permalinks,
+		hyperlinks:          enableColour && !raw.noHyperlinks,
+		localRoot:           strings.TrimSpace(raw.localRoot),
+		stats:               raw.stats,
 	}, nil
 }
 
@@ -114,7 +330,47 @@ func parseArguments() (*rawArgs, error) {
 		"",
 		"Full URL of git repository, e.g https://github.com/agrski/gitfind",
 	)
-	flag.StringVar(&args.filetypes, "type", "", "filetype suffix, e.g. md or go")
+	flag.StringVar(
+		&args.filetypes,
+		"type",
+		"",
+		"comma-separated file types or suffixes, e.g. go,docker,shell or md",
+	)
+	flag.Var(
+		&args.filetypeAdds,
+		"type-add",
+		"define or extend a file type as name:glob[,glob...], e.g. jenkins:Jenkinsfile,*.groovy; may be repeated",
+	)
+	flag.StringVar(
+		&args.includePaths,
+		"include",
+		"",
+		"comma-separated path globs to search, e.g. cmd/** or *.go",
+	)
+	flag.StringVar(
+		&args.excludePaths,
+		"exclude",
+		"",
+		"comma-separated path globs to skip, e.g. vendor/,**/*_test.go; overrides include",
+	)
+	flag.BoolVar(
+		&args.archives,
+		"archives",
+		false,
+		"search inside compressed files and archives, e.g. .gz, .zst, .zip, .jar, and .tar files, whose files have paths like dist/src.zip!main.go",
+	)
+	flag.BoolVar(
+		&args.binary,
+		"binary",
+		false,
+		"also search the raw bytes of binary files, showing byte offsets and a hexdump of each match",
+	)
+	flag.BoolVar(
+		&args.detectEncoding,
+		"detect-encoding",
+		false,
+		"detect UTF-16, Latin-1, and Windows-1252 files and convert them to UTF-8 before matching",
+	)
 	flag.StringVar(&args.accessToken, "access-token", "", "raw access token for repository access")
 	flag.StringVar(
 		&args.accessTokenFile,
@@ -123,12 +379,124 @@ func parseArguments() (*rawArgs, error) {
 		"file containing access token for repository access",
 	)
 	flag.BoolVar(&args.caseInsensitive, "i", false, "enable case-insensitive matching")
+	flag.IntVar(&args.contextAfter, "A", 0, "lines of context to show after each match; overrides C; holds each file in memory")
+	flag.IntVar(&args.contextBefore, "B", 0, "lines of context to show before each match; overrides C; holds each file in memory")
+	flag.IntVar(&args.contextAround, "C", 0, "lines of context to show before and after each match; holds each file in memory")
+	flag.IntVar(&args.maxCount, "m", 0, "stop after this many matches in each file; 0 means no limit")
+	flag.IntVar(&args.maxCount, "max-count", 0, "stop after this many matches in each file; same as m")
+	flag.IntVar(
+		&args.maxResults,
+		"max-results",
+		0,
+		"stop searching after this many matches in total; 0 means no limit",
+	)
+	flag.BoolVar(
+		&args.multiline,
+		"multiline",
+		false,
+		"allow matches to span lines; \\n in the search term matches a line break",
+	)
+	flag.BoolVar(
+		&args.query,
+		"query",
+		false,
+		`treat the search term as a boolean query, e.g. "sql.Open" AND NOT ("defer db.Close" OR TODO)`,
+	)
+	flag.IntVar(
+		&args.fuzzyDistance,
+		"fuzzy",
+		0,
+		"match text within this many single-character edits of the search term",
+	)
+	flag.BoolVar(
+		&args.structural,
+		"structural",
+		false,
+		"match code structurally, with holes for balanced expressions, e.g. http.Get(:[url])",
+	)
+	flag.BoolVar(
+		&args.goSymbols,
+		"go-symbols",
+		false,
+		"match Go declarations, e.g. func:New*, type:*Reader, method:Close() error, import:database/sql, call:http.Get",
+	)
+	flag.BoolVar(
+		&args.secrets,
+		"secrets",
+		false,
+		"look for leaked credentials such as AWS keys, GitHub tokens, and private keys instead of a search term",
+	)
+	flag.StringVar(
+		&args.secretRulesFile,
+		"secret-rules",
+		"",
+		`JSON file of additional secret rules, e.g. [{"id": "slack", "regex": "xox[bp]-[0-9A-Za-z-]+"}]; replaces built-in rules with the same id`,
+	)
+	flag.StringVar(
+		&args.scope,
+		"scope",
+		"",
+		"only match within code, comments, or strings; skips files in unsupported languages",
+	)
 	flag.BoolVar(&args.quiet, "quiet", false, "disable logging; overrides verbose mode")
 	flag.BoolVar(&args.verbose, "verbose", false, "increase logging; overridden by quiet mode")
 	flag.BoolVar(&args.colour, "colour", false, "force coloured outputs; overridden by no-colour")
 	flag.BoolVar(&args.noColour, "no-colour", false, "force uncoloured outputs; overrides colour")
+	flag.StringVar(
+		&args.coloursFile,
+		"colours-file",
+		"",
+		"file of colours for console output in GREP_COLORS syntax, e.g. fn=bold blue:ms=#ff8800; default: greg/colours in the user config directory",
+	)
+	flag.BoolVar(&args.showSecrets, "show-secrets", false, "show secrets in full rather than redacting them")
+	flag.StringVar(
+		&args.format,
+		"format",
+		string(FormatConsole),
+		"output format, one of: console, jsonl (one JSON object per match), sarif (SARIF 2.1.0 document), csv, tsv, html (standalone report), vimgrep (path:line:column:text), interactive (browse results in the terminal)",
+	)
+	flag.StringVar(
+		&args.columns,
+		"columns",
+		"",
+		"comma-separated columns for CSV and TSV output, from: repository, path, line, column, match, text, permalink",
+	)
+	flag.BoolVar(&args.noHeader, "no-header", false, "omit the header row from CSV and TSV output")
+	flag.StringVar(
+		&args.localRoot,
+		"local-root",
+		"",
+		"local checkout of the repository to give paths within in vimgrep output",
+	)
+	flag.BoolVar(
+		&args.permalinks,
+		"permalinks",
+		false,
+		"show a link to each match as of the commit searched in console output",
+	)
+	flag.BoolVar(
+		&args.noHyperlinks,
+		"no-hyperlinks",
+		false,
+		"do not make paths and line numbers clickable in coloured console output",
+	)
+	flag.StringVar(&args.ruleID, "rule-id", "", "rule ID given to matches of the search term in SARIF output")
+	flag.BoolVar(
+		&args.stats,
+		"stats",
+		false,
+		"summarise the search, such as files fetched and API requests made; included in JSONL, SARIF, and HTML output, otherwise written to stderr",
+	)
 	flag.Parse()
 
+	if args.secrets {
+		if 0 != flag.NArg() {
+			return nil, fmt.Errorf("expected no search term when looking for secrets but found %d", flag.NArg())
+		}
+
+		return &args, nil
+	}
+
 	if 1 != flag.NArg() {
 		return nil, fmt.Errorf("expected one search term but found %d", flag.NArg())
 	}
@@ -212,6 +580,129 @@ func getFiletypes(filetypes string) []types.FileExtension {
 	return extensions
 }
 
+func getContextLines(before int, after int, around int) (uint, uint, error) {
+	if before < 0 || after < 0 || around < 0 {
+		return 0, 0, errors.New("lines of context cannot be negative")
+	}
+
+	if before == 0 {
+		before = around
+	}
+	if after == 0 {
+		after = around
+	}
+
+	return uint(before), uint(after), nil
+}
+
+func getMaxCounts(maxCount int, maxResults int) (uint, uint, error) {
+	if maxCount < 0 || maxResults < 0 {
+		return 0, 0, errors.New("maximum numbers of matches cannot be negative")
+	}
+
+	return uint(maxCount), uint(maxResults), nil
+}
+
+func getFileTypeDefinitions(definitions []string) (match.FileTypes, error) {
+	fileTypes := match.DefaultFileTypes()
+
+	for _, d := range definitions {
+		if err := fileTypes.Add(d); err != nil {
+			return nil, err
+		}
+	}
+
+	return fileTypes, nil
+}
+
+// checkMatchingModes ensures at most one alternative to exact matching has been chosen.
+func checkMatchingModes(args *rawArgs) error {
+	modes := []string{}
+	if args.multiline {
+		modes = append(modes, "multiline")
+	}
+	if args.fuzzyDistance != 0 {
+		modes = append(modes, "fuzzy")
+	}
+	if args.structural {
+		modes = append(modes, "structural")
+	}
+	if args.goSymbols {
+		modes = append(modes, "go-symbols")
+	}
+	if args.secrets {
+		modes = append(modes, "secrets")
+	}
+
+	if len(modes) > 1 {
+		return fmt.Errorf("only one matching mode may be used at a time but found %s", strings.Join(modes, ", "))
+	}
+
+	if args.secrets && args.query {
+		return errors.New("secrets cannot be looked for with a query")
+	}
+	if !args.secrets && !isEmpty(args.secretRulesFile) {
+		return errors.New("secret rules can only be used when looking for secrets")
+	}
+
+	return nil
+}
+
+func getFuzzyDistance(distance int, pattern string, query bool) (uint, error) {
+	if distance < 0 {
+		return 0, errors.New("fuzzy distance cannot be negative")
+	}
+	if distance == 0 {
+		return 0, nil
+	}
+
+	// Query terms are checked individually when matching
+	if !query {
+		if err := match.CheckFuzzyDistance(pattern, uint(distance)); err != nil {
+			return 0, err
+		}
+	}
+
+	return uint(distance), nil
+}
+
+// getSecretRules adds any rules from the given file to the built-in secret rules.
+func getSecretRules(rulesFile string) ([]*match.SecretRule, error) {
+	rules := match.DefaultSecretRules()
+	if isEmpty(rulesFile) {
+		return rules, nil
+	}
+
+	f, err := os.Open(rulesFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	custom, err := match.ParseSecretRules(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return match.MergeSecretRules(rules, custom), nil
+}
+
+func getPathFilter(includes string, excludes string) (*match.PathFilter, error) {
+	if isEmpty(includes) && isEmpty(excludes) {
+		return nil, nil
+	}
+
+	return match.NewPathFilter(splitList(includes), splitList(excludes))
+}
+
+func splitList(s string) []string {
+	if isEmpty(s) {
+		return nil
+	}
+
+	return strings.Split(s, ",")
+}
+
 func getSearchPattern(pattern string) (string, error) {
 	if isEmpty(pattern) {
 		return "", errors.New("search term must be specified; wrap multiple words in quotes")
@@ -220,6 +711,10 @@ func getSearchPattern(pattern string) (string, error) {
 	return pattern, nil
 }
 
+func unescapeLineBreaks(pattern string) string {
+	return strings.ReplaceAll(pattern, `\n`, "\n")
+}
+
 func isEmpty(s string) bool {
 	return "" == strings.TrimSpace(s)
 }
@@ -266,13 +761,76 @@ func getVerbosity(quiet bool, verbose bool) VerbosityLevel {
 	}
 }
 
+func getFormat(format string) (OutputFormat, error) {
+	normalised := OutputFormat(strings.ToLower(strings.TrimSpace(format)))
+	if normalised == "" {
+		return FormatConsole, nil
+	}
+
+	for _, f := range supportedFormats {
+		if normalised == f {
+			return f, nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported output format %s", format)
+}
+
+func getColumns(columns string, noHeader bool, format OutputFormat) ([]tabular.Column, error) {
+	isTabular := format == FormatCSV || format == FormatTSV
+	if !isTabular && (!isEmpty(columns) || noHeader) {
+		return nil, errors.New("columns and header can only be chosen for CSV and TSV output")
+	}
+
+	return tabular.ParseColumns(columns)
+}
+
+// getTheme applies GREP_COLORS, then the colours file, then GREG_COLORS to the default theme, so the most specific setting wins.
+// The colours file only needs to exist when given explicitly.
+func getTheme(coloursFile string) (console.Theme, error) {
+	theme, err := console.ParseTheme(console.DefaultTheme(), os.Getenv(grepColoursVariable))
+	if err != nil {
+		return console.Theme{}, fmt.Errorf("unable to parse %s: %w", grepColoursVariable, err)
+	}
+
+	path := strings.TrimSpace(coloursFile)
+	if path == "" {
+		if configDir, err := os.UserConfigDir(); err == nil {
+			path = filepath.Join(configDir, "greg", coloursFileName)
+		}
+	}
+	if path != "" {
+		spec, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			theme, err = console.ParseTheme(theme, string(spec))
+			if err != nil {
+				return console.Theme{}, fmt.Errorf("unable to parse colours file %s: %w", path, err)
+			}
+		case !errors.Is(err, os.ErrNotExist) || !isEmpty(coloursFile):
+			return console.Theme{}, err
+		}
+	}
+
+	theme, err = console.ParseTheme(theme, os.Getenv(coloursVariable))
+	if err != nil {
+		return console.Theme{}, fmt.Errorf("unable to parse %s: %w", coloursVariable, err)
+	}
+
+	return theme, nil
+}
+
 func getColourEnabled(forceColour bool, forceNoColour bool) bool {
 	if forceNoColour {
 		return false
 	} else if forceColour {
 		return true
 	} else {
-		fd := os.Stdout.Fd()
-		return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+		return isTerminal(os.Stdout)
 	}
 }
+
+func isTerminal(f *os.File) bool {
+	fd := f.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
@@ -3,11 +3,15 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
 	fetchTypes "github.com/agrski/greg/pkg/fetch/types"
+	"github.com/agrski/greg/pkg/present/console"
+	"github.com/agrski/greg/pkg/present/tabular"
 	"github.com/agrski/greg/pkg/types"
 )
 
@@ -56,6 +60,227 @@ func Test_getFiletypes(t *testing.T) {
 	}
 }
 
+func Test_getFileTypeDefinitions(t *testing.T) {
+	fileTypes, err := getFileTypeDefinitions([]string{"jenkins:Jenkinsfile", "jenkins:*.groovy", "go:go.mod"})
+	require.NoError(t, err)
+
+	require.True(t, fileTypes.Filter([]types.FileExtension{"jenkins"}, &types.FileInfo{Path: "Jenkinsfile"}))
+	require.True(t, fileTypes.Filter([]types.FileExtension{"jenkins"}, &types.FileInfo{Path: "ci/build.groovy"}))
+	require.True(t, fileTypes.Filter([]types.FileExtension{"go"}, &types.FileInfo{Path: "go.mod"}))
+	require.True(t, fileTypes.Filter([]types.FileExtension{"go"}, &types.FileInfo{Path: "main.go"}))
+
+	_, err = getFileTypeDefinitions([]string{"jenkins"})
+	require.Error(t, err)
+}
+
+func Test_checkMatchingModes(t *testing.T) {
+	type test struct {
+		name    string
+		args    *rawArgs
+		wantErr bool
+	}
+
+	tests := []test{
+		{
+			name:    "exact matching by default",
+			args:    &rawArgs{},
+			wantErr: false,
+		},
+		{
+			name:    "single mode",
+			args:    &rawArgs{structural: true},
+			wantErr: false,
+		},
+		{
+			name:    "modifiers are not modes",
+			args:    &rawArgs{multiline: true, query: true, caseInsensitive: true},
+			wantErr: false,
+		},
+		{
+			name:    "fuzzy and multi-line fails",
+			args:    &rawArgs{multiline: true, fuzzyDistance: 1},
+			wantErr: true,
+		},
+		{
+			name:    "structural and fuzzy fails",
+			args:    &rawArgs{structural: true, fuzzyDistance: 2},
+			wantErr: true,
+		},
+		{
+			name:    "Go symbols and structural fails",
+			args:    &rawArgs{goSymbols: true, structural: true},
+			wantErr: true,
+		},
+		{
+			name:    "secrets with context",
+			args:    &rawArgs{secrets: true, contextAround: 2, secretRulesFile: "rules.json"},
+			wantErr: false,
+		},
+		{
+			name:    "secrets and query fails",
+			args:    &rawArgs{secrets: true, query: true},
+			wantErr: true,
+		},
+		{
+			name:    "secret rules without secrets fails",
+			args:    &rawArgs{secretRulesFile: "rules.json"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				err := checkMatchingModes(tt.args)
+
+				if tt.wantErr {
+					require.Error(t, err)
+				} else {
+					require.NoError(t, err)
+				}
+			},
+		)
+	}
+}
+
+func Test_getFuzzyDistance(t *testing.T) {
+	type test struct {
+		name     string
+		distance int
+		pattern  string
+		query    bool
+		want     uint
+		wantErr  bool
+	}
+
+	tests := []test{
+		{
+			name:     "disabled by default",
+			distance: 0,
+			pattern:  "a",
+			want:     0,
+		},
+		{
+			name:     "distance shorter than pattern",
+			distance: 2,
+			pattern:  "receive",
+			want:     2,
+		},
+		{
+			name:     "negative distance fails",
+			distance: -1,
+			pattern:  "receive",
+			wantErr:  true,
+		},
+		{
+			name:     "distance as long as pattern fails",
+			distance: 3,
+			pattern:  "foo",
+			wantErr:  true,
+		},
+		{
+			name:     "query terms are not checked up front",
+			distance: 3,
+			pattern:  "foo OR bar",
+			query:    true,
+			want:     3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				actual, err := getFuzzyDistance(tt.distance, tt.pattern, tt.query)
+
+				if tt.wantErr {
+					require.Error(t, err)
+				} else {
+					require.NoError(t, err)
+				}
+				require.Equal(t, tt.want, actual)
+			},
+		)
+	}
+}
+
+func Test_getSecretRules(t *testing.T) {
+	builtin, err := getSecretRules("")
+	require.NoError(t, err)
+	require.NotEmpty(t, builtin)
+
+	dir := t.TempDir()
+	rulesFile := filepath.Join(dir, "rules.json")
+	err = os.WriteFile(
+		rulesFile,
+		[]byte(`[{"id": "slack", "regex": "xox[bp]-[0-9A-Za-z-]+"}, {"id": "private-key", "regex": "PRIVATE KEY"}]`),
+		0o600,
+	)
+	require.NoError(t, err)
+
+	custom, err := getSecretRules(rulesFile)
+	require.NoError(t, err)
+	require.Len(t, custom, len(builtin)+1)
+	require.Equal(t, "slack", custom[len(custom)-1].ID)
+
+	_, err = getSecretRules(filepath.Join(dir, "missing.json"))
+	require.Error(t, err)
+}
+
+func Test_getPathFilter(t *testing.T) {
+	type test struct {
+		name       string
+		includes   string
+		excludes   string
+		wantNil    bool
+		wantErr    bool
+		allowed    []string
+		disallowed []string
+	}
+
+	tests := []test{
+		{
+			name:    "no patterns gives no filter",
+			wantNil: true,
+		},
+		{
+			name:       "comma-separated patterns are all applied",
+			includes:   "cmd/**,pkg/**",
+			excludes:   "**/*_test.go,*.pb.go",
+			allowed:    []string{"cmd/cli/main.go", "pkg/match/match.go"},
+			disallowed: []string{"README.md", "cmd/cli/main_test.go", "pkg/api/api.pb.go"},
+		},
+		{
+			name:     "invalid pattern fails",
+			excludes: "vendor/,[",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				actual, err := getPathFilter(tt.includes, tt.excludes)
+
+				if tt.wantErr {
+					require.Error(t, err)
+					return
+				}
+
+				require.NoError(t, err)
+				if tt.wantNil {
+					require.Nil(t, actual)
+				}
+				for _, a := range tt.allowed {
+					require.True(t, actual.AllowsFile(a), a)
+				}
+				for _, d := range tt.disallowed {
+					require.False(t, actual.AllowsFile(d), d)
+				}
+			},
+		)
+	}
+}
+
 func Test_getLocation(t *testing.T) {
 	type test struct {
 		name    string
@@ -125,6 +350,277 @@ func Test_getLocation(t *testing.T) {
 	}
 }
 
+func Test_getContextLines(t *testing.T) {
+	type test struct {
+		name       string
+		before     int
+		after      int
+		around     int
+		wantBefore uint
+		wantAfter  uint
+		wantErr    bool
+	}
+
+	tests := []test{
+		{
+			name:       "no context by default",
+			wantBefore: 0,
+			wantAfter:  0,
+		},
+		{
+			name:       "around sets both before and after",
+			around:     2,
+			wantBefore: 2,
+			wantAfter:  2,
+		},
+		{
+			name:       "before and after override around",
+			before:     1,
+			after:      3,
+			around:     2,
+			wantBefore: 1,
+			wantAfter:  3,
+		},
+		{
+			name:       "before only overrides around on one side",
+			before:     1,
+			around:     2,
+			wantBefore: 1,
+			wantAfter:  2,
+		},
+		{
+			name:    "negative context fails",
+			after:   -1,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				before, after, err := getContextLines(tt.before, tt.after, tt.around)
+
+				if tt.wantErr {
+					require.Error(t, err)
+				} else {
+					require.NoError(t, err)
+				}
+				require.Equal(t, tt.wantBefore, before)
+				require.Equal(t, tt.wantAfter, after)
+			},
+		)
+	}
+}
+
+func Test_getMaxCounts(t *testing.T) {
+	type test struct {
+		name           string
+		maxCount       int
+		maxResults     int
+		wantMaxCount   uint
+		wantMaxResults uint
+		wantErr        bool
+	}
+
+	tests := []test{
+		{
+			name: "no limits by default",
+		},
+		{
+			name:           "limits are kept",
+			maxCount:       3,
+			maxResults:     10,
+			wantMaxCount:   3,
+			wantMaxResults: 10,
+		},
+		{
+			name:     "negative max count fails",
+			maxCount: -1,
+			wantErr:  true,
+		},
+		{
+			name:       "negative max results fails",
+			maxResults: -1,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				maxCount, maxResults, err := getMaxCounts(tt.maxCount, tt.maxResults)
+
+				if tt.wantErr {
+					require.Error(t, err)
+				} else {
+					require.NoError(t, err)
+				}
+				require.Equal(t, tt.wantMaxCount, maxCount)
+				require.Equal(t, tt.wantMaxResults, maxResults)
+			},
+		)
+	}
+}
+
+func Test_getFormat(t *testing.T) {
+	type test struct {
+		name    string
+		format  string
+		want    OutputFormat
+		wantErr bool
+	}
+
+	tests := []test{
+		{name: "empty means console", format: "", want: FormatConsole},
+		{name: "console", format: "console", want: FormatConsole},
+		{name: "JSON Lines with whitespace and capitals", format: " JSONL ", want: FormatJSONL},
+		{name: "SARIF", format: "sarif", want: FormatSARIF},
+		{name: "CSV", format: "csv", want: FormatCSV},
+		{name: "TSV", format: "tsv", want: FormatTSV},
+		{name: "HTML", format: "html", want: FormatHTML},
+		{name: "vimgrep", format: "vimgrep", want: FormatVimgrep},
+		{name: "interactive", format: "interactive", want: FormatInteractive},
+		{name: "unknown format fails", format: "xml", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				actual, err := getFormat(tt.format)
+
+				if tt.wantErr {
+					require.Error(t, err)
+				} else {
+					require.NoError(t, err)
+					require.Equal(t, tt.want, actual)
+				}
+			},
+		)
+	}
+}
+
+func Test_getColumns(t *testing.T) {
+	type test struct {
+		name     string
+		columns  string
+		noHeader bool
+		format   OutputFormat
+		want     []tabular.Column
+		wantErr  bool
+	}
+
+	tests := []test{
+		{name: "all columns by default", format: FormatCSV, want: tabular.DefaultColumns},
+		{
+			name:     "chosen columns without header",
+			columns:  "path,line",
+			noHeader: true,
+			format:   FormatTSV,
+			want:     []tabular.Column{tabular.ColumnPath, tabular.ColumnLine},
+		},
+		{name: "unknown column fails", columns: "author", format: FormatCSV, wantErr: true},
+		{name: "columns need tabular output", columns: "path", format: FormatConsole, wantErr: true},
+		{name: "no header needs tabular output", noHeader: true, format: FormatJSONL, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				actual, err := getColumns(tt.columns, tt.noHeader, tt.format)
+
+				if tt.wantErr {
+					require.Error(t, err)
+				} else {
+					require.NoError(t, err)
+					require.Equal(t, tt.want, actual)
+				}
+			},
+		)
+	}
+}
+
+func Test_getTheme(t *testing.T) {
+	// Keep any colours file of the user running the tests out of the way
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("HOME", configDir)
+
+	coloursFile := filepath.Join(t.TempDir(), "colours")
+	require.NoError(t, os.WriteFile(coloursFile, []byte("# paths\nfn=bold green\nln=33\n"), 0o600))
+
+	type test struct {
+		name        string
+		grepColours string
+		gregColours string
+		coloursFile string
+		want        func(*console.Theme)
+		wantErr     bool
+	}
+
+	tests := []test{
+		{name: "default", want: func(*console.Theme) {}},
+		{
+			name:        "GREP_COLORS",
+			grepColours: "ms=01;31:fn=35:ne",
+			want: func(theme *console.Theme) {
+				theme.Match = "01;31"
+				theme.Path = "35"
+			},
+		},
+		{
+			name:        "colours file overrides GREP_COLORS",
+			grepColours: "fn=35:ms=01;31",
+			coloursFile: coloursFile,
+			want: func(theme *console.Theme) {
+				theme.Path = "1;32"
+				theme.LineNumber = "33"
+				theme.Match = "01;31"
+			},
+		},
+		{
+			name:        "GREG_COLORS overrides colours file",
+			gregColours: "fn=:ln=colour208",
+			coloursFile: coloursFile,
+			want: func(theme *console.Theme) {
+				theme.Path = ""
+				theme.LineNumber = "38;5;208"
+			},
+		},
+		{name: "invalid GREG_COLORS fails", gregColours: "fn=sparkly", wantErr: true},
+		{name: "missing colours file fails", coloursFile: filepath.Join(configDir, "missing"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				t.Setenv(grepColoursVariable, tt.grepColours)
+				t.Setenv(coloursVariable, tt.gregColours)
+
+				actual, err := getTheme(tt.coloursFile)
+
+				if tt.wantErr {
+					require.Error(t, err)
+				} else {
+					require.NoError(t, err)
+					expected := console.DefaultTheme()
+					tt.want(&expected)
+					require.Equal(t, expected, actual)
+				}
+			},
+		)
+	}
+
+	// The colours file in the user config directory is used when none is given
+	require.NoError(t, os.MkdirAll(filepath.Join(configDir, "greg"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "greg", coloursFileName), []byte("fn=36"), 0o600))
+	t.Setenv(grepColoursVariable, "")
+	t.Setenv(coloursVariable, "")
+
+	actual, err := getTheme("")
+	require.NoError(t, err)
+	require.Equal(t, "36", actual.Path)
+}
+
 func Test_getSearchPattern(t *testing.T) {
 	type test struct {
 		name          string
@@ -164,6 +660,41 @@ func Test_getSearchPattern(t *testing.T) {
 	}
 }
 
+func Test_unescapeLineBreaks(t *testing.T) {
+	type test struct {
+		name    string
+		pattern string
+		want    string
+	}
+
+	tests := []test{
+		{
+			name:    "pattern without escapes is unchanged",
+			pattern: "foo bar",
+			want:    "foo bar",
+		},
+		{
+			name:    "escaped line break is replaced",
+			pattern: `foo(\n\tbar`,
+			want:    "foo(\n\\tbar",
+		},
+		{
+			name:    "literal line break is unchanged",
+			pattern: "foo\nbar",
+			want:    "foo\nbar",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				actual := unescapeLineBreaks(tt.pattern)
+				require.Equal(t, tt.want, actual)
+			},
+		)
+	}
+}
+
 func Test_isEmpty(t *testing.T) {
 	type test struct {
 		name  string
@@ -10,9 +10,19 @@ import (
 	"github.com/rs/zerolog"
 
 	"github.com/agrski/greg/pkg/fetch"
+	"github.com/agrski/greg/pkg/fetch/archive"
+	"github.com/agrski/greg/pkg/fetch/transcode"
 	fetchTypes "github.com/agrski/greg/pkg/fetch/types"
 	"github.com/agrski/greg/pkg/match"
+	"github.com/agrski/greg/pkg/present"
 	"github.com/agrski/greg/pkg/present/console"
+	"github.com/agrski/greg/pkg/present/html"
+	"github.com/agrski/greg/pkg/present/json"
+	"github.com/agrski/greg/pkg/present/sarif"
+	"github.com/agrski/greg/pkg/present/tabular"
+	"github.com/agrski/greg/pkg/present/tui"
+	"github.com/agrski/greg/pkg/present/vimgrep"
+	"github.com/agrski/greg/pkg/stats"
 )
 
 func main() {
@@ -33,11 +43,55 @@ func main() {
 		// Already at normal (info-level) verbosity
 	}
 
-	console := console.New(os.Stdout, args.enableColour)
+	// Logs while searching would draw over the interactive interface, which shows progress itself
+	pipelineLogger := logger
+	if args.format == FormatInteractive {
+		pipelineLogger = logger.Level(zerolog.Disabled)
+	}
+
+	// Statistics are always collected, as doing so is cheap, but only shown when asked for
+	runStats := stats.New()
 
-	matcher := match.New(logger, args.caseInsensitive, args.filetypes)
+	matcher := match.New(
+		pipelineLogger,
+		match.Config{
+			CaseInsensitive:     args.caseInsensitive,
+			AllowedFiletypes:    args.filetypes,
+			FileTypeDefinitions: args.fileTypeDefinitions,
+			PathFilter:          args.pathFilter,
+			ContextBefore:       args.contextBefore,
+			ContextAfter:        args.contextAfter,
+			MaxCount:            args.maxCount,
+			MaxResults:          args.maxResults,
+			Multiline:           args.multiline,
+			MaxDistance:         args.fuzzyDistance,
+			Structural:          args.structural,
+			GoSymbols:           args.goSymbols,
+			Secrets:             args.secrets,
+			SecretRules:         args.secretRules,
+			Binary:              args.binary,
+			Scope:               args.scope,
+			Query:               args.query,
+			KeepContents:        args.format == FormatInteractive,
+			Stats:               runStats,
+		},
+	)
 
-	fetcher := fetch.New(logger, args.location, args.tokenSource)
+	fetchOptions := fetchTypes.Options{
+		BinaryContents: args.archives || args.binary || args.detectEncoding,
+		Archives:       args.archives,
+		Stats:          runStats,
+	}
+	if args.pathFilter != nil {
+		fetchOptions.PathFilter = args.pathFilter
+	}
+	fetcher := fetch.New(pipelineLogger, args.location, args.tokenSource, fetchOptions)
+	if args.archives {
+		fetcher = archive.New(pipelineLogger, fetcher, runStats)
+	}
+	if args.detectEncoding {
+		fetcher = transcode.New(pipelineLogger, fetcher)
+	}
 	uri := makeURI(args.location)
 
 	logger.
@@ -51,14 +105,148 @@ func main() {
 		logger.Fatal().Err(err).Msg("unable to start fetching matches")
 	}
 
-	next, ok := fetcher.Next()
-	if ok {
+	revision := fetcher.Revision()
+	presenter := makePresenter(
+		args,
+		present.Source{
+			Location: args.location,
+			Ref:      revision.Ref,
+			Commit:   revision.Commit,
+		},
+	)
+
+	// Only the interactive interface can ask to stop early, so this is otherwise never ready
+	var quit <-chan struct{}
+	if t, ok := presenter.(*tui.TUI); ok {
+		if err := t.Start(); err != nil {
+			logger.Fatal().Err(err).Msg("unable to start interactive mode")
+		}
+		quit = t.Quit()
+	}
+
+loop:
+	for {
+		select {
+		case <-quit:
+			break loop
+		default:
+		}
+
+		next, ok := fetcher.Next()
+		if !ok {
+			break
+		}
+
 		if m, ok := matcher.Match(args.searchPattern, next); ok {
-			console.Write(next, m)
+			presenter.Write(next, m)
+		}
+
+		if matcher.Exhausted() {
+			pipelineLogger.Info().Uint("maxResults", args.maxResults).Msg("stopping after maximum results")
+			break
 		}
 	}
 
+	// Stopping early cancels any fetching still in progress
 	_ = fetcher.Stop()
+
+	summary := runStats.Summary()
+	summaryPresenter, includesSummary := presenter.(present.SummaryPresenter)
+	if args.stats && includesSummary {
+		summaryPresenter.WriteSummary(summary)
+	}
+
+	if err := presenter.Close(); err != nil {
+		logger.Fatal().Err(err).Msg("unable to write results")
+	}
+
+	// Other presenters may be using the terminal until closed, so the summary follows them
+	if args.stats && !includesSummary {
+		if err := present.WriteSummary(os.Stderr, summary); err != nil {
+			logger.Fatal().Err(err).Msg("unable to write summary")
+		}
+	}
+}
+
+func makePresenter(args *Args, source present.Source) present.Presenter {
+	switch args.format {
+	case FormatJSONL:
+		return json.New(
+			os.Stdout,
+			json.Config{
+				Source:        source,
+				Pattern:       args.searchPattern,
+				RedactSecrets: args.redactSecrets,
+			},
+		)
+	case FormatSARIF:
+		return sarif.New(
+			os.Stdout,
+			sarif.Config{
+				Source:        source,
+				Pattern:       args.searchPattern,
+				RuleID:        args.ruleID,
+				SecretRules:   args.secretRules,
+				RedactSecrets: args.redactSecrets,
+			},
+		)
+	case FormatCSV, FormatTSV:
+		separator := tabular.SeparatorCSV
+		if args.format == FormatTSV {
+			separator = tabular.SeparatorTSV
+		}
+
+		return tabular.New(
+			os.Stdout,
+			tabular.Config{
+				Source:        source,
+				Separator:     separator,
+				Columns:       args.columns,
+				Header:        args.header,
+				RedactSecrets: args.redactSecrets,
+			},
+		)
+	case FormatHTML:
+		return html.New(
+			os.Stdout,
+			html.Config{
+				Source:        source,
+				Pattern:       args.searchPattern,
+				RedactSecrets: args.redactSecrets,
+			},
+		)
+	case FormatVimgrep:
+		return vimgrep.New(
+			os.Stdout,
+			vimgrep.Config{
+				LocalRoot:     args.localRoot,
+				RedactSecrets: args.redactSecrets,
+			},
+		)
+	case FormatInteractive:
+		return tui.New(
+			os.Stdin,
+			os.Stdout,
+			tui.Config{
+				Source:        source,
+				RedactSecrets: args.redactSecrets,
+			},
+		)
+	case FormatConsole:
+		fallthrough
+	default:
+		return console.New(
+			os.Stdout,
+			console.Config{
+				EnableColour:  args.enableColour,
+				Theme:         &args.theme,
+				RedactSecrets: args.redactSecrets,
+				Source:        source,
+				Permalinks:    args.permalinks,
+				Hyperlinks:    args.hyperlinks,
+			},
+		)
+	}
 }
 
 func makeLogger(level zerolog.Level, enableColour bool) zerolog.Logger {
@@ -3,12 +3,17 @@ module github.com/agrski/greg
 go 1.20
 
 require (
+	github.com/bmatcuk/doublestar/v4 v4.6.0
 	github.com/daixiang0/gci v0.10.1
 	github.com/hasura/go-graphql-client v0.6.3
+	github.com/klauspost/compress v1.16.5
 	github.com/mattn/go-isatty v0.0.12
 	github.com/rs/zerolog v1.26.1
 	github.com/stretchr/testify v1.8.1
+	github.com/ulikunitz/xz v0.5.11
 	golang.org/x/oauth2 v0.0.0-20220223155221-ee480838109b
+	golang.org/x/sys v0.5.0
+	golang.org/x/text v0.9.0
 )
 
 require (
@@ -17,7 +22,6 @@ require (
 	github.com/google/uuid v1.3.0 // indirect
 	github.com/hexops/gotextdiff v1.0.3 // indirect
 	github.com/inconshreveable/mousetrap v1.0.1 // indirect
-	github.com/klauspost/compress v1.10.3 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/spf13/cobra v1.6.1 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
@@ -26,7 +30,6 @@ require (
 	go.uber.org/zap v1.24.0 // indirect
 	golang.org/x/net v0.0.0-20220127200216-cd36cc0744dd // indirect
 	golang.org/x/sync v0.1.0 // indirect
-	golang.org/x/sys v0.5.0 // indirect
 	google.golang.org/appengine v1.6.6 // indirect
 	google.golang.org/protobuf v1.25.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
@@ -0,0 +1,486 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/rs/zerolog"
+	"github.com/ulikunitz/xz"
+
+	fetchTypes "github.com/agrski/greg/pkg/fetch/types"
+	"github.com/agrski/greg/pkg/stats"
+	"github.com/agrski/greg/pkg/types"
+)
+
+const (
+	// maxDepth limits how deeply archives may be nested in one another.
+	maxDepth = 5
+	// sniffLength is how much of a file is read to recognise its format, enough for tar headers.
+	sniffLength = 512
+	// binarySniffLength is how much of a file is checked for NUL bytes, as git does.
+	binarySniffLength = 8000
+	// defaultMaxZipSize limits the size of zip files, which are read into memory whole as their index is at the end.
+	defaultMaxZipSize = 64 << 20
+	// defaultMaxExpandedSize limits how large any file may be once decompressed, including archives within compressed files,
+	// so a small but highly compressed file cannot exhaust memory.
+	defaultMaxExpandedSize = 128 << 20
+	// defaultMaxMembers limits how many files any one archive may hold.
+	defaultMaxMembers = 10_000
+)
+
+type format int
+
+const (
+	formatPlain format = iota
+	formatGzip
+	formatBzip2
+	formatXz
+	formatZstd
+	formatZip
+	formatTar
+)
+
+// decompressedExtensions replaces the extensions of compressed files with those of their contents.
+var decompressedExtensions = map[string]string{
+	".gz":   "",
+	".bz2":  "",
+	".xz":   "",
+	".zst":  "",
+	".tgz":  ".tar",
+	".tbz2": ".tar",
+	".txz":  ".tar",
+	".tzst": ".tar",
+}
+
+// Fetcher expands compressed files and archives from another fetcher into the files they contain.
+// Compressed files keep their path, while files in archives are named like outer.zip!inner/file.txt.
+// As the contents of archives are streamed, each file's Body can only be read until Next is called again.
+// Archives which are too large or hold too many files are recorded as failures, as are files too large once expanded,
+// whose Body fails when read past the limit.
+type Fetcher struct {
+	fetcher fetchTypes.Fetcher
+	members []*openArchive
+	limits  limits
+	stats   *stats.Stats
+	logger  zerolog.Logger
+}
+
+var _ fetchTypes.Fetcher = (*Fetcher)(nil)
+
+type limits struct {
+	zipSize      int64
+	expandedSize int64
+	members      int
+}
+
+// openArchive is an archive whose files are still being given out.
+type openArchive struct {
+	path     string
+	iterator iterator
+}
+
+func New(logger zerolog.Logger, fetcher fetchTypes.Fetcher, stats *stats.Stats) *Fetcher {
+	logger = logger.With().Str("source", "ArchiveFetcher").Logger()
+
+	return &Fetcher{
+		fetcher: fetcher,
+		limits: limits{
+			zipSize:      defaultMaxZipSize,
+			expandedSize: defaultMaxExpandedSize,
+			members:      defaultMaxMembers,
+		},
+		stats:  stats,
+		logger: logger,
+	}
+}
+
+func (f *Fetcher) Start() error {
+	return f.fetcher.Start()
+}
+
+func (f *Fetcher) Stop() error {
+	for _, a := range f.members {
+		_ = a.iterator.close()
+	}
+	f.members = nil
+
+	return f.fetcher.Stop()
+}
+
+func (f *Fetcher) Revision() fetchTypes.Revision {
+	return f.fetcher.Revision()
+}
+
+func (f *Fetcher) Next() (*types.FileInfo, bool) {
+	logger := f.logger.With().Str("func", "Next").Logger()
+
+	for {
+		next, depth, ok := f.nextCandidate()
+		if !ok {
+			return nil, false
+		}
+
+		expanded, err := f.expand(next, depth)
+		if err != nil {
+			logger.Warn().Err(err).Str("filename", next.Path).Msg("unable to expand file")
+			f.stats.RecordFailure(next.Path, err)
+			continue
+		}
+		if expanded {
+			continue
+		}
+
+		return next, true
+	}
+}
+
+// nextCandidate takes the next file from the innermost open archive, or else the wrapped fetcher.
+func (f *Fetcher) nextCandidate() (*types.FileInfo, int, bool) {
+	logger := f.logger.With().Str("func", "nextCandidate").Logger()
+
+	for len(f.members) > 0 {
+		depth := len(f.members)
+		a := f.members[depth-1]
+
+		next, err := a.iterator.next()
+		if err == nil {
+			// The wrapped fetcher counts the archive, so its members are counted here to be matched against
+			f.stats.RecordFetched()
+			return next, depth, true
+		}
+
+		if !errors.Is(err, io.EOF) {
+			logger.Warn().Err(err).Str("filename", a.path).Msg("unable to read archive")
+			f.stats.RecordFailure(a.path, err)
+		}
+		_ = a.iterator.close()
+		f.members = f.members[:depth-1]
+	}
+
+	next, ok := f.fetcher.Next()
+
+	return next, 0, ok
+}
+
+// expand opens a compressed file or archive so its contents are returned by later calls to Next.
+// Plain files are left to be returned as they are.
+func (f *Fetcher) expand(next *types.FileInfo, depth int) (bool, error) {
+	// Compressed files and archives are binary, and fetchers only provide their contents on request
+	if depth == 0 && (!next.IsBinary || next.Body == nil) {
+		return false, nil
+	}
+
+	body := bufio.NewReaderSize(next.Reader(), binarySniffLength)
+	next.Body = body
+
+	header, err := body.Peek(sniffLength)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return false, err
+	}
+
+	kind := sniffFormat(header)
+	if kind != formatPlain && depth >= maxDepth {
+		return false, fmt.Errorf("archives nested more than %d deep", maxDepth)
+	}
+
+	var it iterator
+	switch kind {
+	case formatPlain:
+		if depth > 0 {
+			next.IsBinary = isBinary(body)
+		}
+
+		return false, nil
+	case formatGzip, formatBzip2, formatXz, formatZstd:
+		it, err = decompress(next, kind, body, f.limits)
+	case formatZip:
+		it, err = newZipIterator(next.Path, body, f.limits)
+	case formatTar:
+		it = &tarIterator{container: next.Path, reader: tar.NewReader(body), limits: f.limits}
+	}
+	if err != nil {
+		return false, err
+	}
+
+	f.members = append(f.members, &openArchive{path: next.Path, iterator: it})
+
+	return true, nil
+}
+
+func sniffFormat(header []byte) format {
+	switch {
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return formatGzip
+	case bytes.HasPrefix(header, []byte("BZh")):
+		return formatBzip2
+	case bytes.HasPrefix(header, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}):
+		return formatXz
+	case bytes.HasPrefix(header, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return formatZstd
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return formatZip
+	case len(header) >= 262 && bytes.Equal(header[257:262], []byte("ustar")):
+		return formatTar
+	default:
+		return formatPlain
+	}
+}
+
+func isBinary(body *bufio.Reader) bool {
+	start, _ := body.Peek(binarySniffLength)
+	return bytes.IndexByte(start, 0) != -1
+}
+
+// decompress gives the single file held in a compressed one, named by dropping the compression extension.
+func decompress(compressed *types.FileInfo, kind format, body io.Reader, limits limits) (iterator, error) {
+	var reader io.Reader
+	var closer func() error
+
+	switch kind {
+	case formatGzip:
+		r, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		reader, closer = r, r.Close
+	case formatBzip2:
+		reader = bzip2.NewReader(body)
+	case formatXz:
+		r, err := xz.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = r
+	case formatZstd:
+		r, err := zstd.NewReader(body, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		reader = r
+		closer = func() error {
+			r.Close()
+			return nil
+		}
+	case formatPlain, formatZip, formatTar:
+		return nil, fmt.Errorf("format %d is not a compression format", kind)
+	}
+
+	decompressed := &types.FileInfo{
+		Path:      compressed.Path,
+		Extension: types.FileExtension(path.Ext(decompressedName(compressed.Path))),
+		Body:      limitExpanded(reader, limits),
+	}
+
+	return &singleIterator{file: decompressed, closer: closer}, nil
+}
+
+// decompressedName drops a compression extension, so data.json.gz becomes data.json and logs.tgz becomes logs.tar.
+func decompressedName(name string) string {
+	ext := path.Ext(name)
+	replacement, ok := decompressedExtensions[strings.ToLower(ext)]
+	if !ok {
+		return name
+	}
+
+	return strings.TrimSuffix(name, ext) + replacement
+}
+
+func memberPath(container string, member string) string {
+	return container + types.MemberSeparator + strings.TrimPrefix(member, "/")
+}
+
+// expandedLimitReader fails once more than a limited number of bytes have been read,
+// where io.LimitReader would stop silently, so oversized files are not mistaken for small ones.
+type expandedLimitReader struct {
+	reader    io.Reader
+	limit     int64
+	remaining int64
+	// err is kept once the limit is passed, as buffered readers may otherwise lose it.
+	err error
+}
+
+var _ io.Reader = (*expandedLimitReader)(nil)
+
+func limitExpanded(reader io.Reader, limits limits) *expandedLimitReader {
+	return &expandedLimitReader{reader: reader, limit: limits.expandedSize, remaining: limits.expandedSize}
+}
+
+func (l *expandedLimitReader) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+
+	if l.remaining <= 0 {
+		// Files of exactly the limit are allowed, so only fail if there is more to come
+		var probe [1]byte
+		n, err := l.reader.Read(probe[:])
+		if n > 0 {
+			l.err = fmt.Errorf("file is larger than the limit of %d bytes once expanded", l.limit)
+			return 0, l.err
+		}
+
+		return 0, err
+	}
+
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.reader.Read(p)
+	l.remaining -= int64(n)
+
+	return n, err
+}
+
+// iterator gives each file in an archive in turn, returning io.EOF once there are none left.
+type iterator interface {
+	next() (*types.FileInfo, error)
+	close() error
+}
+
+type singleIterator struct {
+	file   *types.FileInfo
+	closer func() error
+}
+
+var _ iterator = (*singleIterator)(nil)
+
+func (s *singleIterator) next() (*types.FileInfo, error) {
+	if s.file == nil {
+		return nil, io.EOF
+	}
+
+	file := s.file
+	s.file = nil
+
+	return file, nil
+}
+
+func (s *singleIterator) close() error {
+	if s.closer == nil {
+		return nil
+	}
+
+	return s.closer()
+}
+
+type tarIterator struct {
+	container string
+	reader    *tar.Reader
+	limits    limits
+	count     int
+}
+
+var _ iterator = (*tarIterator)(nil)
+
+func (t *tarIterator) next() (*types.FileInfo, error) {
+	for {
+		header, err := t.reader.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		t.count++
+		if t.count > t.limits.members {
+			return nil, fmt.Errorf("archive holds more than the limit of %d files", t.limits.members)
+		}
+
+		return &types.FileInfo{
+			Path:      memberPath(t.container, header.Name),
+			Extension: types.FileExtension(path.Ext(header.Name)),
+			Body:      limitExpanded(t.reader, t.limits),
+		}, nil
+	}
+}
+
+func (t *tarIterator) close() error {
+	return nil
+}
+
+type zipIterator struct {
+	container string
+	files     []*zip.File
+	current   io.Closer
+	limits    limits
+}
+
+var _ iterator = (*zipIterator)(nil)
+
+// newZipIterator reads a whole zip file into memory, as its index is at the end.
+func newZipIterator(container string, body io.Reader, limits limits) (*zipIterator, error) {
+	contents, err := io.ReadAll(io.LimitReader(body, limits.zipSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(contents)) > limits.zipSize {
+		return nil, fmt.Errorf("zip file is larger than the limit of %d bytes", limits.zipSize)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(contents), int64(len(contents)))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(reader.File) > limits.members {
+		return nil, fmt.Errorf("archive holds more than the limit of %d files", limits.members)
+	}
+
+	return &zipIterator{
+		container: container,
+		files:     reader.File,
+		limits:    limits,
+	}, nil
+}
+
+func (z *zipIterator) next() (*types.FileInfo, error) {
+	if err := z.close(); err != nil {
+		return nil, err
+	}
+
+	for len(z.files) > 0 {
+		file := z.files[0]
+		z.files = z.files[1:]
+
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		body, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		z.current = body
+
+		return &types.FileInfo{
+			Path:      memberPath(z.container, file.Name),
+			Extension: types.FileExtension(path.Ext(file.Name)),
+			Body:      limitExpanded(body, z.limits),
+		}, nil
+	}
+
+	return nil, io.EOF
+}
+
+func (z *zipIterator) close() error {
+	if z.current == nil {
+		return nil
+	}
+
+	err := z.current.Close()
+	z.current = nil
+
+	return err
+}
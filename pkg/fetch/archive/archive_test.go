@@ -0,0 +1,397 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+
+	fetchTypes "github.com/agrski/greg/pkg/fetch/types"
+	"github.com/agrski/greg/pkg/stats"
+	"github.com/agrski/greg/pkg/types"
+)
+
+// helloBzip2 is "hello bzip2\n", as the standard library cannot write bzip2.
+const helloBzip2 = "\x42\x5a\x68\x39\x31\x41\x59\x26\x53\x59\xab\x6b\xa1\xf1\x00\x00\x02\xd9\x80\x00\x10\x40\x00" +
+	"\x10\x00\x12\x64\xc0\x10\x20\x00\x31\x00\xd3\x4d\x04\x00\x1e\xa3\xef\x4e\x51\xa2\x07\x8b\xb9\x22\x9c" +
+	"\x28\x48\x55\xb5\xd0\xf8\x80"
+
+// sliceFetcher provides a fixed set of files.
+type sliceFetcher struct {
+	files   []*types.FileInfo
+	stopped bool
+}
+
+func (s *sliceFetcher) Start() error {
+	return nil
+}
+
+func (s *sliceFetcher) Stop() error {
+	s.stopped = true
+	return nil
+}
+
+func (s *sliceFetcher) Revision() fetchTypes.Revision {
+	return fetchTypes.Revision{}
+}
+
+func (s *sliceFetcher) Next() (*types.FileInfo, bool) {
+	if len(s.files) == 0 {
+		return nil, false
+	}
+
+	next := s.files[0]
+	s.files = s.files[1:]
+
+	return next, true
+}
+
+type member struct {
+	name     string
+	contents []byte
+}
+
+func makeGzip(t *testing.T, contents []byte) []byte {
+	b := &bytes.Buffer{}
+	w := gzip.NewWriter(b)
+	_, err := w.Write(contents)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return b.Bytes()
+}
+
+func makeXz(t *testing.T, contents []byte) []byte {
+	b := &bytes.Buffer{}
+	w, err := xz.NewWriter(b)
+	require.NoError(t, err)
+	_, err = w.Write(contents)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return b.Bytes()
+}
+
+func makeZstd(t *testing.T, contents []byte) []byte {
+	w, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	defer w.Close()
+
+	return w.EncodeAll(contents, nil)
+}
+
+func makeTar(t *testing.T, members ...member) []byte {
+	b := &bytes.Buffer{}
+	w := tar.NewWriter(b)
+	require.NoError(t, w.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0o755}))
+	for _, m := range members {
+		err := w.WriteHeader(&tar.Header{Name: m.name, Mode: 0o644, Size: int64(len(m.contents))})
+		require.NoError(t, err)
+		_, err = w.Write(m.contents)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	return b.Bytes()
+}
+
+func makeZip(t *testing.T, members ...member) []byte {
+	b := &bytes.Buffer{}
+	w := zip.NewWriter(b)
+	for _, m := range members {
+		f, err := w.Create(m.name)
+		require.NoError(t, err)
+		_, err = f.Write(m.contents)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	return b.Bytes()
+}
+
+func binaryFile(path string, contents []byte) *types.FileInfo {
+	return &types.FileInfo{
+		Path:     path,
+		IsBinary: true,
+		Body:     bytes.NewReader(contents),
+	}
+}
+
+func TestNext(t *testing.T) {
+	type expectedFile struct {
+		path      string
+		extension types.FileExtension
+		isBinary  bool
+		text      string
+	}
+
+	type test struct {
+		name     string
+		files    func(t *testing.T) []*types.FileInfo
+		expected []expectedFile
+	}
+
+	tests := []test{
+		{
+			name: "plain files are untouched",
+			files: func(t *testing.T) []*types.FileInfo {
+				return []*types.FileInfo{
+					{Path: "main.go", Extension: ".go", Text: "package main"},
+					{Path: "logo.png", Extension: ".png", IsBinary: true},
+				}
+			},
+			expected: []expectedFile{
+				{path: "main.go", extension: ".go", text: "package main"},
+				{path: "logo.png", extension: ".png", isBinary: true},
+			},
+		},
+		{
+			name: "compressed files keep their path",
+			files: func(t *testing.T) []*types.FileInfo {
+				return []*types.FileInfo{
+					binaryFile("fixtures/data.json.gz", makeGzip(t, []byte(`{"gzip": true}`))),
+					binaryFile("notes.txt.bz2", []byte(helloBzip2)),
+					binaryFile("notes.md.xz", makeXz(t, []byte("# xz"))),
+					binaryFile("notes.zst", makeZstd(t, []byte("zstd"))),
+				}
+			},
+			expected: []expectedFile{
+				{path: "fixtures/data.json.gz", extension: ".json", text: `{"gzip": true}`},
+				{path: "notes.txt.bz2", extension: ".txt", text: "hello bzip2\n"},
+				{path: "notes.md.xz", extension: ".md", text: "# xz"},
+				{path: "notes.zst", extension: "", text: "zstd"},
+			},
+		},
+		{
+			name: "archive members have nested paths",
+			files: func(t *testing.T) []*types.FileInfo {
+				return []*types.FileInfo{
+					binaryFile(
+						"assets/data.zip",
+						makeZip(
+							t,
+							member{name: "inner/config.json", contents: []byte(`{"zip": true}`)},
+							member{name: "inner/blob.bin", contents: []byte{0x00, 0x01}},
+						),
+					),
+					{Path: "README.md", Extension: ".md", Text: "readme"},
+				}
+			},
+			expected: []expectedFile{
+				{path: "assets/data.zip!inner/config.json", extension: ".json", text: `{"zip": true}`},
+				{path: "assets/data.zip!inner/blob.bin", extension: ".bin", isBinary: true, text: "\x00\x01"},
+				{path: "README.md", extension: ".md", text: "readme"},
+			},
+		},
+		{
+			name: "compressed and nested archives are expanded",
+			files: func(t *testing.T) []*types.FileInfo {
+				inner := makeZip(t, member{name: "Main.java", contents: []byte("class Main {}")})
+				outer := makeTar(
+					t,
+					member{name: "dir/app.jar", contents: inner},
+					member{name: "dir/notes.txt", contents: []byte("notes")},
+				)
+
+				return []*types.FileInfo{
+					binaryFile("release.tgz", makeGzip(t, outer)),
+				}
+			},
+			expected: []expectedFile{
+				{path: "release.tgz!dir/app.jar!Main.java", extension: ".java", text: "class Main {}"},
+				{path: "release.tgz!dir/notes.txt", extension: ".txt", text: "notes"},
+			},
+		},
+		{
+			name: "corrupt archives are skipped",
+			files: func(t *testing.T) []*types.FileInfo {
+				return []*types.FileInfo{
+					binaryFile("broken.zip", []byte("PK\x03\x04 not really a zip")),
+					{Path: "main.go", Extension: ".go", Text: "package main"},
+				}
+			},
+			expected: []expectedFile{
+				{path: "main.go", extension: ".go", text: "package main"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wrapped := &sliceFetcher{files: tt.files(t)}
+			fetcher := New(zerolog.Nop(), wrapped, nil)
+			require.NoError(t, fetcher.Start())
+
+			actual := []expectedFile{}
+			for {
+				next, ok := fetcher.Next()
+				if !ok {
+					break
+				}
+
+				// Contents must be read before moving on to the next file
+				text := next.Text
+				if next.Body != nil {
+					b, err := io.ReadAll(next.Body)
+					require.NoError(t, err)
+					text = string(b)
+				}
+
+				actual = append(
+					actual,
+					expectedFile{
+						path:      next.Path,
+						extension: next.Extension,
+						isBinary:  next.IsBinary,
+						text:      text,
+					},
+				)
+			}
+
+			require.NoError(t, fetcher.Stop())
+			require.True(t, wrapped.stopped)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestDecompressedName(t *testing.T) {
+	require.Equal(t, "data.json", decompressedName("data.json.gz"))
+	require.Equal(t, "logs.tar", decompressedName("logs.TGZ"))
+	require.Equal(t, "data.zip", decompressedName("data.zip"))
+}
+
+func TestNextLimits(t *testing.T) {
+	type test struct {
+		name             string
+		limits           limits
+		file             func(t *testing.T) *types.FileInfo
+		expectedPaths    []string
+		expectedReadErr  bool
+		expectedFailures []string
+	}
+
+	big := bytes.Repeat([]byte("a"), 1_000)
+
+	tests := []test{
+		{
+			name:   "compressed file within limit",
+			limits: limits{zipSize: 10_000, expandedSize: 1_000, members: 10},
+			file: func(t *testing.T) *types.FileInfo {
+				return binaryFile("data.txt.gz", makeGzip(t, big))
+			},
+			expectedPaths: []string{"data.txt.gz"},
+		},
+		{
+			name:   "compressed file too large once expanded",
+			limits: limits{zipSize: 10_000, expandedSize: 999, members: 10},
+			file: func(t *testing.T) *types.FileInfo {
+				return binaryFile("data.txt.gz", makeGzip(t, big))
+			},
+			expectedPaths:   []string{"data.txt.gz"},
+			expectedReadErr: true,
+		},
+		{
+			name:   "tar member too large once expanded",
+			limits: limits{zipSize: 10_000, expandedSize: 999, members: 10},
+			file: func(t *testing.T) *types.FileInfo {
+				return binaryFile("data.tar", makeTar(t, member{name: "dir/big.txt", contents: big}))
+			},
+			expectedPaths:   []string{"data.tar!dir/big.txt"},
+			expectedReadErr: true,
+		},
+		{
+			name:   "tar with too many members",
+			limits: limits{zipSize: 10_000, expandedSize: 1_000, members: 1},
+			file: func(t *testing.T) *types.FileInfo {
+				return binaryFile(
+					"data.tar",
+					makeTar(t, member{name: "dir/a.txt", contents: []byte("a")}, member{name: "dir/b.txt", contents: []byte("b")}),
+				)
+			},
+			expectedPaths:    []string{"data.tar!dir/a.txt"},
+			expectedFailures: []string{"data.tar"},
+		},
+		{
+			name:   "zip with too many members",
+			limits: limits{zipSize: 10_000, expandedSize: 1_000, members: 1},
+			file: func(t *testing.T) *types.FileInfo {
+				return binaryFile(
+					"data.zip",
+					makeZip(t, member{name: "a.txt", contents: []byte("a")}, member{name: "b.txt", contents: []byte("b")}),
+				)
+			},
+			expectedPaths:    []string{},
+			expectedFailures: []string{"data.zip"},
+		},
+		{
+			name:   "zip too large to read",
+			limits: limits{zipSize: 10, expandedSize: 1_000, members: 10},
+			file: func(t *testing.T) *types.FileInfo {
+				return binaryFile("data.zip", makeZip(t, member{name: "a.txt", contents: []byte("a")}))
+			},
+			expectedPaths:    []string{},
+			expectedFailures: []string{"data.zip"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runStats := stats.New()
+			fetcher := New(zerolog.Nop(), &sliceFetcher{files: []*types.FileInfo{tt.file(t)}}, runStats)
+			fetcher.limits = tt.limits
+
+			paths := []string{}
+			for {
+				next, ok := fetcher.Next()
+				if !ok {
+					break
+				}
+				paths = append(paths, next.Path)
+
+				_, err := io.ReadAll(next.Body)
+				if tt.expectedReadErr {
+					require.Error(t, err)
+				} else {
+					require.NoError(t, err)
+				}
+			}
+
+			failures := []string{}
+			for _, f := range runStats.Summary().Failures {
+				failures = append(failures, f.Path)
+			}
+
+			require.Equal(t, tt.expectedPaths, paths)
+			require.ElementsMatch(t, tt.expectedFailures, failures)
+		})
+	}
+}
+
+func TestNextCountsMembersAsFetched(t *testing.T) {
+	runStats := stats.New()
+	files := []*types.FileInfo{
+		{Path: "main.go", Text: "package main"},
+		binaryFile(
+			"src.zip",
+			makeZip(t, member{name: "a.go", contents: []byte("package a")}, member{name: "b.go", contents: []byte("package b")}),
+		),
+	}
+	fetcher := New(zerolog.Nop(), &sliceFetcher{files: files}, runStats)
+
+	for {
+		if _, ok := fetcher.Next(); !ok {
+			break
+		}
+	}
+
+	// The wrapped fetcher counts the files it provides itself
+	require.Equal(t, uint64(2), runStats.Summary().FilesFetched)
+}
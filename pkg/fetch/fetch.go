@@ -18,11 +18,13 @@ func New(
 	logger zerolog.Logger,
 	location types.Location,
 	tokenSource oauth2.TokenSource,
+	options types.Options,
 ) types.Fetcher {
 	githubFetcher := github.New(
 		logger,
 		location,
 		tokenSource,
+		options,
 	)
 
 	return githubFetcher
@@ -0,0 +1,91 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var errBlobClosed = errors.New("blob reader already closed")
+
+// blobReader downloads the raw contents of a blob when first read,
+// so files which are never searched are never downloaded.
+// Readers which are not read to the end should be closed, to release their connections.
+type blobReader struct {
+	g      *GitHub
+	oid    string
+	body   io.ReadCloser
+	cancel func()
+	err    error
+}
+
+var _ io.Reader = (*blobReader)(nil)
+
+func (b *blobReader) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	if b.body == nil {
+		b.body, b.cancel, b.err = b.g.getBlob(b.oid)
+		if b.err != nil {
+			return 0, b.err
+		}
+	}
+
+	n, err := b.body.Read(p)
+	if err != nil {
+		// Release the connection as soon as the blob has been read
+		b.close()
+		b.err = err
+	}
+
+	return n, err
+}
+
+func (b *blobReader) close() {
+	if b.body != nil {
+		_ = b.body.Close()
+		b.cancel()
+		b.body = nil
+	}
+	if b.err == nil {
+		b.err = errBlobClosed
+	}
+}
+
+func (g *GitHub) getBlob(oid string) (io.ReadCloser, func(), error) {
+	g.logger.Debug().Str("func", "getBlob").Str("oid", oid).Send()
+
+	url := fmt.Sprintf(
+		"%s/repos/%s/%s/git/blobs/%s",
+		g.restUrl,
+		g.queryParams.RepoOwner,
+		g.queryParams.RepoName,
+		oid,
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultBlobTimeout)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	request.Header.Set("Accept", rawMediaType)
+
+	response, err := g.httpClient.Do(request)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		_ = response.Body.Close()
+		cancel()
+		return nil, nil, fmt.Errorf("unable to fetch blob %s: %s", oid, response.Status)
+	}
+
+	return response.Body, cancel, nil
+}
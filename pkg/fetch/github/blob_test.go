@@ -0,0 +1,73 @@
+//go:build !integration
+
+package github
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlobReader(t *testing.T) {
+	type test struct {
+		name     string
+		oid      string
+		expected string
+		wantErr  bool
+	}
+
+	tests := []test{
+		{
+			name:     "downloads raw contents",
+			oid:      "abc123",
+			expected: "\x1f\x8bcompressed",
+		},
+		{
+			name:    "fails for unknown blob",
+			oid:     "missing",
+			wantErr: true,
+		},
+	}
+
+	requests := 0
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if r.URL.Path != "/repos/agrski/greg/git/blobs/abc123" || r.Header.Get("Accept") != rawMediaType {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte("\x1f\x8bcompressed"))
+		}),
+	)
+	defer server.Close()
+
+	g := &GitHub{
+		httpClient:  server.Client(),
+		restUrl:     server.URL,
+		queryParams: queryParams{RepoOwner: "agrski", RepoName: "greg"},
+		logger:      zerolog.Nop(),
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = 0
+			reader := &blobReader{g: g, oid: tt.oid}
+			require.Zero(t, requests)
+
+			actual, err := io.ReadAll(reader)
+
+			require.Equal(t, 1, requests)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, string(actual))
+			}
+		})
+	}
+}
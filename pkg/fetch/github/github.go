@@ -3,6 +3,7 @@ package github
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
@@ -11,12 +12,17 @@ import (
 	"golang.org/x/oauth2"
 
 	fetchTypes "github.com/agrski/greg/pkg/fetch/types"
+	"github.com/agrski/greg/pkg/stats"
 	"github.com/agrski/greg/pkg/types"
 )
 
 const (
 	apiUrl                 = "https://api.github.com/graphql"
+	restApiUrl             = "https://api.github.com"
+	rawMediaType           = "application/vnd.github.raw"
 	defaultQueryTimeout    = 30 * time.Second
+	defaultBlobTimeout     = 5 * time.Minute
+	maxInlineBlobSize      = 1 << 20 // Larger text files are streamed rather than taken from tree queries
 	treeResultsCapacity    = 100
 	treesRemainingCapacity = 10_000 // Max subtrees we support for any node; TODO - make configurable
 )
@@ -27,17 +33,35 @@ type graphqlVariables map[string]interface{}
 // An instance should only be used once, as it stores intermediate state internally.
 // A stopped instance cannot be restarted cleanly; instead, create a fresh instance.
 type GitHub struct {
-	client      *graphql.Client
-	queryParams queryParams
-	logger      zerolog.Logger
-	results     <-chan *types.FileInfo
-	cancel      func()
+	client         *graphql.Client
+	httpClient     *http.Client
+	restUrl        string
+	queryParams    queryParams
+	logger         zerolog.Logger
+	pathFilter     fetchTypes.PathFilter
+	binaryContents bool
+	archives       bool
+	stats          *stats.Stats
+	commit         string
+	results        <-chan *types.FileInfo
+	cancel         func()
+	// current is the contents of the file last given by Next, which are released on the next call.
+	current *blobReader
 }
 
 var _ fetchTypes.Fetcher = (*GitHub)(nil)
 
-func New(logger zerolog.Logger, location fetchTypes.Location, tokenSource oauth2.TokenSource) *GitHub {
-	authClient := oauth2.NewClient(context.Background(), tokenSource)
+func New(
+	logger zerolog.Logger,
+	location fetchTypes.Location,
+	tokenSource oauth2.TokenSource,
+	options fetchTypes.Options,
+) *GitHub {
+	authTransport := oauth2.NewClient(context.Background(), tokenSource).Transport
+	if authTransport == nil {
+		authTransport = http.DefaultTransport
+	}
+	authClient := &http.Client{Transport: &countingTransport{transport: authTransport, stats: options.Stats}}
 	client := graphql.NewClient(apiUrl, authClient)
 	logger = logger.With().Str("source", "GitHub").Logger()
 	queryParams := queryParams{
@@ -46,9 +70,15 @@ func New(logger zerolog.Logger, location fetchTypes.Location, tokenSource oauth2
 	}
 
 	return &GitHub{
-		logger:      logger,
-		client:      client,
-		queryParams: queryParams,
+		logger:         logger,
+		client:         client,
+		httpClient:     authClient,
+		restUrl:        restApiUrl,
+		queryParams:    queryParams,
+		pathFilter:     options.PathFilter,
+		binaryContents: options.BinaryContents,
+		archives:       options.Archives,
+		stats:          options.Stats,
 	}
 }
 
@@ -68,6 +98,12 @@ func (g *GitHub) Start() error {
 		return err
 	}
 
+	commit, err := g.getCommit()
+	if err != nil {
+		return err
+	}
+	g.commit = commit
+
 	results, cancel := g.getFiles()
 	g.results = results
 	g.cancel = cancel
@@ -79,18 +115,37 @@ func (g *GitHub) Stop() error {
 	g.logger.Debug().Str("func", "Stop").Msg("stopping GitHub fetcher")
 
 	g.cancel()
+	if g.current != nil {
+		g.current.close()
+	}
 
 	return nil
 }
 
+func (g *GitHub) Revision() fetchTypes.Revision {
+	return fetchTypes.Revision{
+		Ref:    g.queryParams.Commitish,
+		Commit: g.commit,
+	}
+}
+
 func (g *GitHub) Next() (*types.FileInfo, bool) {
 	logger := g.logger.With().Str("func", "Next").Logger()
+
+	// Matchers may stop reading a file early, which would otherwise leave its download open
+	if g.current != nil {
+		g.current.close()
+		g.current = nil
+	}
+
 	next := <-g.results
 	if next == nil {
 		logger.Trace().Msg("no more results")
 		return nil, false
 	} else {
 		logger.Trace().Msg("providing next result")
+		g.stats.RecordFetched()
+		g.current, _ = next.Body.(*blobReader)
 		return next, true
 	}
 }
@@ -121,8 +176,10 @@ func (g *GitHub) getFiles() (<-chan *types.FileInfo, func()) {
 				tree, err := g.getTree(variables)
 				if err != nil {
 					logger.Error().Err(err).Msg("unable to fetch from GitHub")
+					g.stats.RecordFailure(path, err)
 					return
 				}
+				g.stats.RecordCost(tree.RateLimit.Cost)
 
 				g.parseTree(tree, results, remaining, cancel)
 			case <-cancel:
@@ -167,10 +224,35 @@ func (g *GitHub) getDefaultBranchRef() (string, error) {
 	if err != nil {
 		return "", err
 	}
+	g.stats.RecordCost(q.RateLimit.Cost)
 
 	return q.Repository.DefaultBranchRef.Name, nil
 }
 
+// getCommit resolves the commitish to the full SHA of a commit, so results can refer to an unchanging version.
+func (g *GitHub) getCommit() (string, error) {
+	q := &commitQuery{}
+	variables := graphqlVariables{
+		"owner":     graphql.String(g.queryParams.RepoOwner),
+		"repo":      graphql.String(g.queryParams.RepoName),
+		"commitish": graphql.String(g.queryParams.Commitish),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultQueryTimeout)
+	defer cancel()
+
+	err := g.client.Query(ctx, q, variables)
+	if err != nil {
+		return "", err
+	}
+	g.stats.RecordCost(q.RateLimit.Cost)
+
+	if q.Repository.Object.Oid == "" {
+		return "", fmt.Errorf("unable to resolve %s to a commit", g.queryParams.Commitish)
+	}
+
+	return q.Repository.Object.Oid, nil
+}
+
 func (g *GitHub) paramsToVariables() graphqlVariables {
 	rootExpression := g.makeRootPathExpression()
 
@@ -183,8 +265,15 @@ func (g *GitHub) paramsToVariables() graphqlVariables {
 	return variables
 }
 
+// makeRootPathExpression pins trees to the commit resolved on starting, once there is one,
+// so a branch moving part way through a search does not mix files from different commits.
 func (g *GitHub) makeRootPathExpression() string {
-	return fmt.Sprintf("%s:%s", g.queryParams.Commitish, g.queryParams.PathPrefix)
+	commitish := g.commit
+	if commitish == "" {
+		commitish = g.queryParams.Commitish
+	}
+
+	return fmt.Sprintf("%s:%s", commitish, g.queryParams.PathPrefix)
 }
 
 func (g *GitHub) getTree(variables graphqlVariables) (*treeQuery, error) {
@@ -218,13 +307,30 @@ func (g *GitHub) parseTree(
 		default:
 			switch e.Type {
 			case TreeEntryDir:
+				if g.pathFilter != nil && !g.pathFilter.AllowsDirectory(e.Path) {
+					logger.Debug().Str("path", e.Path).Msg("skipping filtered directory")
+					g.stats.RecordFilteredByPath()
+					continue
+				}
 				remaining <- e.Path
 			case TreeEntryFile:
+				if g.pathFilter != nil && !g.pathFilter.AllowsFile(e.Path) && !g.mayHoldAllowedFiles(e) {
+					logger.Trace().Str("path", e.Path).Msg("skipping filtered file")
+					g.stats.RecordFilteredByPath()
+					continue
+				}
 				f := &types.FileInfo{
 					Path:      e.Path,
 					Extension: types.FileExtension(e.Extension),
 					IsBinary:  e.Object.IsBinary,
-					Text:      e.Object.Text,
+				}
+				switch {
+				case !f.IsBinary && !e.Object.IsTruncated && e.Object.ByteSize <= maxInlineBlobSize:
+					// Small text files come with the tree, which saves a request for each of them
+					f.Text = e.Object.Text
+				case !f.IsBinary || g.binaryContents:
+					// Other contents are streamed as they are searched, and binary files are only searched on request
+					f.Body = &blobReader{g: g, oid: e.Object.Oid}
 				}
 				results <- f
 			default:
@@ -234,3 +340,8 @@ func (g *GitHub) parseTree(
 		}
 	}
 }
+
+// mayHoldAllowedFiles reports whether a file, which is not wanted itself, could be an archive of wanted files.
+func (g *GitHub) mayHoldAllowedFiles(e entry) bool {
+	return g.archives && e.Object.IsBinary && g.pathFilter.AllowsArchive(e.Path)
+}
@@ -49,6 +49,7 @@ func TestGetDefaultBranchRef(t *testing.T) {
 					Repository:   types.RepositoryName(tt.repo),
 				},
 				getTokenSource(t),
+				types.Options{},
 			)
 
 			name, err := g.getDefaultBranchRef()
@@ -84,6 +85,7 @@ func TestEnsureCommitish(t *testing.T) {
 					Repository:   types.RepositoryName("gitfind"),
 				},
 				getTokenSource(t),
+				types.Options{},
 			)
 
 			g.queryParams.Commitish = tt.commit
@@ -100,6 +102,24 @@ func TestEnsureCommitish(t *testing.T) {
 	}
 }
 
+func TestGetCommit(t *testing.T) {
+	g := New(
+		zerolog.Nop(),
+		types.Location{
+			Organisation: types.OrganisationName("agrski"),
+			Repository:   types.RepositoryName("gitfind"),
+		},
+		getTokenSource(t),
+		types.Options{},
+	)
+
+	g.queryParams.Commitish = "master"
+
+	commit, err := g.getCommit()
+	require.NoError(t, err)
+	require.Len(t, commit, 40)
+}
+
 func TestGetFiles(t *testing.T) {
 	numResults := 0
 	g := New(
@@ -109,6 +129,7 @@ func TestGetFiles(t *testing.T) {
 			Repository:   types.RepositoryName("gitfind"),
 		},
 		getTokenSource(t),
+		types.Options{},
 	)
 
 	fs, cancel := g.getFiles()
@@ -131,6 +152,7 @@ func TestStart(t *testing.T) {
 			Repository:   types.RepositoryName("gitfind"),
 		},
 		getTokenSource(t),
+		types.Options{},
 	)
 
 	// Ensure API returns some files
@@ -153,6 +175,7 @@ func TestStop(t *testing.T) {
 			Repository:   types.RepositoryName("gitfind"),
 		},
 		getTokenSource(t),
+		types.Options{},
 	)
 
 	// Stopping immediately should be far too fast for any real results to be fetched
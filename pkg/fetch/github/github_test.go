@@ -3,17 +3,41 @@
 package github
 
 import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 
+	fetchTypes "github.com/agrski/greg/pkg/fetch/types"
 	"github.com/agrski/greg/pkg/types"
 )
 
+// skipPrefixFilter rejects any path starting with the given prefix.
+type skipPrefixFilter string
+
+func (f skipPrefixFilter) AllowsDirectory(path string) bool {
+	return !strings.HasPrefix(path, string(f))
+}
+
+func (f skipPrefixFilter) AllowsFile(path string) bool {
+	return !strings.HasPrefix(path, string(f))
+}
+
+// AllowsArchive treats any archive as possibly holding wanted files.
+func (f skipPrefixFilter) AllowsArchive(path string) bool {
+	return true
+}
+
 func TestParseTree(t *testing.T) {
+	g := &GitHub{logger: zerolog.Nop()}
+
 	type test struct {
 		name              string
+		pathFilter        fetchTypes.PathFilter
 		entries           []entry
 		expectedResults   []*types.FileInfo
 		expectedRemaining []string
@@ -55,7 +79,9 @@ func TestParseTree(t *testing.T) {
 					},
 					entryObject{
 						fileContents{
+							Oid:      "abc123",
 							IsBinary: false,
+							ByteSize: 9,
 							Text:     "some text",
 						},
 					},
@@ -83,7 +109,9 @@ func TestParseTree(t *testing.T) {
 					},
 					entryObject{
 						fileContents{
+							Oid:      "abc123",
 							IsBinary: false,
+							ByteSize: 9,
 							Text:     "some text",
 						},
 					},
@@ -107,6 +135,69 @@ func TestParseTree(t *testing.T) {
 			},
 			expectedRemaining: []string{"dir1"},
 		},
+		{
+			name:       "filtered files and dirs are skipped",
+			pathFilter: skipPrefixFilter("vendor"),
+			entries: []entry{
+				{
+					fileMetadata{
+						Type:      TreeEntryFile,
+						Name:      "file1.txt",
+						Path:      "foo/file1.txt",
+						Extension: ".txt",
+					},
+					entryObject{
+						fileContents{
+							Oid:      "abc123",
+							IsBinary: false,
+							ByteSize: 9,
+							Text:     "some text",
+						},
+					},
+				},
+				{
+					fileMetadata{
+						Type:      TreeEntryFile,
+						Name:      "vendor.txt",
+						Path:      "vendor.txt",
+						Extension: ".txt",
+					},
+					entryObject{
+						fileContents{
+							Oid:      "def456",
+							IsBinary: false,
+							ByteSize: 13,
+							Text:     "vendored text",
+						},
+					},
+				},
+				{
+					fileMetadata{
+						Type: TreeEntryDir,
+						Name: "dir1",
+						Path: "dir1",
+					},
+					entryObject{},
+				},
+				{
+					fileMetadata{
+						Type: TreeEntryDir,
+						Name: "vendor",
+						Path: "vendor",
+					},
+					entryObject{},
+				},
+			},
+			expectedResults: []*types.FileInfo{
+				{
+					Path:      "foo/file1.txt",
+					Extension: ".txt",
+					IsBinary:  false,
+					Text:      "some text",
+				},
+			},
+			expectedRemaining: []string{"dir1"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -119,9 +210,7 @@ func TestParseTree(t *testing.T) {
 			remaining := make(chan string, 100)
 			cancel := make(chan struct{}, 1)
 
-			g := GitHub{
-				logger: zerolog.Nop(),
-			}
+			g.pathFilter = tt.pathFilter
 
 			g.parseTree(tree, results, remaining, cancel)
 
@@ -143,3 +232,154 @@ func TestParseTree(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTreeBinaryContents(t *testing.T) {
+	tree := &treeQuery{}
+	tree.Repository.Object.Tree.Entries = []entry{
+		{
+			fileMetadata{Type: TreeEntryFile, Name: "data.gz", Path: "data.gz", Extension: ".gz"},
+			entryObject{fileContents{Oid: "abc123", IsBinary: true}},
+		},
+		{
+			fileMetadata{Type: TreeEntryFile, Name: "file.txt", Path: "file.txt", Extension: ".txt"},
+			entryObject{fileContents{Oid: "def456", ByteSize: 9, Text: "some text"}},
+		},
+	}
+
+	for _, binaryContents := range []bool{false, true} {
+		results := make(chan *types.FileInfo, 2)
+		g := &GitHub{
+			logger:         zerolog.Nop(),
+			binaryContents: binaryContents,
+		}
+
+		g.parseTree(tree, results, make(chan string), make(chan struct{}))
+		close(results)
+
+		binary := <-results
+		if binaryContents {
+			require.Equal(t, &blobReader{g: g, oid: "abc123"}, binary.Body)
+		} else {
+			require.Nil(t, binary.Body)
+		}
+
+		text := <-results
+		require.Nil(t, text.Body)
+		require.Equal(t, "some text", text.Text)
+	}
+}
+
+func TestParseTreeArchives(t *testing.T) {
+	tree := &treeQuery{}
+	tree.Repository.Object.Tree.Entries = []entry{
+		{
+			fileMetadata{Type: TreeEntryFile, Name: "src.zip", Path: "dist/src.zip", Extension: ".zip"},
+			entryObject{fileContents{Oid: "abc123", IsBinary: true}},
+		},
+		{
+			fileMetadata{Type: TreeEntryFile, Name: "notes.txt", Path: "dist/notes.txt", Extension: ".txt"},
+			entryObject{fileContents{Oid: "def456"}},
+		},
+	}
+
+	for _, archives := range []bool{false, true} {
+		results := make(chan *types.FileInfo, 2)
+		g := &GitHub{
+			logger:         zerolog.Nop(),
+			pathFilter:     skipPrefixFilter("dist"),
+			binaryContents: archives,
+			archives:       archives,
+		}
+
+		g.parseTree(tree, results, make(chan string), make(chan struct{}))
+		close(results)
+
+		actual := []string{}
+		for f := range results {
+			actual = append(actual, f.Path)
+		}
+
+		// Only binary files can be archives, so filtered text files are still skipped
+		if archives {
+			require.Equal(t, []string{"dist/src.zip"}, actual)
+		} else {
+			require.Empty(t, actual)
+		}
+	}
+}
+
+func TestParseTreeRequestsOnlyLargeFiles(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			_, _ = w.Write([]byte("large text"))
+		}),
+	)
+	defer server.Close()
+
+	tree := &treeQuery{}
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		tree.Repository.Object.Tree.Entries = append(
+			tree.Repository.Object.Tree.Entries,
+			entry{
+				fileMetadata{Type: TreeEntryFile, Name: name, Path: name, Extension: ".go"},
+				entryObject{fileContents{Oid: name, ByteSize: 12, Text: "package main"}},
+			},
+		)
+	}
+	tree.Repository.Object.Tree.Entries = append(
+		tree.Repository.Object.Tree.Entries,
+		entry{
+			fileMetadata{Type: TreeEntryFile, Name: "large.txt", Path: "large.txt", Extension: ".txt"},
+			entryObject{fileContents{Oid: "large", ByteSize: maxInlineBlobSize + 1, IsTruncated: true}},
+		},
+	)
+
+	results := make(chan *types.FileInfo, 4)
+	g := &GitHub{
+		logger:      zerolog.Nop(),
+		httpClient:  server.Client(),
+		restUrl:     server.URL,
+		queryParams: queryParams{RepoOwner: "agrski", RepoName: "greg"},
+	}
+
+	g.parseTree(tree, results, make(chan string), make(chan struct{}))
+	close(results)
+
+	contents := []string{}
+	for f := range results {
+		b, err := io.ReadAll(f.Reader())
+		require.NoError(t, err)
+		contents = append(contents, string(b))
+	}
+
+	require.Equal(t, []string{"package main", "package main", "package main", "large text"}, contents)
+	require.Equal(t, 1, requests)
+}
+
+func TestMakeRootPathExpression(t *testing.T) {
+	g := &GitHub{queryParams: queryParams{Commitish: "master", PathPrefix: "pkg/fetch"}}
+	require.Equal(t, "master:pkg/fetch", g.makeRootPathExpression())
+
+	g.commit = "0123456789abcdef0123456789abcdef01234567"
+	require.Equal(t, "0123456789abcdef0123456789abcdef01234567:pkg/fetch", g.makeRootPathExpression())
+}
+
+func TestNextClosesPreviousBlob(t *testing.T) {
+	results := make(chan *types.FileInfo, 2)
+	g := &GitHub{logger: zerolog.Nop(), results: results}
+
+	first := &blobReader{g: g, oid: "abc123"}
+	results <- &types.FileInfo{Path: "first.txt", Body: first}
+	results <- &types.FileInfo{Path: "second.txt", Body: &blobReader{g: g, oid: "def456"}}
+
+	_, ok := g.Next()
+	require.True(t, ok)
+	require.NoError(t, first.err)
+
+	_, ok = g.Next()
+	require.True(t, ok)
+	_, err := first.Read(make([]byte, 1))
+	require.ErrorIs(t, err, errBlobClosed)
+}
@@ -14,16 +14,32 @@ type queryParams struct {
 	PathPrefix string
 }
 
+// rateLimit reports what a query cost against the GraphQL rate limit.
+type rateLimit struct {
+	Cost int
+}
+
 type branchRefQuery struct {
 	Repository struct {
 		DefaultBranchRef struct {
 			Name string
 		}
 	} `graphql:"repository(owner: $owner, name: $repo)"`
+	RateLimit rateLimit
+}
+
+type commitQuery struct {
+	Repository struct {
+		Object struct {
+			Oid string
+		} `graphql:"object(expression: $commitish)"`
+	} `graphql:"repository(owner: $owner, name: $repo)"`
+	RateLimit rateLimit
 }
 
 type treeQuery struct {
 	Repository repository `graphql:"repository(owner: $owner, name: $repo)"`
+	RateLimit  rateLimit
 }
 
 type repository struct {
@@ -55,7 +71,12 @@ type fileMetadata struct {
 	Path      string
 }
 
+// fileContents includes the text of blobs, saving a request per file,
+// but only small blobs use it, so large files are streamed separately rather than held in memory.
 type fileContents struct {
-	IsBinary bool
-	Text     string
+	Oid         string
+	IsBinary    bool
+	ByteSize    int
+	IsTruncated bool
+	Text        string
 }
@@ -0,0 +1,41 @@
+package github
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/agrski/greg/pkg/stats"
+)
+
+// countingTransport records every request made to GitHub and the size of every response body.
+type countingTransport struct {
+	transport http.RoundTripper
+	stats     *stats.Stats
+}
+
+var _ http.RoundTripper = (*countingTransport)(nil)
+
+func (t *countingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	t.stats.RecordRequest()
+
+	response, err := t.transport.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+
+	response.Body = &countingBody{ReadCloser: response.Body, stats: t.stats}
+
+	return response, nil
+}
+
+type countingBody struct {
+	io.ReadCloser
+	stats *stats.Stats
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.stats.RecordDownloaded(n)
+
+	return n, err
+}
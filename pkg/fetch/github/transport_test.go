@@ -0,0 +1,38 @@
+//go:build !integration
+
+package github
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/agrski/greg/pkg/stats"
+)
+
+func TestCountingTransport(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("0123456789"))
+		}),
+	)
+	defer server.Close()
+
+	s := stats.New()
+	client := &http.Client{Transport: &countingTransport{transport: server.Client().Transport, stats: s}}
+
+	for i := 0; i < 2; i++ {
+		response, err := client.Get(server.URL)
+		require.NoError(t, err)
+		_, err = io.ReadAll(response.Body)
+		require.NoError(t, err)
+		require.NoError(t, response.Body.Close())
+	}
+
+	summary := s.Summary()
+	require.Equal(t, uint64(2), summary.APIRequests)
+	require.Equal(t, uint64(20), summary.BytesDownloaded)
+}
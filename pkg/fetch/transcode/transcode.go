@@ -0,0 +1,212 @@
+package transcode
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+
+	fetchTypes "github.com/agrski/greg/pkg/fetch/types"
+	"github.com/agrski/greg/pkg/types"
+)
+
+const (
+	EncodingUTF8        = "utf-8"
+	EncodingUTF16LE     = "utf-16le"
+	EncodingUTF16BE     = "utf-16be"
+	EncodingISO8859_1   = "iso-8859-1"
+	EncodingWindows1252 = "windows-1252"
+	// sniffLength is how much of a file is read to detect its encoding.
+	sniffLength = 8000
+	// minUTF16ZeroRatio is the least proportion of code units needing a zero byte for BOM-less UTF-16,
+	// as expected of text which is mostly ASCII.
+	minUTF16ZeroRatio = 0.4
+)
+
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+var decoders = map[string]encoding.Encoding{
+	EncodingUTF8:        unicode.UTF8BOM,
+	EncodingUTF16LE:     unicode.UTF16(unicode.LittleEndian, unicode.UseBOM),
+	EncodingUTF16BE:     unicode.UTF16(unicode.BigEndian, unicode.UseBOM),
+	EncodingISO8859_1:   charmap.ISO8859_1,
+	EncodingWindows1252: charmap.Windows1252,
+}
+
+// Fetcher detects the encodings of files from another fetcher and transcodes them to UTF-8.
+// Detection relies on raw contents, so the wrapped fetcher should provide the contents of binary files,
+// which is how UTF-16 files usually appear.
+type Fetcher struct {
+	fetcher fetchTypes.Fetcher
+	logger  zerolog.Logger
+}
+
+var _ fetchTypes.Fetcher = (*Fetcher)(nil)
+
+func New(logger zerolog.Logger, fetcher fetchTypes.Fetcher) *Fetcher {
+	logger = logger.With().Str("source", "TranscodingFetcher").Logger()
+
+	return &Fetcher{
+		fetcher: fetcher,
+		logger:  logger,
+	}
+}
+
+func (f *Fetcher) Start() error {
+	return f.fetcher.Start()
+}
+
+func (f *Fetcher) Stop() error {
+	return f.fetcher.Stop()
+}
+
+func (f *Fetcher) Revision() fetchTypes.Revision {
+	return f.fetcher.Revision()
+}
+
+func (f *Fetcher) Next() (*types.FileInfo, bool) {
+	logger := f.logger.With().Str("func", "Next").Logger()
+
+	next, ok := f.fetcher.Next()
+	if !ok {
+		return nil, false
+	}
+
+	if err := transcode(next); err != nil {
+		logger.Warn().Err(err).Str("filename", next.Path).Msg("unable to detect encoding")
+	} else if next.Encoding != "" {
+		logger.Debug().Str("filename", next.Path).Str("encoding", next.Encoding).Msg("detected encoding")
+	}
+
+	return next, true
+}
+
+// transcode replaces the contents of a file with UTF-8 when it is recognisably in another encoding.
+func transcode(next *types.FileInfo) error {
+	if next.Body == nil {
+		// Fetched text is already UTF-8 unless it is invalid
+		if strings.HasPrefix(next.Text, string(bomUTF8)) || !utf8.ValidString(next.Text) {
+			next.Body = strings.NewReader(next.Text)
+			next.Text = ""
+		} else {
+			return nil
+		}
+	}
+
+	body := bufio.NewReaderSize(next.Body, sniffLength)
+	next.Body = body
+
+	start, err := body.Peek(sniffLength)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return err
+	}
+
+	detected := Detect(start)
+	decoder, ok := decoders[detected]
+	if !ok {
+		return nil
+	}
+
+	next.Body = transform.NewReader(body, decoder.NewDecoder())
+	next.IsBinary = false
+	next.Encoding = detected
+
+	return nil
+}
+
+// Detect guesses the encoding of text from its beginning, or gives an empty string when it looks like
+// BOM-less UTF-8 or binary data.
+// UTF-8 with a BOM is detected as such, so the BOM is removed by decoding.
+func Detect(start []byte) string {
+	switch {
+	case bytes.HasPrefix(start, bomUTF8):
+		return EncodingUTF8
+	case bytes.HasPrefix(start, bomUTF16LE):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(start, bomUTF16BE):
+		return EncodingUTF16BE
+	}
+
+	if bytes.IndexByte(start, 0) != -1 {
+		return detectUTF16(start)
+	}
+
+	// A multi-byte character may be cut short at the end of what has been read
+	if utf8.Valid(start) || utf8.Valid(start[:lastRuneStart(start)]) {
+		return ""
+	}
+
+	return detectSingleByte(start)
+}
+
+// detectUTF16 recognises BOM-less UTF-16 by zero bytes, which mostly-ASCII text has in every other byte.
+func detectUTF16(start []byte) string {
+	units := len(start) / 2
+	if units == 0 {
+		return ""
+	}
+
+	var evenZeroes, oddZeroes int
+	for idx := 0; idx+1 < len(start); idx += 2 {
+		if start[idx] == 0 {
+			evenZeroes++
+		}
+		if start[idx+1] == 0 {
+			oddZeroes++
+		}
+	}
+
+	switch {
+	case evenZeroes == 0 && float64(oddZeroes)/float64(units) >= minUTF16ZeroRatio:
+		return EncodingUTF16LE
+	case oddZeroes == 0 && float64(evenZeroes)/float64(units) >= minUTF16ZeroRatio:
+		return EncodingUTF16BE
+	default:
+		return ""
+	}
+}
+
+// detectSingleByte recognises legacy 8-bit text, which has no control characters besides whitespace.
+// Windows-1252 uses the range ISO 8859-1 reserves for control characters for printable ones instead.
+func detectSingleByte(start []byte) string {
+	usesWindowsRange := false
+
+	for _, b := range start {
+		switch {
+		case b == '\t', b == '\n', b == '\v', b == '\f', b == '\r', b == 0x1b:
+			continue
+		case b < 0x20, b == 0x7f:
+			return ""
+		case 0x80 <= b && b <= 0x9f:
+			usesWindowsRange = true
+		}
+	}
+
+	if usesWindowsRange {
+		return EncodingWindows1252
+	}
+
+	return EncodingISO8859_1
+}
+
+func lastRuneStart(b []byte) int {
+	for idx := len(b) - 1; idx >= 0 && idx >= len(b)-utf8.UTFMax; idx-- {
+		if utf8.RuneStart(b[idx]) {
+			return idx
+		}
+	}
+
+	return len(b)
+}
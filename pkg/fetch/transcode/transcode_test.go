@@ -0,0 +1,185 @@
+package transcode
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+
+	fetchTypes "github.com/agrski/greg/pkg/fetch/types"
+	"github.com/agrski/greg/pkg/types"
+)
+
+// sliceFetcher provides a fixed set of files.
+type sliceFetcher struct {
+	files []*types.FileInfo
+}
+
+func (s *sliceFetcher) Start() error {
+	return nil
+}
+
+func (s *sliceFetcher) Stop() error {
+	return nil
+}
+
+func (s *sliceFetcher) Revision() fetchTypes.Revision {
+	return fetchTypes.Revision{}
+}
+
+func (s *sliceFetcher) Next() (*types.FileInfo, bool) {
+	if len(s.files) == 0 {
+		return nil, false
+	}
+
+	next := s.files[0]
+	s.files = s.files[1:]
+
+	return next, true
+}
+
+func encode(t *testing.T, text string, withBOM bool, bigEndian bool) []byte {
+	endianness, bom := unicode.LittleEndian, unicode.IgnoreBOM
+	if bigEndian {
+		endianness = unicode.BigEndian
+	}
+	if withBOM {
+		bom = unicode.UseBOM
+	}
+
+	encoded, err := unicode.UTF16(endianness, bom).NewEncoder().Bytes([]byte(text))
+	require.NoError(t, err)
+
+	return encoded
+}
+
+func TestDetect(t *testing.T) {
+	type test struct {
+		name     string
+		start    func(t *testing.T) []byte
+		expected string
+	}
+
+	tests := []test{
+		{
+			name:     "plain UTF-8",
+			start:    func(t *testing.T) []byte { return []byte("naïve café\n") },
+			expected: "",
+		},
+		{
+			name:     "UTF-8 cut short mid-character",
+			start:    func(t *testing.T) []byte { return []byte("café")[:4] },
+			expected: "",
+		},
+		{
+			name:     "UTF-8 with BOM",
+			start:    func(t *testing.T) []byte { return append([]byte{0xef, 0xbb, 0xbf}, "text"...) },
+			expected: EncodingUTF8,
+		},
+		{
+			name:     "UTF-16LE with BOM",
+			start:    func(t *testing.T) []byte { return encode(t, "hello\r\n", true, false) },
+			expected: EncodingUTF16LE,
+		},
+		{
+			name:     "UTF-16BE with BOM",
+			start:    func(t *testing.T) []byte { return encode(t, "hello\r\n", true, true) },
+			expected: EncodingUTF16BE,
+		},
+		{
+			name:     "UTF-16LE without BOM",
+			start:    func(t *testing.T) []byte { return encode(t, "Windows text\r\n", false, false) },
+			expected: EncodingUTF16LE,
+		},
+		{
+			name:     "UTF-16BE without BOM",
+			start:    func(t *testing.T) []byte { return encode(t, "Windows text\r\n", false, true) },
+			expected: EncodingUTF16BE,
+		},
+		{
+			name:     "ISO 8859-1",
+			start:    func(t *testing.T) []byte { return []byte("caf\xe9\n") },
+			expected: EncodingISO8859_1,
+		},
+		{
+			name:     "Windows-1252",
+			start:    func(t *testing.T) []byte { return []byte("\x93quoted\x94 caf\xe9\r\n") },
+			expected: EncodingWindows1252,
+		},
+		{
+			name:     "binary data",
+			start:    func(t *testing.T) []byte { return []byte("\x7fELF\x02\x01\x01\x00\x00\x00\xff\x03") },
+			expected: "",
+		},
+		{
+			name:     "binary data without zero bytes",
+			start:    func(t *testing.T) []byte { return []byte("\x89PNG\r\n\x1a\n\x01\xff") },
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, Detect(tt.start(t)))
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	latin1, err := charmap.ISO8859_1.NewEncoder().String("größe = 1\n")
+	require.NoError(t, err)
+
+	files := []*types.FileInfo{
+		{Path: "main.go", Text: "package main\n"},
+		{Path: "bom.txt", Text: "\xef\xbb\xbfbom\n"},
+		{Path: "streamed.txt", Body: bytes.NewReader([]byte("\xef\xbb\xbfstreamed\n"))},
+		{Path: "latin1.txt", Text: latin1},
+		{Path: "windows.txt", IsBinary: true, Body: bytes.NewReader(encode(t, "line 1\r\nline 2\r\n", true, false))},
+		{Path: "app.exe", IsBinary: true, Body: bytes.NewReader([]byte("MZ\x90\x00\x03\x00\x00\x00"))},
+		{Path: "logo.png", IsBinary: true},
+	}
+
+	type result struct {
+		path     string
+		isBinary bool
+		encoding string
+		text     string
+	}
+
+	expected := []result{
+		{path: "main.go", text: "package main\n"},
+		{path: "bom.txt", encoding: EncodingUTF8, text: "bom\n"},
+		{path: "streamed.txt", encoding: EncodingUTF8, text: "streamed\n"},
+		{path: "latin1.txt", encoding: EncodingISO8859_1, text: "größe = 1\n"},
+		{path: "windows.txt", encoding: EncodingUTF16LE, text: "line 1\r\nline 2\r\n"},
+		{path: "app.exe", isBinary: true, text: "MZ\x90\x00\x03\x00\x00\x00"},
+		{path: "logo.png", isBinary: true},
+	}
+
+	fetcher := New(zerolog.Nop(), &sliceFetcher{files: files})
+	require.NoError(t, fetcher.Start())
+
+	actual := []result{}
+	for {
+		next, ok := fetcher.Next()
+		if !ok {
+			break
+		}
+
+		text := next.Text
+		if next.Body != nil {
+			b, err := io.ReadAll(next.Body)
+			require.NoError(t, err)
+			text = string(b)
+		}
+
+		actual = append(actual, result{path: next.Path, isBinary: next.IsBinary, encoding: next.Encoding, text: text})
+	}
+
+	require.NoError(t, fetcher.Stop())
+	require.Equal(t, expected, actual)
+}
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"github.com/agrski/greg/pkg/stats"
 	common "github.com/agrski/greg/pkg/types"
 )
 
@@ -14,8 +15,37 @@ type Location struct {
 	Repository   RepositoryName
 }
 
+// PathFilter decides which paths in a repository are worth fetching.
+type PathFilter interface {
+	AllowsDirectory(path string) bool
+	AllowsFile(path string) bool
+	// AllowsArchive reports whether an archive might contain files worth fetching, even if it is not itself.
+	AllowsArchive(path string) bool
+}
+
+type Options struct {
+	// PathFilter, if set, skips unwanted files and avoids descending into unwanted directories.
+	PathFilter PathFilter
+	// BinaryContents provides the raw contents of binary files as their Body, such as for compressed files.
+	BinaryContents bool
+	// Archives lets binary files through the PathFilter when files within them could be searched.
+	Archives bool
+	// Stats, if set, records what is fetched and how many requests it takes.
+	Stats *stats.Stats
+}
+
+// Revision identifies the version of a repository files are fetched from.
+type Revision struct {
+	// Ref is the branch, tag, or other expression used to look up the version, such as main.
+	Ref string
+	// Commit is the full SHA of the commit the ref resolved to.
+	Commit string
+}
+
 type Fetcher interface {
 	Start() error
 	Stop() error
 	Next() (*common.FileInfo, bool)
+	// Revision gives the version being fetched from, which is only known once started.
+	Revision() Revision
 }
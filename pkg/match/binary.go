@@ -0,0 +1,181 @@
+package match
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/rs/zerolog"
+
+	"github.com/agrski/greg/pkg/types"
+)
+
+const (
+	// HexdumpWidth is the number of bytes shown per row of a hexdump.
+	// Binary excerpts are aligned to rows of this width, with a row either side of the match.
+	HexdumpWidth = 16
+	// binaryChunkLength is how much of a binary file is read at once.
+	binaryChunkLength = 64 * 1024
+	// binaryContextLength is the most context needed either side of a match to fill its rows.
+	binaryContextLength = 2 * HexdumpWidth
+)
+
+// binaryMatcher searches the raw bytes of binary files, leaving text files to another matcher.
+type binaryMatcher struct {
+	matcher         Matcher
+	caseInsensitive bool
+	// maxCount stops matching once this many positions are found, unless zero.
+	maxCount uint
+	logger   zerolog.Logger
+}
+
+var _ Matcher = (*binaryMatcher)(nil)
+
+func newBinaryMatcher(logger zerolog.Logger, matcher Matcher, caseInsensitive bool, maxCount uint) *binaryMatcher {
+	logger = logger.With().Str("source", "BinaryMatcher").Logger()
+	return &binaryMatcher{
+		matcher:         matcher,
+		caseInsensitive: caseInsensitive,
+		maxCount:        maxCount,
+		logger:          logger,
+	}
+}
+
+// Match finds the byte offsets of the pattern in a binary file, which are given as columns on the first line.
+func (bm *binaryMatcher) Match(pattern string, next *types.FileInfo) (*Match, bool) {
+	logger := bm.logger.With().Str("func", "Match").Logger()
+
+	if !next.IsBinary {
+		return bm.matcher.Match(pattern, next)
+	}
+
+	if next.Body == nil && next.Text == "" {
+		logger.Debug().Str("filename", next.Path).Msg("skipping binary file without contents")
+		return nil, false
+	}
+
+	if pattern == "" {
+		return nil, false
+	}
+
+	needle := []byte(pattern)
+	if bm.caseInsensitive {
+		needle = asciiLower(needle)
+	}
+
+	positions, err := bm.matchBytes(needle, next.Reader())
+	if err != nil {
+		logger.Error().Err(err).Str("filename", next.Path).Msg("unable to read file")
+		return nil, false
+	}
+
+	if len(positions) == 0 {
+		return nil, false
+	}
+
+	return &Match{Positions: positions}, true
+}
+
+// matchBytes searches a chunk at a time, holding back matches near the end of each chunk
+// until enough has been read to give them context.
+func (bm *binaryMatcher) matchBytes(needle []byte, reader io.Reader) ([]*FilePosition, error) {
+	positions := []*FilePosition{}
+	window := []byte{}
+	chunk := make([]byte, binaryChunkLength)
+	var windowOffset int
+	searchFrom := 0
+
+	for {
+		n, err := io.ReadFull(reader, chunk)
+		isLastChunk := errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+		if err != nil && !isLastChunk {
+			return nil, err
+		}
+		window = append(window, chunk[:n]...)
+
+		limit := len(window) - len(needle)
+		if !isLastChunk {
+			limit -= binaryContextLength
+		}
+
+		searchable := window
+		if bm.caseInsensitive {
+			searchable = asciiLower(window)
+		}
+
+		searchedUntil := searchFrom
+		for searchedUntil <= limit {
+			idx := bytes.Index(searchable[searchedUntil:], needle)
+			if idx == -1 || searchedUntil+idx > limit {
+				break
+			}
+
+			start := searchedUntil + idx
+			positions = append(positions, makeBinaryExcerpt(window, windowOffset, start, len(needle)))
+			searchedUntil = start + len(needle)
+
+			if bm.maxCount > 0 && uint(len(positions)) >= bm.maxCount {
+				return positions, nil
+			}
+		}
+
+		if isLastChunk {
+			return positions, nil
+		}
+
+		nextSearchFrom := limit + 1
+		if nextSearchFrom < searchedUntil {
+			nextSearchFrom = searchedUntil
+		}
+		if nextSearchFrom < 0 {
+			nextSearchFrom = 0
+		}
+		keepFrom := nextSearchFrom - binaryContextLength
+		if keepFrom < 0 {
+			keepFrom = 0
+		}
+
+		windowOffset += keepFrom
+		searchFrom = nextSearchFrom - keepFrom
+		window = append([]byte{}, window[keepFrom:]...)
+	}
+}
+
+// makeBinaryExcerpt builds a position for a match, with the rows of bytes around it as text.
+func makeBinaryExcerpt(window []byte, windowOffset int, start int, length int) *FilePosition {
+	offset := windowOffset + start
+
+	excerptStart := alignDown(offset, HexdumpWidth) - HexdumpWidth
+	if excerptStart < windowOffset {
+		excerptStart = windowOffset
+	}
+	excerptEnd := alignDown(offset+length+HexdumpWidth-1, HexdumpWidth) + HexdumpWidth
+	if excerptEnd > windowOffset+len(window) {
+		excerptEnd = windowOffset + len(window)
+	}
+
+	return &FilePosition{
+		ColumnStart: uint(offset),
+		ColumnEnd:   uint(offset + length),
+		Text:        string(window[excerptStart-windowOffset : excerptEnd-windowOffset]),
+		TextColumn:  uint(excerptStart),
+		Binary:      true,
+	}
+}
+
+func alignDown(n int, width int) int {
+	return n - n%width
+}
+
+// asciiLower lowers only ASCII letters, so offsets in arbitrary bytes are kept.
+func asciiLower(b []byte) []byte {
+	lowered := make([]byte, len(b))
+	for idx, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		lowered[idx] = c
+	}
+
+	return lowered
+}
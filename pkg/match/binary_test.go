@@ -0,0 +1,139 @@
+package match
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agrski/greg/pkg/types"
+)
+
+func TestBinaryMatch(t *testing.T) {
+	type test struct {
+		name              string
+		isCaseInsensitive bool
+		fileInfo          *types.FileInfo
+		pattern           string
+		expectedOk        bool
+		expectedPositions []*FilePosition
+	}
+
+	artefact := "\x7fELF\x02\x01\x01\x00" + strings.Repeat("\x00", 24) + "version=1.2.3\x00" + strings.Repeat("\xff", 40)
+	padding := strings.Repeat("\x00", binaryChunkLength)
+
+	tests := []test{
+		{
+			name:       "text files are left to the wrapped matcher",
+			fileInfo:   &types.FileInfo{Path: "main.go", Text: "package main"},
+			pattern:    "main",
+			expectedOk: true,
+			expectedPositions: []*FilePosition{
+				{ColumnStart: 8, ColumnEnd: 12, Text: "package main"},
+			},
+		},
+		{
+			name:       "binary files without contents are skipped",
+			fileInfo:   &types.FileInfo{Path: "logo.png", IsBinary: true},
+			pattern:    "PNG",
+			expectedOk: false,
+		},
+		{
+			name:       "byte offsets and surrounding rows",
+			fileInfo:   &types.FileInfo{Path: "app", IsBinary: true, Body: strings.NewReader(artefact)},
+			pattern:    "version=",
+			expectedOk: true,
+			expectedPositions: []*FilePosition{
+				{
+					ColumnStart: 32,
+					ColumnEnd:   40,
+					Text:        artefact[16:64],
+					TextColumn:  16,
+					Binary:      true,
+				},
+			},
+		},
+		{
+			name:              "case-insensitive ASCII",
+			isCaseInsensitive: true,
+			fileInfo:          &types.FileInfo{Path: "app", IsBinary: true, Text: artefact},
+			pattern:           "elf",
+			expectedOk:        true,
+			expectedPositions: []*FilePosition{
+				{
+					ColumnStart: 1,
+					ColumnEnd:   4,
+					Text:        artefact[:32],
+					Binary:      true,
+				},
+			},
+		},
+		{
+			name: "match crossing chunk boundary",
+			fileInfo: &types.FileInfo{
+				Path:     "app",
+				IsBinary: true,
+				Body:     strings.NewReader(padding[:binaryChunkLength-3] + "needle" + padding[:100]),
+			},
+			pattern:    "needle",
+			expectedOk: true,
+			expectedPositions: []*FilePosition{
+				{
+					ColumnStart: binaryChunkLength - 3,
+					ColumnEnd:   binaryChunkLength + 3,
+					Text:        padding[:16+13] + "needle" + padding[:13+16],
+					TextColumn:  binaryChunkLength - 32,
+					Binary:      true,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := newExactMatcher(zerolog.Nop(), tt.isCaseInsensitive, 0)
+			matcher := newBinaryMatcher(zerolog.Nop(), inner, tt.isCaseInsensitive, 0)
+
+			actual, ok := matcher.Match(tt.pattern, tt.fileInfo)
+
+			require.Equal(t, tt.expectedOk, ok)
+			if !tt.expectedOk {
+				require.Nil(t, actual)
+				return
+			}
+
+			for _, p := range tt.expectedPositions {
+				if !p.Binary {
+					p.LineEnd = p.Line
+				}
+			}
+			require.Equal(t, tt.expectedPositions, actual.Positions)
+		})
+	}
+}
+
+func TestBinaryMatchManyChunks(t *testing.T) {
+	needle := []byte("https://example.com")
+	contents := bytes.Repeat([]byte{0xde, 0xad, 0xbe, 0xef}, binaryChunkLength)
+	offsets := []int{0, binaryChunkLength - 10, binaryChunkLength + 20, 3*binaryChunkLength - 1, len(contents) - len(needle)}
+	for _, o := range offsets {
+		copy(contents[o:], needle)
+	}
+
+	matcher := newBinaryMatcher(zerolog.Nop(), nil, false, 0)
+
+	actual, ok := matcher.Match(string(needle), &types.FileInfo{IsBinary: true, Body: bytes.NewReader(contents)})
+	require.True(t, ok)
+
+	actualOffsets := []int{}
+	for _, p := range actual.Positions {
+		actualOffsets = append(actualOffsets, int(p.ColumnStart))
+
+		start, end := p.Span()
+		require.Equal(t, string(needle), p.Text[start:end])
+		require.Zero(t, p.TextColumn%HexdumpWidth)
+	}
+	require.Equal(t, offsets, actualOffsets)
+}
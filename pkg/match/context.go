@@ -0,0 +1,156 @@
+package match
+
+import (
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/agrski/greg/pkg/types"
+)
+
+// Hunk is a run of consecutive lines around one or more match positions.
+type Hunk struct {
+	Lines []*Line
+}
+
+type Line struct {
+	Number uint
+	Text   string
+	// Secrets are all those on the line when looking for secrets, including any not reported as matches,
+	// so they can be hidden wherever the line is shown.
+	Secrets []*FilePosition
+}
+
+type contextMatcher struct {
+	matcher Matcher
+	before  uint
+	after   uint
+	// secrets finds the secrets on each line of context, if looking for them.
+	secrets *secretMatcher
+	logger  zerolog.Logger
+}
+
+var _ Matcher = (*contextMatcher)(nil)
+
+func newContextMatcher(logger zerolog.Logger, matcher Matcher, before uint, after uint, secrets *secretMatcher) *contextMatcher {
+	logger = logger.With().Str("source", "ContextMatcher").Logger()
+
+	return &contextMatcher{
+		matcher: matcher,
+		before:  before,
+		after:   after,
+		secrets: secrets,
+		logger:  logger,
+	}
+}
+
+func (cm *contextMatcher) Match(pattern string, next *types.FileInfo) (*Match, bool) {
+	logger := cm.logger.With().Str("func", "Match").Logger()
+
+	// Binary matches carry their own context, as bytes rather than lines
+	if next.IsBinary {
+		return cm.matcher.Match(pattern, next)
+	}
+
+	// The wrapped matcher may only stream the file, but context lines need all of it.
+	// Positions are only known once the whole file has been searched, so which lines to keep cannot be known sooner.
+	text, err := next.Contents()
+	if err != nil {
+		logger.Error().Err(err).Str("filename", next.Path).Msg("unable to read file")
+		return nil, false
+	}
+
+	match, ok := cm.matcher.Match(pattern, next)
+	if !ok {
+		return nil, false
+	}
+
+	lines := splitLines(text)
+	match.Hunks = makeHunks(match.Positions, lines, cm.before, cm.after)
+
+	// Limits on matches may leave secrets unreported, but still shown as context
+	if cm.secrets != nil {
+		for _, h := range match.Hunks {
+			for _, l := range h.Lines {
+				if secrets := cm.secrets.matchLine(l.Number, l.Text); len(secrets) > 0 {
+					l.Secrets = secrets
+				}
+			}
+		}
+	}
+
+	return match, true
+}
+
+// makeHunks builds a window of context lines around each position,
+// merging windows which overlap or are directly adjacent to one another.
+// Positions are expected to be ordered by line, as matchers produce them.
+func makeHunks(positions []*FilePosition, lines []string, before uint, after uint) []*Hunk {
+	if len(positions) == 0 || len(lines) == 0 {
+		return nil
+	}
+
+	lastLine := uint(len(lines) - 1)
+	hunks := []*Hunk{}
+	var start, end uint
+	open := false
+
+	flush := func() {
+		h := &Hunk{}
+		for n := start; n <= end; n++ {
+			h.Lines = append(h.Lines, &Line{Number: n, Text: lines[n]})
+		}
+		hunks = append(hunks, h)
+	}
+
+	for _, p := range positions {
+		windowStart := uint(0)
+		if p.Line > before {
+			windowStart = p.Line - before
+		}
+		windowEnd := p.LineEnd + after
+		if windowEnd > lastLine {
+			windowEnd = lastLine
+		}
+		if windowStart > lastLine {
+			continue
+		}
+
+		switch {
+		case !open:
+			start, end = windowStart, windowEnd
+			open = true
+		case windowStart <= end+1:
+			if windowEnd > end {
+				end = windowEnd
+			}
+		default:
+			flush()
+			start, end = windowStart, windowEnd
+		}
+	}
+
+	if open {
+		flush()
+	}
+
+	return hunks
+}
+
+// splitLines divides text into lines in the same way as bufio.ScanLines,
+// but without any limit on line length.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	lines := strings.Split(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for idx, l := range lines {
+		lines[idx] = strings.TrimSuffix(l, "\r")
+	}
+
+	return lines
+}
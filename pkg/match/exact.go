@@ -2,28 +2,43 @@ package match
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
+	"io"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/rs/zerolog"
 
 	"github.com/agrski/greg/pkg/types"
 )
 
+const (
+	// maxLineLength is the longest line held whole; longer lines are searched in chunks of this size.
+	maxLineLength = 64 * 1024
+	// excerptLength is how much of a long line to keep either side of a match.
+	excerptLength = 80
+)
+
 type exactMatcher struct {
 	caseInsensitive bool
-	logger          zerolog.Logger
+	// maxCount stops matching once this many positions are found, unless zero.
+	maxCount uint
+	logger   zerolog.Logger
 }
 
 var _ Matcher = (*exactMatcher)(nil)
 
-func newExactMatcher(logger zerolog.Logger, caseInsensitive bool) *exactMatcher {
+func newExactMatcher(logger zerolog.Logger, caseInsensitive bool, maxCount uint) *exactMatcher {
 	logger = logger.With().Str("source", "ExactMatcher").Logger()
 	return &exactMatcher{
 		caseInsensitive: caseInsensitive,
+		maxCount:        maxCount,
 		logger:          logger,
 	}
 }
 
+// Match streams the file a line at a time, so only long lines are ever held in part.
 func (em *exactMatcher) Match(pattern string, next *types.FileInfo) (*Match, bool) {
 	logger := em.logger.With().Str("func", "Match").Logger()
 
@@ -32,29 +47,33 @@ func (em *exactMatcher) Match(pattern string, next *types.FileInfo) (*Match, boo
 		return nil, false
 	}
 
+	if pattern == "" {
+		return nil, false
+	}
+
 	match := &Match{}
-	lineReader := bufio.NewScanner(
-		strings.NewReader(next.Text),
-	)
-
-	for row := 0; lineReader.Scan(); row++ {
-		line := lineReader.Text()
-		matchColumns := em.matchLine(pattern, line)
-		for _, column := range matchColumns {
-			match.Positions = append(
-				match.Positions,
-				&FilePosition{
-					Line:        uint(row),
-					ColumnStart: column,
-					ColumnEnd:   column + uint(len(pattern)),
-					Text:        line,
-				},
-			)
-		}
+	bufferSize := maxLineLength
+	if next.Body == nil && len(next.Text) < bufferSize {
+		// No line can be longer than text already held in memory
+		bufferSize = len(next.Text) + 1
 	}
+	lineReader := bufio.NewReaderSize(next.Reader(), bufferSize)
 
-	if err := lineReader.Err(); err != nil {
-		return nil, false
+	for row := uint(0); ; row++ {
+		positions, err := em.matchNextLine(pattern, lineReader, row)
+		match.Positions = append(match.Positions, positions...)
+
+		if em.maxCount > 0 && uint(len(match.Positions)) >= em.maxCount {
+			match.Positions = match.Positions[:em.maxCount]
+			break
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			logger.Error().Err(err).Str("filename", next.Path).Msg("unable to read file")
+			return nil, false
+		}
 	}
 
 	if len(match.Positions) == 0 {
@@ -64,6 +83,131 @@ func (em *exactMatcher) Match(pattern string, next *types.FileInfo) (*Match, boo
 	return match, true
 }
 
+// matchNextLine reads and searches a line, returning io.EOF once the file has been read.
+func (em *exactMatcher) matchNextLine(
+	pattern string,
+	lineReader *bufio.Reader,
+	row uint,
+) ([]*FilePosition, error) {
+	chunk, err := lineReader.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return em.matchLongLine(pattern, lineReader, row, chunk)
+	}
+
+	line := string(trimLineEnding(chunk))
+
+	positions := []*FilePosition{}
+	for _, column := range em.matchLine(pattern, line) {
+		positions = append(
+			positions,
+			&FilePosition{
+				Line:        row,
+				LineEnd:     row,
+				ColumnStart: column,
+				ColumnEnd:   column + uint(len(pattern)),
+				Text:        line,
+			},
+		)
+	}
+
+	return positions, err
+}
+
+// matchLongLine searches a line too long to buffer, one chunk at a time.
+// The end of each chunk is kept to find matches crossing into the next one,
+// and each position holds an excerpt of the line around its match.
+func (em *exactMatcher) matchLongLine(
+	pattern string,
+	lineReader *bufio.Reader,
+	row uint,
+	chunk []byte,
+) ([]*FilePosition, error) {
+	positions := []*FilePosition{}
+	window := append([]byte{}, chunk...)
+	var windowColumn, windowCharacters uint
+	// Text before searchFrom was searched along with the previous chunk and is only kept for excerpts
+	searchFrom := 0
+
+	for {
+		next, err := lineReader.ReadSlice('\n')
+		isLastChunk := !errors.Is(err, bufio.ErrBufferFull)
+		if isLastChunk && len(next) > 0 {
+			window = append(window, next...)
+		}
+		if isLastChunk {
+			window = trimLineEnding(window)
+		}
+
+		searchedUntil := searchFrom
+		for _, column := range em.matchLine(pattern, string(window[searchFrom:])) {
+			column += uint(searchFrom)
+			positions = append(positions, makeExcerpt(window, windowColumn, windowCharacters, column, uint(len(pattern)), row))
+			searchedUntil = int(column) + len(pattern)
+		}
+
+		if isLastChunk {
+			return positions, err
+		}
+
+		// Too little is searched again to hold a match by itself, so none are found twice
+		nextSearchFrom := len(window) - len(pattern) + 1
+		if nextSearchFrom < searchedUntil {
+			nextSearchFrom = searchedUntil
+		}
+		keepFrom := nextSearchFrom - excerptLength
+		if keepFrom < 0 {
+			keepFrom = 0
+		}
+		// Only whole characters are dropped, so they can be counted
+		for keepFrom > 0 && !utf8.RuneStart(window[keepFrom]) {
+			keepFrom--
+		}
+
+		windowColumn += uint(keepFrom)
+		windowCharacters += uint(utf8.RuneCount(window[:keepFrom]))
+		searchFrom = nextSearchFrom - keepFrom
+		window = append(append([]byte{}, window[keepFrom:]...), next...)
+	}
+}
+
+// makeExcerpt builds a position for a match in a window onto a long line,
+// keeping only a little of the line either side of the match.
+// The window starts at a byte offset and a number of characters into the line.
+func makeExcerpt(window []byte, windowColumn uint, windowCharacters uint, column uint, length uint, row uint) *FilePosition {
+	start := 0
+	if int(column) > excerptLength {
+		start = int(column) - excerptLength
+	}
+	end := int(column+length) + excerptLength
+	if end > len(window) {
+		end = len(window)
+	}
+
+	// Avoid splitting multi-byte characters
+	for start > 0 && !utf8.RuneStart(window[start]) {
+		start--
+	}
+	for end < len(window) && !utf8.RuneStart(window[end]) {
+		end++
+	}
+
+	return &FilePosition{
+		Line:           row,
+		LineEnd:        row,
+		ColumnStart:    windowColumn + column,
+		ColumnEnd:      windowColumn + column + length,
+		Text:           string(window[start:end]),
+		TextColumn:     windowColumn + uint(start),
+		TextCharacters: windowCharacters + uint(utf8.RuneCount(window[:start])),
+	}
+}
+
+// trimLineEnding removes a trailing line break, including any carriage return.
+func trimLineEnding(line []byte) []byte {
+	line = bytes.TrimSuffix(line, []byte{'\n'})
+	return bytes.TrimSuffix(line, []byte{'\r'})
+}
+
 func (em *exactMatcher) matchLine(pattern string, line string) []uint {
 	if em.caseInsensitive {
 		pattern = strings.ToLower(pattern)
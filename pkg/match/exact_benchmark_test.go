@@ -48,7 +48,7 @@ func makeTextOfLength(n int) string {
 }
 
 func benchmarkExactMatcher(b *testing.B, patternSize int, textSize int, caseInsensitive bool) {
-	matcher := newExactMatcher(zerolog.Nop(), caseInsensitive)
+	matcher := newExactMatcher(zerolog.Nop(), caseInsensitive, 0)
 	pattern := makeTextOfLength(patternSize)
 	fileInfo := &types.FileInfo{}
 	fileInfo.IsBinary = false
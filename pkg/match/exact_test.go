@@ -1,7 +1,9 @@
 package match
 
 import (
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
@@ -67,6 +69,7 @@ func TestMatch(t *testing.T) {
 				Positions: []*FilePosition{
 					{
 						Line:        0,
+						LineEnd:     0,
 						ColumnStart: 4,
 						ColumnEnd:   7,
 						Text:        "foo bar baz",
@@ -90,6 +93,7 @@ foo
 				Positions: []*FilePosition{
 					{
 						Line:        4,
+						LineEnd:     4,
 						ColumnStart: 0,
 						ColumnEnd:   3,
 						Text:        "foo",
@@ -113,12 +117,14 @@ foo fifth
 				Positions: []*FilePosition{
 					{
 						Line:        1,
+						LineEnd:     1,
 						ColumnStart: 7,
 						ColumnEnd:   10,
 						Text:        "second foo",
 					},
 					{
 						Line:        4,
+						LineEnd:     4,
 						ColumnStart: 0,
 						ColumnEnd:   3,
 						Text:        "foo fifth",
@@ -137,12 +143,14 @@ foo fifth
 				Positions: []*FilePosition{
 					{
 						Line:        0,
+						LineEnd:     0,
 						ColumnStart: 0,
 						ColumnEnd:   3,
 						Text:        "foo bar foo",
 					},
 					{
 						Line:        0,
+						LineEnd:     0,
 						ColumnStart: 8,
 						ColumnEnd:   11,
 						Text:        "foo bar foo",
@@ -161,6 +169,7 @@ foo fifth
 				Positions: []*FilePosition{
 					{
 						Line:        0,
+						LineEnd:     0,
 						ColumnStart: 6,
 						ColumnEnd:   11,
 						Text:        "HELLO WORLD",
@@ -179,6 +188,7 @@ foo fifth
 				Positions: []*FilePosition{
 					{
 						Line:        0,
+						LineEnd:     0,
 						ColumnStart: 6,
 						ColumnEnd:   11,
 						Text:        "hello world",
@@ -187,6 +197,25 @@ foo fifth
 			},
 			expectedOk: true,
 		},
+		{
+			name:              "should strip carriage returns from line endings",
+			isBinary:          false,
+			isCaseInsensitive: false,
+			text:              "hello\r\nworld\r\n",
+			pattern:           "world",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{
+						Line:        1,
+						LineEnd:     1,
+						ColumnStart: 0,
+						ColumnEnd:   5,
+						Text:        "world",
+					},
+				},
+			},
+			expectedOk: true,
+		},
 		{
 			name:              "should accept mixed-case pattern, mixed-case text when case-insensitive",
 			isBinary:          false,
@@ -197,6 +226,7 @@ foo fifth
 				Positions: []*FilePosition{
 					{
 						Line:        0,
+						LineEnd:     0,
 						ColumnStart: 6,
 						ColumnEnd:   11,
 						Text:        "Hello wOrLd",
@@ -213,7 +243,7 @@ foo fifth
 			fileInfo.IsBinary = tt.isBinary
 			fileInfo.Text = tt.text
 
-			matcher := newExactMatcher(zerolog.Nop(), tt.isCaseInsensitive)
+			matcher := newExactMatcher(zerolog.Nop(), tt.isCaseInsensitive, 0)
 
 			actual, ok := matcher.Match(tt.pattern, fileInfo)
 
@@ -222,3 +252,113 @@ foo fifth
 		})
 	}
 }
+
+func TestMatchLongLinesCountsCharacters(t *testing.T) {
+	// Each character is two bytes, so excerpts start at fewer characters than bytes into the line
+	text := strings.Repeat("é", maxLineLength) + "needle" + strings.Repeat("é", maxLineLength)
+	fileInfo := &types.FileInfo{Body: strings.NewReader(text)}
+	matcher := newExactMatcher(zerolog.Nop(), false, 0)
+
+	actual, ok := matcher.Match("needle", fileInfo)
+
+	require.True(t, ok)
+	require.Len(t, actual.Positions, 1)
+	p := actual.Positions[0]
+	start, _ := p.Span()
+	require.Equal(t, uint(2*maxLineLength), p.ColumnStart)
+	require.Equal(t, uint(maxLineLength), p.TextCharacters+uint(utf8.RuneCountInString(p.Text[:start])))
+}
+
+func TestMatchLongLines(t *testing.T) {
+	type test struct {
+		name              string
+		text              string
+		pattern           string
+		expectedPositions []*FilePosition
+	}
+
+	padding := strings.Repeat("x", maxLineLength)
+
+	tests := []test{
+		{
+			name:    "match crossing chunk boundary",
+			text:    "first\n" + padding[:maxLineLength-2] + "needle" + padding + "\nlast needle",
+			pattern: "needle",
+			expectedPositions: []*FilePosition{
+				{
+					Line:           1,
+					LineEnd:        1,
+					ColumnStart:    maxLineLength - 2,
+					ColumnEnd:      maxLineLength + 4,
+					Text:           padding[:excerptLength] + "needle" + padding[:excerptLength],
+					TextColumn:     maxLineLength - 2 - excerptLength,
+					TextCharacters: maxLineLength - 2 - excerptLength,
+				},
+				{
+					Line:        2,
+					LineEnd:     2,
+					ColumnStart: 5,
+					ColumnEnd:   11,
+					Text:        "last needle",
+				},
+			},
+		},
+		{
+			name:    "overlapping matches are not repeated across chunks",
+			text:    padding[:maxLineLength-1] + "aaa",
+			pattern: "aa",
+			expectedPositions: []*FilePosition{
+				{
+					Line:           0,
+					LineEnd:        0,
+					ColumnStart:    maxLineLength - 1,
+					ColumnEnd:      maxLineLength + 1,
+					Text:           padding[:excerptLength] + "aaa",
+					TextColumn:     maxLineLength - 1 - excerptLength,
+					TextCharacters: maxLineLength - 1 - excerptLength,
+				},
+			},
+		},
+		{
+			name:    "matches in several chunks of one line",
+			text:    "needle" + padding + padding + "needle\r\n",
+			pattern: "needle",
+			expectedPositions: []*FilePosition{
+				{
+					Line:        0,
+					LineEnd:     0,
+					ColumnStart: 0,
+					ColumnEnd:   6,
+					Text:        "needle" + padding[:excerptLength],
+				},
+				{
+					Line:           0,
+					LineEnd:        0,
+					ColumnStart:    2*maxLineLength + 6,
+					ColumnEnd:      2*maxLineLength + 12,
+					Text:           padding[:excerptLength] + "needle",
+					TextColumn:     2*maxLineLength + 6 - excerptLength,
+					TextCharacters: 2*maxLineLength + 6 - excerptLength,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileInfo := &types.FileInfo{
+				Body: strings.NewReader(tt.text),
+			}
+			matcher := newExactMatcher(zerolog.Nop(), false, 0)
+
+			actual, ok := matcher.Match(tt.pattern, fileInfo)
+
+			require.True(t, ok)
+			require.Equal(t, tt.expectedPositions, actual.Positions)
+			for _, p := range actual.Positions {
+				start, end := p.Span()
+				require.Equal(t, tt.pattern, p.Text[start:end])
+			}
+		})
+	}
+}
@@ -1,19 +1,190 @@
 package match
 
 import (
+	"fmt"
+	"path"
 	"strings"
 
 	"github.com/agrski/greg/pkg/types"
 )
 
-func FilterFiletype(allowed []types.FileExtension, next *types.FileInfo) bool {
+// FileType groups the files belonging to a language or tool under a single name.
+type FileType struct {
+	// Extensions are without a leading dot and may contain several parts, e.g. tar.gz or d.ts.
+	Extensions []types.FileExtension
+	// Basenames are exact file names, e.g. Dockerfile.
+	Basenames []string
+	// Globs are matched against file names, e.g. Dockerfile.*
+	Globs []string
+}
+
+// FileTypes maps names, as given to the CLI, to file-type definitions.
+type FileTypes map[string]*FileType
+
+var builtinFileTypes = FileTypes{
+	"archive": {
+		Extensions: []types.FileExtension{
+			"tar", "tar.gz", "tar.bz2", "tar.xz", "tar.zst", "tgz", "zip", "jar", "gz", "bz2", "xz", "zst",
+		},
+	},
+	"c":      {Extensions: []types.FileExtension{"c", "h"}},
+	"cpp":    {Extensions: []types.FileExtension{"cpp", "cc", "cxx", "c++", "hpp", "hh", "hxx", "h++", "h"}},
+	"csharp": {Extensions: []types.FileExtension{"cs"}},
+	"css":    {Extensions: []types.FileExtension{"css", "scss", "sass", "less"}},
+	"docker": {
+		Extensions: []types.FileExtension{"dockerfile"},
+		Basenames:  []string{"Dockerfile", "Containerfile", ".dockerignore"},
+		Globs:      []string{"Dockerfile.*", "*.Dockerfile", "docker-compose*.yml", "docker-compose*.yaml"},
+	},
+	"go":   {Extensions: []types.FileExtension{"go"}},
+	"html": {Extensions: []types.FileExtension{"html", "htm", "xhtml"}},
+	"java": {Extensions: []types.FileExtension{"java", "jsp"}},
+	"js":   {Extensions: []types.FileExtension{"js", "jsx", "mjs", "cjs", "vue"}},
+	"json": {Extensions: []types.FileExtension{"json", "jsonl", "geojson"}},
+	"make": {
+		Extensions: []types.FileExtension{"mk", "mak"},
+		Basenames:  []string{"Makefile", "makefile", "GNUmakefile"},
+		Globs:      []string{"Makefile.*", "makefile.*"},
+	},
+	"markdown": {Extensions: []types.FileExtension{"md", "markdown", "mdown", "mkd"}},
+	"proto":    {Extensions: []types.FileExtension{"proto"}},
+	"py": {
+		Extensions: []types.FileExtension{"py", "pyi", "pyw"},
+		Basenames:  []string{"SConstruct", "SConscript"},
+	},
+	"ruby": {
+		Extensions: []types.FileExtension{"rb", "gemspec", "rake"},
+		Basenames:  []string{"Gemfile", "Rakefile", "Vagrantfile"},
+	},
+	"rust": {Extensions: []types.FileExtension{"rs"}},
+	"shell": {
+		Extensions: []types.FileExtension{"sh", "bash", "zsh", "ksh", "csh", "fish"},
+		Basenames: []string{
+			".bashrc", ".bash_profile", ".bash_aliases", ".bash_logout", ".profile", ".zshrc", ".zshenv", ".zprofile",
+		},
+	},
+	"sql":  {Extensions: []types.FileExtension{"sql"}},
+	"toml": {Extensions: []types.FileExtension{"toml"}, Basenames: []string{"Cargo.lock", "Pipfile"}},
+	"ts":   {Extensions: []types.FileExtension{"ts", "tsx", "mts", "cts"}},
+	"txt":  {Extensions: []types.FileExtension{"txt"}},
+	"xml":  {Extensions: []types.FileExtension{"xml", "xsd", "xsl", "xslt", "svg"}},
+	"yaml": {Extensions: []types.FileExtension{"yaml", "yml"}},
+}
+
+func init() {
+	// Aliases for convenience and familiarity
+	builtinFileTypes["python"] = builtinFileTypes["py"]
+	builtinFileTypes["sh"] = builtinFileTypes["shell"]
+	builtinFileTypes["dockerfile"] = builtinFileTypes["docker"]
+	builtinFileTypes["makefile"] = builtinFileTypes["make"]
+	builtinFileTypes["javascript"] = builtinFileTypes["js"]
+	builtinFileTypes["typescript"] = builtinFileTypes["ts"]
+	builtinFileTypes["yml"] = builtinFileTypes["yaml"]
+}
+
+// DefaultFileTypes returns a copy of the built-in file types, which may be safely extended.
+// Aliases share their copy, so extending one name extends all its aliases.
+func DefaultFileTypes() FileTypes {
+	fileTypes := make(FileTypes, len(builtinFileTypes))
+	copies := make(map[*FileType]*FileType, len(builtinFileTypes))
+	for name, ft := range builtinFileTypes {
+		c, ok := copies[ft]
+		if !ok {
+			c = &FileType{
+				Extensions: append([]types.FileExtension{}, ft.Extensions...),
+				Basenames:  append([]string{}, ft.Basenames...),
+				Globs:      append([]string{}, ft.Globs...),
+			}
+			copies[ft] = c
+		}
+		fileTypes[name] = c
+	}
+
+	return fileTypes
+}
+
+// Add extends the file types with a definition of the form name:spec[,spec...].
+// Each spec is either a glob, such as *.tar.gz, or an exact file name, such as Jenkinsfile.
+// Specs for an existing name are added to those already defined.
+func (fts FileTypes) Add(definition string) error {
+	name, specs, ok := strings.Cut(definition, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.TrimSpace(specs) == "" {
+		return fmt.Errorf("file type definition '%s' must be of the form name:glob[,glob...]", definition)
+	}
+
+	ft, ok := fts[name]
+	if !ok {
+		ft = &FileType{}
+		fts[name] = ft
+	}
+
+	for _, s := range strings.Split(specs, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		if strings.ContainsAny(s, "*?[") {
+			if _, err := path.Match(s, ""); err != nil {
+				return fmt.Errorf("invalid glob '%s' for file type %s", s, name)
+			}
+			ft.Globs = append(ft.Globs, s)
+		} else {
+			ft.Basenames = append(ft.Basenames, s)
+		}
+	}
+
+	return nil
+}
+
+// Filter reports whether a file belongs to any of the allowed types.
+// Names which are not defined file types are treated as raw file extensions.
+func (fts FileTypes) Filter(allowed []types.FileExtension, next *types.FileInfo) bool {
 	if len(allowed) == 0 {
 		return true
 	}
 
-	normalised := NormaliseExtension(next.Extension)
 	for _, a := range allowed {
-		if normalised == a {
+		ft, ok := fts[string(a)]
+		if !ok {
+			ft = &FileType{Extensions: []types.FileExtension{a}}
+		}
+
+		if ft.Matches(next) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (ft *FileType) Matches(next *types.FileInfo) bool {
+	extension := next.Extension
+	if extension == "" {
+		extension = types.FileExtension(path.Ext(next.Path))
+	}
+	normalised := NormaliseExtension(extension)
+	basename := path.Base(next.Path)
+
+	for _, e := range ft.Extensions {
+		if normalised == e {
+			return true
+		}
+		// Multi-part extensions are not reflected in the final extension alone
+		if strings.Contains(string(e), ".") && strings.HasSuffix(basename, "."+string(e)) {
+			return true
+		}
+	}
+
+	for _, b := range ft.Basenames {
+		if basename == b {
+			return true
+		}
+	}
+
+	for _, g := range ft.Globs {
+		if ok, err := path.Match(g, basename); err == nil && ok {
 			return true
 		}
 	}
@@ -21,6 +192,11 @@ func FilterFiletype(allowed []types.FileExtension, next *types.FileInfo) bool {
 	return false
 }
 
+// FilterFiletype reports whether a file belongs to any of the allowed built-in types or extensions.
+func FilterFiletype(allowed []types.FileExtension, next *types.FileInfo) bool {
+	return builtinFileTypes.Filter(allowed, next)
+}
+
 func NormaliseExtension(ext types.FileExtension) types.FileExtension {
 	trimmed := strings.TrimSpace(string(ext))
 	withoutDot := strings.TrimPrefix(trimmed, ".")
@@ -121,3 +121,217 @@ func TestFilterFiletype(t *testing.T) {
 		})
 	}
 }
+
+func TestFileTypesFilter(t *testing.T) {
+	type test struct {
+		name      string
+		allowed   []types.FileExtension
+		path      string
+		extension types.FileExtension
+		expected  bool
+	}
+
+	tests := []test{
+		{
+			name:      "named type matches by extension",
+			allowed:   []types.FileExtension{"go"},
+			path:      "cmd/cli/main.go",
+			extension: ".go",
+			expected:  true,
+		},
+		{
+			name:      "named type matches any of its extensions",
+			allowed:   []types.FileExtension{"js"},
+			path:      "web/index.mjs",
+			extension: ".mjs",
+			expected:  true,
+		},
+		{
+			name:      "named type matches exact basename without extension",
+			allowed:   []types.FileExtension{"docker"},
+			path:      "build/Dockerfile",
+			extension: "",
+			expected:  true,
+		},
+		{
+			name:      "named type matches glob",
+			allowed:   []types.FileExtension{"docker"},
+			path:      "build/Dockerfile.dev",
+			extension: ".dev",
+			expected:  true,
+		},
+		{
+			name:      "named type matches dotfile basename",
+			allowed:   []types.FileExtension{"shell"},
+			path:      "home/.bashrc",
+			extension: ".bashrc",
+			expected:  true,
+		},
+		{
+			name:      "named type matches multi-part extension",
+			allowed:   []types.FileExtension{"archive"},
+			path:      "fixtures/data.tar.gz",
+			extension: ".gz",
+			expected:  true,
+		},
+		{
+			name:      "alias matches same files as original name",
+			allowed:   []types.FileExtension{"makefile"},
+			path:      "Makefile",
+			extension: "",
+			expected:  true,
+		},
+		{
+			name:      "named type does not match other files",
+			allowed:   []types.FileExtension{"make"},
+			path:      "cmd/cli/main.go",
+			extension: ".go",
+			expected:  false,
+		},
+		{
+			name:      "basename must match exactly",
+			allowed:   []types.FileExtension{"docker"},
+			path:      "docs/Dockerfile-notes.md",
+			extension: ".md",
+			expected:  false,
+		},
+		{
+			name:      "unknown name is treated as raw extension",
+			allowed:   []types.FileExtension{"tf"},
+			path:      "infra/main.tf",
+			extension: ".tf",
+			expected:  true,
+		},
+		{
+			name:      "unknown multi-part name is treated as raw extension",
+			allowed:   []types.FileExtension{"d.ts"},
+			path:      "types/index.d.ts",
+			extension: ".ts",
+			expected:  true,
+		},
+		{
+			name:      "multi-part extension does not match plain final extension",
+			allowed:   []types.FileExtension{"d.ts"},
+			path:      "src/index.ts",
+			extension: ".ts",
+			expected:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileInfo := &types.FileInfo{}
+			fileInfo.Path = tt.path
+			fileInfo.Extension = tt.extension
+
+			actual := DefaultFileTypes().Filter(tt.allowed, fileInfo)
+
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestFileTypesAdd(t *testing.T) {
+	type test struct {
+		name       string
+		definition string
+		fileType   string
+		path       string
+		expected   bool
+		wantErr    bool
+	}
+
+	tests := []test{
+		{
+			name:       "new type with glob",
+			definition: "groovy:*.groovy",
+			fileType:   "groovy",
+			path:       "ci/build.groovy",
+			expected:   true,
+		},
+		{
+			name:       "new type with several specs",
+			definition: "jenkins:Jenkinsfile, *.jenkinsfile",
+			fileType:   "jenkins",
+			path:       "Jenkinsfile",
+			expected:   true,
+		},
+		{
+			name:       "existing type is extended",
+			definition: "go:go.mod",
+			fileType:   "go",
+			path:       "go.mod",
+			expected:   true,
+		},
+		{
+			name:       "existing type keeps original definition",
+			definition: "go:go.mod",
+			fileType:   "go",
+			path:       "main.go",
+			expected:   true,
+		},
+		{
+			name:       "missing separator fails",
+			definition: "groovy",
+			wantErr:    true,
+		},
+		{
+			name:       "missing name fails",
+			definition: ":*.groovy",
+			wantErr:    true,
+		},
+		{
+			name:       "missing specs fails",
+			definition: "groovy: ",
+			wantErr:    true,
+		},
+		{
+			name:       "invalid glob fails",
+			definition: "broken:[a-",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileTypes := DefaultFileTypes()
+
+			err := fileTypes.Add(tt.definition)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			fileInfo := &types.FileInfo{Path: tt.path}
+			actual := fileTypes.Filter([]types.FileExtension{types.FileExtension(tt.fileType)}, fileInfo)
+
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestDefaultFileTypesAreIndependentCopies(t *testing.T) {
+	fileTypes := DefaultFileTypes()
+	require.NoError(t, fileTypes.Add("go:*.tmpl"))
+
+	fileInfo := &types.FileInfo{Path: "page.tmpl", Extension: ".tmpl"}
+
+	require.True(t, fileTypes.Filter([]types.FileExtension{"go"}, fileInfo))
+	require.False(t, DefaultFileTypes().Filter([]types.FileExtension{"go"}, fileInfo))
+	require.False(t, FilterFiletype([]types.FileExtension{"go"}, fileInfo))
+}
+
+func TestDefaultFileTypesKeepAliases(t *testing.T) {
+	fileTypes := DefaultFileTypes()
+	require.NoError(t, fileTypes.Add("python:*.pyx"))
+	require.NoError(t, fileTypes.Add("sh:*.envrc"))
+
+	pyx := &types.FileInfo{Path: "fast.pyx", Extension: ".pyx"}
+	envrc := &types.FileInfo{Path: "dev.envrc", Extension: ".envrc"}
+
+	require.True(t, fileTypes.Filter([]types.FileExtension{"py"}, pyx))
+	require.True(t, fileTypes.Filter([]types.FileExtension{"python"}, pyx))
+	require.True(t, fileTypes.Filter([]types.FileExtension{"shell"}, envrc))
+	require.False(t, DefaultFileTypes().Filter([]types.FileExtension{"python"}, pyx))
+}
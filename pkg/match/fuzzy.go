@@ -0,0 +1,199 @@
+package match
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+
+	"github.com/agrski/greg/pkg/types"
+)
+
+type fuzzyMatcher struct {
+	caseInsensitive bool
+	maxDistance     uint
+	logger          zerolog.Logger
+}
+
+var _ Matcher = (*fuzzyMatcher)(nil)
+
+func newFuzzyMatcher(logger zerolog.Logger, caseInsensitive bool, maxDistance uint) *fuzzyMatcher {
+	logger = logger.With().Str("source", "FuzzyMatcher").Logger()
+
+	return &fuzzyMatcher{
+		caseInsensitive: caseInsensitive,
+		maxDistance:     maxDistance,
+		logger:          logger,
+	}
+}
+
+// Match finds substrings of each line within the maximum Levenshtein distance of the pattern.
+func (fm *fuzzyMatcher) Match(pattern string, next *types.FileInfo) (*Match, bool) {
+	logger := fm.logger.With().Str("func", "Match").Logger()
+
+	if next.IsBinary {
+		logger.Debug().Str("filename", next.Path).Msg("rejecting binary file")
+		return nil, false
+	}
+
+	if err := CheckFuzzyDistance(pattern, fm.maxDistance); err != nil {
+		logger.Error().Err(err).Send()
+		return nil, false
+	}
+
+	patternRunes := fm.normalise(pattern)
+
+	text, err := next.Contents()
+	if err != nil {
+		logger.Error().Err(err).Str("filename", next.Path).Msg("unable to read file")
+		return nil, false
+	}
+
+	match := &Match{}
+
+	for row, line := range splitLines(text) {
+		for _, s := range fm.matchLine(patternRunes, line) {
+			match.Positions = append(
+				match.Positions,
+				&FilePosition{
+					Line:        uint(row),
+					LineEnd:     uint(row),
+					ColumnStart: s.start,
+					ColumnEnd:   s.end,
+					Text:        line,
+					Distance:    s.distance,
+				},
+			)
+		}
+	}
+
+	if len(match.Positions) == 0 {
+		return nil, false
+	}
+
+	return match, true
+}
+
+func (fm *fuzzyMatcher) normalise(s string) []rune {
+	runes := []rune(s)
+	if fm.caseInsensitive {
+		for idx, r := range runes {
+			runes[idx] = unicode.ToLower(r)
+		}
+	}
+
+	return runes
+}
+
+type fuzzySpan struct {
+	start    uint
+	end      uint
+	distance uint
+}
+
+// matchLine applies Sellers' algorithm, a variant of the Levenshtein dynamic programme
+// in which a match may begin at any point in the text, and returns non-overlapping
+// spans with the lowest distances, as byte offsets into the line.
+func (fm *fuzzyMatcher) matchLine(pattern []rune, line string) []fuzzySpan {
+	text := fm.normalise(line)
+
+	// Byte offset of each rune in the original line, plus the end of the line
+	offsets := make([]uint, 0, len(text)+1)
+	for idx := range line {
+		offsets = append(offsets, uint(idx))
+	}
+	offsets = append(offsets, uint(len(line)))
+
+	m := len(pattern)
+	maxDistance := int(fm.maxDistance)
+
+	// Costs and alignment starts for the previous and current text positions
+	previousCost := make([]int, m+1)
+	currentCost := make([]int, m+1)
+	previousStart := make([]int, m+1)
+	currentStart := make([]int, m+1)
+
+	for i := 0; i <= m; i++ {
+		previousCost[i] = i
+	}
+
+	spans := []fuzzySpan{}
+	var best *fuzzySpan
+	var lastEnd uint
+
+	emit := func() {
+		if best != nil {
+			spans = append(spans, *best)
+			lastEnd = best.end
+			best = nil
+		}
+	}
+
+	for j := 1; j <= len(text); j++ {
+		currentCost[0] = 0
+		currentStart[0] = j
+
+		for i := 1; i <= m; i++ {
+			substitution := previousCost[i-1]
+			if pattern[i-1] != text[j-1] {
+				substitution++
+			}
+			insertion := previousCost[i] + 1
+			deletion := currentCost[i-1] + 1
+
+			switch {
+			case substitution <= insertion && substitution <= deletion:
+				currentCost[i] = substitution
+				currentStart[i] = previousStart[i-1]
+			case deletion <= insertion:
+				currentCost[i] = deletion
+				currentStart[i] = currentStart[i-1]
+			default:
+				currentCost[i] = insertion
+				currentStart[i] = previousStart[i]
+			}
+		}
+
+		if cost := currentCost[m]; cost <= maxDistance && currentStart[m] < j {
+			candidate := fuzzySpan{
+				start:    offsets[currentStart[m]],
+				end:      offsets[j],
+				distance: uint(cost),
+			}
+
+			switch {
+			case len(spans) > 0 && candidate.start < lastEnd:
+				// Overlaps a span which has already been reported
+			case best == nil:
+				best = &candidate
+			case candidate.start < best.end:
+				// Prefer closer matches, then longer ones, so that trailing characters are not omitted
+				if candidate.distance < best.distance ||
+					(candidate.distance == best.distance && candidate.start == best.start) {
+					best = &candidate
+				}
+			default:
+				emit()
+				best = &candidate
+			}
+		}
+
+		previousCost, currentCost = currentCost, previousCost
+		previousStart, currentStart = currentStart, previousStart
+	}
+
+	emit()
+
+	return spans
+}
+
+// CheckFuzzyDistance ensures a distance is small enough to be meaningful for a pattern,
+// as any text is within the pattern's length in edits of it.
+func CheckFuzzyDistance(pattern string, distance uint) error {
+	if length := uint(utf8.RuneCountInString(pattern)); distance >= length {
+		return fmt.Errorf("fuzzy distance %d must be less than the pattern length of %d", distance, length)
+	}
+
+	return nil
+}
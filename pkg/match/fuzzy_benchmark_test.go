@@ -0,0 +1,70 @@
+package match
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/agrski/greg/pkg/types"
+)
+
+func benchmarkFuzzyMatcher(b *testing.B, patternSize int, textSize int, maxDistance uint, caseInsensitive bool) {
+	matcher := newFuzzyMatcher(zerolog.Nop(), caseInsensitive, maxDistance)
+	pattern := makeTextOfLength(patternSize)
+	fileInfo := &types.FileInfo{}
+	fileInfo.IsBinary = false
+	fileInfo.Text = makeTextOfLength(textSize)
+
+	for i := 0; i < b.N; i++ {
+		matches, ok := matcher.Match(pattern, fileInfo)
+		if ok {
+			results = len(matches.Positions)
+		}
+	}
+}
+
+func BenchmarkFuzzyMatcher_Pattern10_Text100_Distance1(b *testing.B) {
+	benchmarkFuzzyMatcher(b, 10, 100, 1, false)
+}
+func BenchmarkFuzzyMatcher_Pattern10_Text100_Distance1_CaseInsensitive(b *testing.B) {
+	benchmarkFuzzyMatcher(b, 10, 100, 1, true)
+}
+
+func BenchmarkFuzzyMatcher_Pattern10_Text1_000_Distance1(b *testing.B) {
+	benchmarkFuzzyMatcher(b, 10, 1_000, 1, false)
+}
+func BenchmarkFuzzyMatcher_Pattern10_Text1_000_Distance3(b *testing.B) {
+	benchmarkFuzzyMatcher(b, 10, 1_000, 3, false)
+}
+func BenchmarkFuzzyMatcher_Pattern100_Text1_000_Distance1(b *testing.B) {
+	benchmarkFuzzyMatcher(b, 100, 1_000, 1, false)
+}
+func BenchmarkFuzzyMatcher_Pattern10_Text1_000_Distance1_CaseInsensitive(b *testing.B) {
+	benchmarkFuzzyMatcher(b, 10, 1_000, 1, true)
+}
+
+func BenchmarkFuzzyMatcher_Pattern10_Text10_000_Distance1(b *testing.B) {
+	benchmarkFuzzyMatcher(b, 10, 10_000, 1, false)
+}
+func BenchmarkFuzzyMatcher_Pattern10_Text10_000_Distance3(b *testing.B) {
+	benchmarkFuzzyMatcher(b, 10, 10_000, 3, false)
+}
+func BenchmarkFuzzyMatcher_Pattern100_Text10_000_Distance1(b *testing.B) {
+	benchmarkFuzzyMatcher(b, 100, 10_000, 1, false)
+}
+func BenchmarkFuzzyMatcher_Pattern10_Text10_000_Distance1_CaseInsensitive(b *testing.B) {
+	benchmarkFuzzyMatcher(b, 10, 10_000, 1, true)
+}
+
+func BenchmarkFuzzyMatcher_Pattern10_Text100_000_Distance1(b *testing.B) {
+	benchmarkFuzzyMatcher(b, 10, 100_000, 1, false)
+}
+func BenchmarkFuzzyMatcher_Pattern10_Text100_000_Distance3(b *testing.B) {
+	benchmarkFuzzyMatcher(b, 10, 100_000, 3, false)
+}
+func BenchmarkFuzzyMatcher_Pattern100_Text100_000_Distance1(b *testing.B) {
+	benchmarkFuzzyMatcher(b, 100, 100_000, 1, false)
+}
+func BenchmarkFuzzyMatcher_Pattern10_Text100_000_Distance1_CaseInsensitive(b *testing.B) {
+	benchmarkFuzzyMatcher(b, 10, 100_000, 1, true)
+}
@@ -0,0 +1,165 @@
+package match
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agrski/greg/pkg/types"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	type test struct {
+		name              string
+		isBinary          bool
+		isCaseInsensitive bool
+		maxDistance       uint
+		text              string
+		pattern           string
+		expected          *Match
+		expectedOk        bool
+	}
+
+	tests := []test{
+		{
+			name:        "should ignore binary files",
+			isBinary:    true,
+			maxDistance: 1,
+			text:        "receive",
+			pattern:     "receive",
+			expected:    nil,
+			expectedOk:  false,
+		},
+		{
+			name:        "should reject distance not less than pattern length",
+			maxDistance: 3,
+			text:        "foo",
+			pattern:     "bar",
+			expected:    nil,
+			expectedOk:  false,
+		},
+		{
+			name:        "should reject text beyond maximum distance",
+			maxDistance: 1,
+			text:        "the quick brown fox",
+			pattern:     "receive",
+			expected:    nil,
+			expectedOk:  false,
+		},
+		{
+			name:        "should accept exact match with zero distance",
+			maxDistance: 1,
+			text:        "we receive it",
+			pattern:     "receive",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{Line: 0, LineEnd: 0, ColumnStart: 3, ColumnEnd: 10, Text: "we receive it", Distance: 0},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:        "should accept transposition within distance two",
+			maxDistance: 2,
+			text:        "we recieve it",
+			pattern:     "receive",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{Line: 0, LineEnd: 0, ColumnStart: 3, ColumnEnd: 10, Text: "we recieve it", Distance: 2},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:        "should accept deletion",
+			maxDistance: 1,
+			text:        "pick a color",
+			pattern:     "colour",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{Line: 0, LineEnd: 0, ColumnStart: 7, ColumnEnd: 12, Text: "pick a color", Distance: 1},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:        "should accept insertion",
+			maxDistance: 1,
+			text:        "pick a colour",
+			pattern:     "color",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{Line: 0, LineEnd: 0, ColumnStart: 7, ColumnEnd: 13, Text: "pick a colour", Distance: 1},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:        "should prefer closest of overlapping candidates",
+			maxDistance: 1,
+			text:        "abcd",
+			pattern:     "abc",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{Line: 0, LineEnd: 0, ColumnStart: 0, ColumnEnd: 3, Text: "abcd", Distance: 0},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:        "should accept several matches across lines",
+			maxDistance: 1,
+			text:        "colour\nnothing\ncolor and colr",
+			pattern:     "color",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{Line: 0, LineEnd: 0, ColumnStart: 0, ColumnEnd: 6, Text: "colour", Distance: 1},
+					{Line: 2, LineEnd: 2, ColumnStart: 0, ColumnEnd: 5, Text: "color and colr", Distance: 0},
+					{Line: 2, LineEnd: 2, ColumnStart: 10, ColumnEnd: 14, Text: "color and colr", Distance: 1},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:              "should match case-insensitively",
+			isCaseInsensitive: true,
+			maxDistance:       1,
+			text:              "RECIEVE",
+			pattern:           "recieves",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{Line: 0, LineEnd: 0, ColumnStart: 0, ColumnEnd: 7, Text: "RECIEVE", Distance: 1},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:        "should report byte columns for multi-byte text",
+			maxDistance: 1,
+			text:        "naïve café",
+			pattern:     "cafe",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{Line: 0, LineEnd: 0, ColumnStart: 7, ColumnEnd: 12, Text: "naïve café", Distance: 1},
+				},
+			},
+			expectedOk: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileInfo := &types.FileInfo{}
+			fileInfo.IsBinary = tt.isBinary
+			fileInfo.Text = tt.text
+
+			matcher := newFuzzyMatcher(zerolog.Nop(), tt.isCaseInsensitive, tt.maxDistance)
+
+			actual, ok := matcher.Match(tt.pattern, fileInfo)
+
+			require.Equal(t, tt.expectedOk, ok)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package match
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	gotoken "go/token"
+	gotypes "go/types"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/agrski/greg/pkg/types"
+)
+
+/*
+	Go symbol queries match declarations and expressions in Go source rather than text.
+	Each query has a kind and a value, which may contain glob wildcards:
+
+		func:New*               function and method declarations by name
+		type:*Reader            type declarations by name
+		method:Close() error    methods with the given name and signature, capturing the receiver type
+		import:database/sql     imports of a package path, and references to that package in the file
+		call:http.Get           calls to a function, as written at the call site
+*/
+
+type GoQueryKind string
+
+const (
+	GoQueryFunc   GoQueryKind = "func"
+	GoQueryType   GoQueryKind = "type"
+	GoQueryMethod GoQueryKind = "method"
+	GoQueryImport GoQueryKind = "import"
+	GoQueryCall   GoQueryKind = "call"
+)
+
+const (
+	goExtension     = "go"
+	receiverCapture = "receiver"
+)
+
+// GoQuery is a parsed query over Go declarations and expressions.
+type GoQuery struct {
+	Kind GoQueryKind
+	// Name is a glob over declaration names, import paths, or call expressions.
+	Name string
+	// signature is the normalised method signature, for method queries.
+	signature string
+}
+
+// ParseGoQuery parses a query of the form kind:value.
+func ParseGoQuery(raw string) (*GoQuery, error) {
+	kind, value, ok := strings.Cut(strings.TrimSpace(raw), ":")
+	value = strings.TrimSpace(value)
+	if !ok || value == "" {
+		return nil, fmt.Errorf("go query '%s' must be of the form kind:value", raw)
+	}
+
+	q := &GoQuery{Kind: GoQueryKind(kind), Name: value}
+
+	switch q.Kind {
+	case GoQueryFunc, GoQueryType, GoQueryImport, GoQueryCall:
+	case GoQueryMethod:
+		open := strings.IndexByte(value, '(')
+		if open <= 0 {
+			return nil, fmt.Errorf("method query '%s' must contain a name and signature, e.g. Close() error", raw)
+		}
+
+		expr, err := parser.ParseExpr("func" + value[open:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid method signature in '%s': %w", raw, err)
+		}
+		funcType, ok := expr.(*ast.FuncType)
+		if !ok {
+			return nil, fmt.Errorf("invalid method signature in '%s'", raw)
+		}
+
+		q.Name = strings.TrimSpace(value[:open])
+		q.signature = signatureString(funcType)
+	default:
+		return nil, fmt.Errorf("unknown go query kind '%s'; expected func, type, method, import, or call", kind)
+	}
+
+	if _, err := path.Match(q.Name, ""); err != nil {
+		return nil, fmt.Errorf("invalid glob '%s' in go query", q.Name)
+	}
+
+	return q, nil
+}
+
+func (q *GoQuery) matchesName(name string) bool {
+	ok, err := path.Match(q.Name, name)
+
+	return err == nil && ok
+}
+
+// signatureString renders a function type without parameter names, e.g. (string, int) error.
+func signatureString(ft *ast.FuncType) string {
+	params := fieldTypes(ft.Params)
+	results := fieldTypes(ft.Results)
+
+	signature := "(" + strings.Join(params, ", ") + ")"
+	switch len(results) {
+	case 0:
+		return signature
+	case 1:
+		return signature + " " + results[0]
+	default:
+		return signature + " (" + strings.Join(results, ", ") + ")"
+	}
+}
+
+func fieldTypes(fields *ast.FieldList) []string {
+	if fields == nil {
+		return nil
+	}
+
+	rendered := []string{}
+	for _, f := range fields.List {
+		t := gotypes.ExprString(f.Type)
+
+		count := len(f.Names)
+		if count == 0 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			rendered = append(rendered, t)
+		}
+	}
+
+	return rendered
+}
+
+type goMatcher struct {
+	queries map[string]*GoQuery
+	logger  zerolog.Logger
+}
+
+var _ Matcher = (*goMatcher)(nil)
+
+func newGoMatcher(logger zerolog.Logger) *goMatcher {
+	logger = logger.With().Str("source", "GoMatcher").Logger()
+
+	return &goMatcher{
+		queries: map[string]*GoQuery{},
+		logger:  logger,
+	}
+}
+
+func (gm *goMatcher) Match(pattern string, next *types.FileInfo) (*Match, bool) {
+	logger := gm.logger.With().Str("func", "Match").Logger()
+
+	if next.IsBinary || !isGoFile(next) {
+		return nil, false
+	}
+
+	query, ok := gm.queries[pattern]
+	if !ok {
+		q, err := ParseGoQuery(pattern)
+		if err != nil {
+			logger.Error().Err(err).Str("query", pattern).Msg("unable to parse go query")
+			return nil, false
+		}
+
+		query = q
+		gm.queries[pattern] = query
+	}
+
+	text, err := next.Contents()
+	if err != nil {
+		logger.Error().Err(err).Str("filename", next.Path).Msg("unable to read file")
+		return nil, false
+	}
+
+	fset := gotoken.NewFileSet()
+	file, err := parser.ParseFile(fset, next.Path, text, 0)
+	if err != nil {
+		logger.Debug().Err(err).Str("filename", next.Path).Msg("unable to parse go file")
+		return nil, false
+	}
+
+	finder := &goFinder{
+		query: query,
+		fset:  fset,
+		index: newLineIndex(text),
+	}
+	finder.find(file)
+
+	if len(finder.positions) == 0 {
+		return nil, false
+	}
+
+	return &Match{Positions: sortPositions(finder.positions)}, true
+}
+
+func isGoFile(next *types.FileInfo) bool {
+	extension := next.Extension
+	if extension == "" {
+		extension = types.FileExtension(path.Ext(next.Path))
+	}
+
+	return NormaliseExtension(extension) == goExtension
+}
+
+type goFinder struct {
+	query     *GoQuery
+	fset      *gotoken.FileSet
+	index     *lineIndex
+	positions []*FilePosition
+}
+
+func (gf *goFinder) find(file *ast.File) {
+	switch gf.query.Kind {
+	case GoQueryFunc:
+		for _, d := range file.Decls {
+			if fd, ok := d.(*ast.FuncDecl); ok && gf.query.matchesName(fd.Name.Name) {
+				gf.add(fd.Name, nil)
+			}
+		}
+	case GoQueryMethod:
+		for _, d := range file.Decls {
+			fd, ok := d.(*ast.FuncDecl)
+			if !ok || fd.Recv == nil || len(fd.Recv.List) == 0 {
+				continue
+			}
+
+			if gf.query.matchesName(fd.Name.Name) && signatureString(fd.Type) == gf.query.signature {
+				receiver := gotypes.ExprString(fd.Recv.List[0].Type)
+				gf.add(fd.Name, []Capture{{Name: receiverCapture, Text: receiver}})
+			}
+		}
+	case GoQueryType:
+		ast.Inspect(file, func(n ast.Node) bool {
+			if ts, ok := n.(*ast.TypeSpec); ok && gf.query.matchesName(ts.Name.Name) {
+				gf.add(ts.Name, nil)
+			}
+
+			return true
+		})
+	case GoQueryImport:
+		gf.findImports(file)
+	case GoQueryCall:
+		ast.Inspect(file, func(n ast.Node) bool {
+			if ce, ok := n.(*ast.CallExpr); ok && gf.query.matchesName(gotypes.ExprString(ce.Fun)) {
+				gf.add(ce, nil)
+			}
+
+			return true
+		})
+	}
+}
+
+func (gf *goFinder) findImports(file *ast.File) {
+	localNames := map[string]bool{}
+
+	for _, is := range file.Imports {
+		importPath, err := strconv.Unquote(is.Path.Value)
+		if err != nil || !gf.query.matchesName(importPath) {
+			continue
+		}
+
+		gf.add(is, nil)
+
+		switch {
+		case is.Name == nil:
+			localNames[packageNameFromPath(importPath)] = true
+		case is.Name.Name != "_" && is.Name.Name != ".":
+			localNames[is.Name.Name] = true
+		}
+	}
+
+	if len(localNames) == 0 {
+		return
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		se, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+
+		// Package references are never resolved to objects declared in the file
+		if ident, ok := se.X.(*ast.Ident); ok && ident.Obj == nil && localNames[ident.Name] {
+			gf.add(se, nil)
+		}
+
+		return true
+	})
+}
+
+// packageNameFromPath guesses the conventional name of a package from its import path,
+// skipping major-version suffixes such as /v2 and gopkg.in-style .v3 suffixes.
+func packageNameFromPath(importPath string) string {
+	segments := strings.Split(importPath, "/")
+	name := segments[len(segments)-1]
+
+	if len(segments) > 1 && isMajorVersion(name) {
+		name = segments[len(segments)-2]
+	}
+
+	if dot := strings.LastIndex(name, ".v"); dot > 0 && isMajorVersion(name[dot+1:]) {
+		name = name[:dot]
+	}
+
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+func isMajorVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' {
+		return false
+	}
+
+	_, err := strconv.Atoi(s[1:])
+
+	return err == nil
+}
+
+func (gf *goFinder) add(n ast.Node, captures []Capture) {
+	start := gf.fset.Position(n.Pos()).Offset
+	end := gf.fset.Position(n.End()).Offset
+
+	p := gf.index.position(start, end)
+	p.Captures = captures
+	gf.positions = append(gf.positions, p)
+}
@@ -0,0 +1,252 @@
+package match
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agrski/greg/pkg/types"
+)
+
+func TestParseGoQuery(t *testing.T) {
+	type test struct {
+		name     string
+		query    string
+		expected *GoQuery
+		wantErr  bool
+	}
+
+	tests := []test{
+		{
+			name:     "function glob",
+			query:    "func:New*",
+			expected: &GoQuery{Kind: GoQueryFunc, Name: "New*"},
+		},
+		{
+			name:     "import path",
+			query:    " import: database/sql ",
+			expected: &GoQuery{Kind: GoQueryImport, Name: "database/sql"},
+		},
+		{
+			name:     "method signature is normalised",
+			query:    "method:Read(p []byte) (n int, err error)",
+			expected: &GoQuery{Kind: GoQueryMethod, Name: "Read", signature: "([]byte) (int, error)"},
+		},
+		{
+			name:     "method without results",
+			query:    "method:Reset()",
+			expected: &GoQuery{Kind: GoQueryMethod, Name: "Reset", signature: "()"},
+		},
+		{name: "missing kind fails", query: "New*", wantErr: true},
+		{name: "missing value fails", query: "func:", wantErr: true},
+		{name: "unknown kind fails", query: "var:foo", wantErr: true},
+		{name: "method without signature fails", query: "method:Close", wantErr: true},
+		{name: "method with invalid signature fails", query: "method:Close(", wantErr: true},
+		{name: "invalid glob fails", query: "type:[", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ParseGoQuery(tt.query)
+
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPackageNameFromPath(t *testing.T) {
+	type test struct {
+		importPath string
+		expected   string
+	}
+
+	tests := []test{
+		{importPath: "fmt", expected: "fmt"},
+		{importPath: "database/sql", expected: "sql"},
+		{importPath: "github.com/rs/zerolog", expected: "zerolog"},
+		{importPath: "github.com/bmatcuk/doublestar/v4", expected: "doublestar"},
+		{importPath: "gopkg.in/yaml.v3", expected: "yaml"},
+		{importPath: "github.com/mattn/go-isatty", expected: "go_isatty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.importPath, func(t *testing.T) {
+			require.Equal(t, tt.expected, packageNameFromPath(tt.importPath))
+		})
+	}
+}
+
+func TestGoMatch(t *testing.T) {
+	type test struct {
+		name              string
+		path              string
+		text              string
+		query             string
+		expectedOk        bool
+		expectedTexts     []string
+		expectedCaptures  [][]Capture
+		expectedPositions []*FilePosition
+	}
+
+	source := `package store
+
+import (
+	"database/sql"
+	"net/http"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+type reader interface{}
+
+func NewStore(dsn string) (*Store, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s Store) Flush() error { return nil }
+
+func fetch(url string) {
+	sql := url
+	http.Get(
+		sql,
+	)
+}
+`
+
+	tests := []test{
+		{
+			name:       "non-go files are rejected",
+			path:       "store.py",
+			text:       source,
+			query:      "func:New*",
+			expectedOk: false,
+		},
+		{
+			name:       "unparseable files are rejected",
+			path:       "broken.go",
+			text:       "package broken\nfunc {",
+			query:      "func:*",
+			expectedOk: false,
+		},
+		{
+			name:          "functions by name",
+			path:          "store.go",
+			text:          source,
+			query:         "func:New*",
+			expectedOk:    true,
+			expectedTexts: []string{"NewStore"},
+		},
+		{
+			name:          "types by name",
+			path:          "store.go",
+			text:          source,
+			query:         "type:*",
+			expectedOk:    true,
+			expectedTexts: []string{"Store", "reader"},
+		},
+		{
+			name:          "methods by signature capture receiver",
+			path:          "store.go",
+			text:          source,
+			query:         "method:*() error",
+			expectedOk:    true,
+			expectedTexts: []string{"Close", "Flush"},
+			expectedCaptures: [][]Capture{
+				{{Name: "receiver", Text: "*Store"}},
+				{{Name: "receiver", Text: "Store"}},
+			},
+		},
+		{
+			name:       "functions are not methods",
+			path:       "store.go",
+			text:       source,
+			query:      "method:NewStore(string) (*Store, error)",
+			expectedOk: false,
+		},
+		{
+			name:          "imports and package references, ignoring shadowed names",
+			path:          "store.go",
+			text:          source,
+			query:         "import:database/sql",
+			expectedOk:    true,
+			expectedTexts: []string{`"database/sql"`, "sql.DB", "sql.Open"},
+		},
+		{
+			name:          "calls by callee",
+			path:          "store.go",
+			text:          source,
+			query:         "call:sql.Open",
+			expectedOk:    true,
+			expectedTexts: []string{`sql.Open("postgres", dsn)`},
+		},
+		{
+			name:       "multi-line calls span lines",
+			path:       "store.go",
+			text:       source,
+			query:      "call:http.*",
+			expectedOk: true,
+			expectedPositions: []*FilePosition{
+				{
+					Line:        29,
+					LineEnd:     31,
+					ColumnStart: 1,
+					ColumnEnd:   2,
+					Text:        "\thttp.Get(\n\t\tsql,\n\t)",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileInfo := &types.FileInfo{
+				Path: tt.path,
+				Text: tt.text,
+			}
+			matcher := newGoMatcher(zerolog.Nop())
+
+			actual, ok := matcher.Match(tt.query, fileInfo)
+
+			require.Equal(t, tt.expectedOk, ok)
+			if !tt.expectedOk {
+				require.Nil(t, actual)
+				return
+			}
+
+			if tt.expectedPositions != nil {
+				require.Equal(t, tt.expectedPositions, actual.Positions)
+				return
+			}
+
+			actualTexts := []string{}
+			actualCaptures := [][]Capture{}
+			for _, p := range actual.Positions {
+				require.Equal(t, p.Line, p.LineEnd)
+				actualTexts = append(actualTexts, p.Text[p.ColumnStart:p.ColumnEnd])
+				if p.Captures != nil {
+					actualCaptures = append(actualCaptures, p.Captures)
+				}
+			}
+			require.Equal(t, tt.expectedTexts, actualTexts)
+			if tt.expectedCaptures != nil {
+				require.Equal(t, tt.expectedCaptures, actualCaptures)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package match
+
+import (
+	"github.com/rs/zerolog"
+
+	"github.com/agrski/greg/pkg/types"
+)
+
+// limitMatcher caps the positions found in each file and across all files.
+// It counts results over every call, so one instance should only be used for a single run.
+type limitMatcher struct {
+	matcher    Matcher
+	maxCount   uint
+	maxResults uint
+	results    uint
+	logger     zerolog.Logger
+}
+
+var _ Matcher = (*limitMatcher)(nil)
+
+func newLimitMatcher(logger zerolog.Logger, matcher Matcher, maxCount uint, maxResults uint) *limitMatcher {
+	logger = logger.With().Str("source", "LimitMatcher").Logger()
+	return &limitMatcher{
+		matcher:    matcher,
+		maxCount:   maxCount,
+		maxResults: maxResults,
+		logger:     logger,
+	}
+}
+
+func (lm *limitMatcher) Match(pattern string, next *types.FileInfo) (*Match, bool) {
+	if lm.exhausted() {
+		return nil, false
+	}
+
+	match, ok := lm.matcher.Match(pattern, next)
+	if !ok {
+		return nil, false
+	}
+
+	limit := uint(len(match.Positions))
+	if lm.maxCount > 0 && lm.maxCount < limit {
+		limit = lm.maxCount
+	}
+	if lm.maxResults > 0 && lm.maxResults-lm.results < limit {
+		limit = lm.maxResults - lm.results
+	}
+
+	if limit < uint(len(match.Positions)) {
+		lm.logger.
+			Debug().
+			Str("func", "Match").
+			Str("filename", next.Path).
+			Int("found", len(match.Positions)).
+			Uint("kept", limit).
+			Msg("limiting matches")
+		match.Positions = match.Positions[:limit]
+	}
+	lm.results += limit
+
+	return match, true
+}
+
+// exhausted reports whether the maximum number of results for the run has been reached.
+func (lm *limitMatcher) exhausted() bool {
+	return lm.maxResults > 0 && lm.results >= lm.maxResults
+}
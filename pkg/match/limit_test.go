@@ -0,0 +1,124 @@
+package match
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agrski/greg/pkg/types"
+)
+
+func TestExactMatcherMaxCount(t *testing.T) {
+	text := "err\nerr err\nno match\nerr"
+
+	type test struct {
+		name          string
+		maxCount      uint
+		expectedLines []uint
+	}
+
+	tests := []test{
+		{name: "no limit finds all", maxCount: 0, expectedLines: []uint{0, 1, 1, 3}},
+		{name: "limit stops within a line", maxCount: 2, expectedLines: []uint{0, 1}},
+		{name: "limit above matches finds all", maxCount: 10, expectedLines: []uint{0, 1, 1, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newExactMatcher(zerolog.Nop(), false, tt.maxCount)
+
+			match, ok := m.Match("err", &types.FileInfo{Path: "main.go", Text: text})
+			require.True(t, ok)
+
+			lines := []uint{}
+			for _, p := range match.Positions {
+				lines = append(lines, p.Line)
+			}
+			require.Equal(t, tt.expectedLines, lines)
+		})
+	}
+}
+
+func TestLimitMatcher(t *testing.T) {
+	type test struct {
+		name              string
+		maxCount          uint
+		maxResults        uint
+		texts             []string
+		expectedCounts    []int
+		expectedExhausted bool
+	}
+
+	tests := []test{
+		{
+			name:           "no limits keeps everything",
+			texts:          []string{"a a a", "a a"},
+			expectedCounts: []int{3, 2},
+		},
+		{
+			name:           "max count limits each file",
+			maxCount:       2,
+			texts:          []string{"a a a", "a", "a a a"},
+			expectedCounts: []int{2, 1, 2},
+		},
+		{
+			name:              "max results limits across files",
+			maxResults:        4,
+			texts:             []string{"a a a", "a a", "a"},
+			expectedCounts:    []int{3, 1, 0},
+			expectedExhausted: true,
+		},
+		{
+			name:              "both limits apply together",
+			maxCount:          2,
+			maxResults:        3,
+			texts:             []string{"a a a", "a a a"},
+			expectedCounts:    []int{2, 1},
+			expectedExhausted: true,
+		},
+		{
+			name:           "files without matches use none of the budget",
+			maxResults:     2,
+			texts:          []string{"b", "a", "b", "a"},
+			expectedCounts: []int{0, 1, 0, 1},
+			// The budget is used up by the last file
+			expectedExhausted: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newLimitMatcher(zerolog.Nop(), newExactMatcher(zerolog.Nop(), false, 0), tt.maxCount, tt.maxResults)
+
+			for idx, text := range tt.texts {
+				match, ok := m.Match("a", &types.FileInfo{Path: "main.go", Text: text})
+
+				if tt.expectedCounts[idx] == 0 {
+					require.False(t, ok)
+				} else {
+					require.True(t, ok)
+					require.Len(t, match.Positions, tt.expectedCounts[idx])
+				}
+			}
+
+			require.Equal(t, tt.expectedExhausted, m.exhausted())
+		})
+	}
+}
+
+func TestNewExhausted(t *testing.T) {
+	m := New(zerolog.Nop(), Config{MaxResults: 2})
+	require.False(t, m.Exhausted())
+
+	match, ok := m.Match("a", &types.FileInfo{Path: "a.txt", Extension: "txt", Text: "a\na\na"})
+	require.True(t, ok)
+	require.Len(t, match.Positions, 2)
+	require.True(t, m.Exhausted())
+
+	_, ok = m.Match("a", &types.FileInfo{Path: "b.txt", Extension: "txt", Text: "a"})
+	require.False(t, ok)
+
+	unlimited := New(zerolog.Nop(), Config{})
+	require.False(t, unlimited.Exhausted())
+}
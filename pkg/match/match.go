@@ -1,8 +1,12 @@
 package match
 
 import (
+	"errors"
+	"io"
+
 	"github.com/rs/zerolog"
 
+	"github.com/agrski/greg/pkg/stats"
 	"github.com/agrski/greg/pkg/types"
 )
 
@@ -12,43 +16,213 @@ type Matcher interface {
 
 type Match struct {
 	Positions []*FilePosition
+	// Hunks are the contiguous blocks of lines surrounding the positions,
+	// only populated when context lines have been requested.
+	Hunks []*Hunk
 }
 
+// FilePosition describes where a match occurs in a file.
+// Positions spanning several lines start at ColumnStart on Line and end at ColumnEnd on LineEnd,
+// with Text holding every spanned line separated by newlines.
+// Very long lines are not held whole, so Text may instead be an excerpt starting at TextColumn.
 type FilePosition struct {
 	Line        uint
+	LineEnd     uint
 	ColumnStart uint
 	ColumnEnd   uint
 	Text        string
+	TextColumn  uint
+	// TextCharacters counts the characters before Text in its line, which differs from TextColumn
+	// when an excerpt follows multi-byte characters.
+	TextCharacters uint
+	// Distance is the number of edits between the pattern and the matched text, for approximate matches.
+	Distance uint
+	// Captures holds the text matched by each named hole, for structural matches.
+	Captures []Capture
+	// RuleID names the rule which detected a secret, for secret matches.
+	RuleID string
+	// Binary positions give byte offsets into a binary file as columns, with Text holding the nearby bytes.
+	Binary bool
+}
+
+// Span gives the start and end of the match within Text, allowing for excerpts.
+func (p *FilePosition) Span() (uint, uint) {
+	return p.ColumnStart - p.TextColumn, p.ColumnEnd - p.TextColumn
+}
+
+type Config struct {
+	CaseInsensitive  bool
+	AllowedFiletypes []types.FileExtension
+	// FileTypeDefinitions defines the named types which may be allowed, defaulting to the built-in types.
+	FileTypeDefinitions FileTypes
+	PathFilter          *PathFilter
+	// ContextBefore and ContextAfter are the number of lines to attach around each match.
+	ContextBefore uint
+	ContextAfter  uint
+	// Multiline allows patterns, and therefore matches, to span line boundaries.
+	Multiline bool
+	// MaxDistance enables approximate matching within this Levenshtein distance of the pattern when non-zero.
+	MaxDistance uint
+	// Structural treats patterns as structural patterns with holes; see ParseStructuralPattern.
+	Structural bool
+	// GoSymbols treats patterns as queries over Go declarations and expressions; see ParseGoQuery.
+	GoSymbols bool
+	// Secrets looks for leaked credentials instead of a pattern, using SecretRules or else the built-in rules.
+	Secrets     bool
+	SecretRules []*SecretRule
+	// Binary searches the raw bytes of binary files, when fetchers provide them.
+	Binary bool
+	// Scope restricts matches to code, comments, or string literals, for supported languages.
+	Scope Scope
+	// Query treats patterns as boolean queries over several terms; see ParseQuery.
+	Query bool
+	// MaxCount limits the positions reported for each file, and MaxResults those across all files, unless zero.
+	MaxCount   uint
+	MaxResults uint
+	// KeepContents reads the whole of each text file searched into its Text, even for matchers which stream files,
+	// for presenters showing more of a file than its matched lines.
+	KeepContents bool
+	// Stats, if set, records which files are matched, skipped, filtered out, or cannot be read.
+	Stats *stats.Stats
 }
 
 type filteringMatcher struct {
-	matcher   Matcher
-	filetypes []types.FileExtension
-	logger    zerolog.Logger
+	matcher             Matcher
+	limiter             *limitMatcher
+	allowedFiletypes    []types.FileExtension
+	fileTypeDefinitions FileTypes
+	pathFilter          *PathFilter
+	binary              bool
+	keepContents        bool
+	stats               *stats.Stats
+	logger              zerolog.Logger
 }
 
 var _ Matcher = (*filteringMatcher)(nil)
 
-func New(
-	logger zerolog.Logger,
-	caseInsensitive bool,
-	allowedFiletypes []types.FileExtension,
-) *filteringMatcher {
-	em := newExactMatcher(logger, caseInsensitive)
+func New(logger zerolog.Logger, config Config) *filteringMatcher {
+	// Matchers only stop early when no positions they find will be filtered out
+	var baseMaxCount uint
+	if config.Scope == ScopeAll {
+		baseMaxCount = config.MaxCount
+	}
+
+	var m Matcher
+	switch {
+	case config.Secrets:
+		m = newSecretMatcher(logger, config.SecretRules)
+	case config.MaxDistance > 0:
+		m = newFuzzyMatcher(logger, config.CaseInsensitive, config.MaxDistance)
+	case config.GoSymbols:
+		m = newGoMatcher(logger)
+	case config.Structural:
+		m = newStructuralMatcher(logger, config.CaseInsensitive)
+	case config.Multiline:
+		m = newMultilineMatcher(logger, config.CaseInsensitive)
+	default:
+		m = newExactMatcher(logger, config.CaseInsensitive, baseMaxCount)
+	}
+	if config.Binary {
+		m = newBinaryMatcher(logger, m, config.CaseInsensitive, baseMaxCount)
+	}
+	if config.Scope != ScopeAll {
+		m = newScopeMatcher(logger, m, config.Scope)
+	}
+	if config.Query {
+		m = newQueryMatcher(logger, m)
+	}
+	var limiter *limitMatcher
+	if config.MaxCount > 0 || config.MaxResults > 0 {
+		limiter = newLimitMatcher(logger, m, config.MaxCount, config.MaxResults)
+		m = limiter
+	}
+	if config.ContextBefore > 0 || config.ContextAfter > 0 {
+		var secrets *secretMatcher
+		if config.Secrets {
+			secrets = newSecretMatcher(logger, config.SecretRules)
+		}
+		m = newContextMatcher(logger, m, config.ContextBefore, config.ContextAfter, secrets)
+	}
+
+	fileTypeDefinitions := config.FileTypeDefinitions
+	if fileTypeDefinitions == nil {
+		fileTypeDefinitions = builtinFileTypes
+	}
+
 	logger = logger.With().Str("source", "FilteringMatcher").Logger()
 
 	return &filteringMatcher{
-		matcher:   em,
-		filetypes: allowedFiletypes,
-		logger:    logger,
+		matcher:             m,
+		limiter:             limiter,
+		allowedFiletypes:    config.AllowedFiletypes,
+		fileTypeDefinitions: fileTypeDefinitions,
+		pathFilter:          config.PathFilter,
+		binary:              config.Binary,
+		keepContents:        config.KeepContents,
+		stats:               config.Stats,
+		logger:              logger,
 	}
 }
 
+// Exhausted reports whether the maximum number of results has been found, so no more files need searching.
+func (fm *filteringMatcher) Exhausted() bool {
+	return fm.limiter != nil && fm.limiter.exhausted()
+}
+
 func (fm *filteringMatcher) Match(pattern string, next *types.FileInfo) (*Match, bool) {
-	ok := FilterFiletype(fm.filetypes, next)
+	ok := fm.fileTypeDefinitions.Filter(fm.allowedFiletypes, next)
 	if !ok {
+		fm.stats.RecordFilteredByType()
 		return nil, false
 	}
 
-	return fm.matcher.Match(pattern, next)
+	if !fm.pathFilter.AllowsFile(next.Path) {
+		fm.stats.RecordFilteredByPath()
+		return nil, false
+	}
+
+	// Binary files are only searched in binary mode, and then only when their contents were fetched
+	unsearchable := next.IsBinary && (!fm.binary || (next.Body == nil && next.Text == ""))
+
+	// Errors reading a body, such as failed downloads, are otherwise only logged by the matcher reading it
+	var body *errorRecordingReader
+	if next.Body != nil {
+		body = &errorRecordingReader{reader: next.Body}
+		next.Body = body
+	}
+
+	if fm.keepContents && !next.IsBinary {
+		if _, err := next.Contents(); err != nil {
+			fm.logger.Error().Err(err).Str("func", "Match").Str("filename", next.Path).Msg("unable to read file")
+			fm.stats.RecordFailure(next.Path, err)
+			return nil, false
+		}
+	}
+
+	m, ok := fm.matcher.Match(pattern, next)
+	switch {
+	case body != nil && body.err != nil:
+		fm.stats.RecordFailure(next.Path, body.err)
+	case ok:
+		fm.stats.RecordMatched(len(m.Positions))
+	case unsearchable:
+		fm.stats.RecordSkippedBinary()
+	}
+
+	return m, ok
+}
+
+// errorRecordingReader keeps the first error other than EOF from reading a file.
+type errorRecordingReader struct {
+	reader io.Reader
+	err    error
+}
+
+func (r *errorRecordingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) && r.err == nil {
+		r.err = err
+	}
+
+	return n, err
 }
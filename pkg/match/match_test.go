@@ -0,0 +1,65 @@
+package match
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agrski/greg/pkg/stats"
+	"github.com/agrski/greg/pkg/types"
+)
+
+// failingReader gives some text and then an error, as a download cut short does.
+type failingReader struct {
+	reader io.Reader
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if errors.Is(err, io.EOF) {
+		return n, io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
+func TestNewStats(t *testing.T) {
+	pathFilter, err := NewPathFilter(nil, []string{"vendor/**"})
+	require.NoError(t, err)
+
+	s := stats.New()
+	m := New(
+		zerolog.Nop(),
+		Config{
+			AllowedFiletypes: []types.FileExtension{"go"},
+			PathFilter:       pathFilter,
+			Stats:            s,
+		},
+	)
+
+	files := []*types.FileInfo{
+		{Path: "main.go", Extension: "go", Text: "err err\nerr"},
+		{Path: "other.go", Extension: "go", Text: "nothing here"},
+		{Path: "README.md", Extension: "md", Text: "err"},
+		{Path: "vendor/lib.go", Extension: "go", Text: "err"},
+		{Path: "bin.go", Extension: "go", IsBinary: true},
+		{Path: "cut.go", Extension: "go", Body: &failingReader{reader: strings.NewReader("err\n")}},
+	}
+	for _, f := range files {
+		m.Match("err", f)
+	}
+
+	summary := s.Summary()
+	require.Equal(t, uint64(1), summary.FilesMatched)
+	require.Equal(t, uint64(3), summary.Matches)
+	require.Equal(t, uint64(1), summary.FilteredByType)
+	require.Equal(t, uint64(1), summary.FilteredByPath)
+	require.Equal(t, uint64(1), summary.SkippedBinary)
+	require.Len(t, summary.Failures, 1)
+	require.Equal(t, "cut.go", summary.Failures[0].Path)
+	require.ErrorIs(t, summary.Failures[0].Err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,158 @@
+package match
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/agrski/greg/pkg/types"
+)
+
+type multilineMatcher struct {
+	caseInsensitive bool
+	logger          zerolog.Logger
+}
+
+var _ Matcher = (*multilineMatcher)(nil)
+
+func newMultilineMatcher(logger zerolog.Logger, caseInsensitive bool) *multilineMatcher {
+	logger = logger.With().Str("source", "MultilineMatcher").Logger()
+
+	return &multilineMatcher{
+		caseInsensitive: caseInsensitive,
+		logger:          logger,
+	}
+}
+
+func (mm *multilineMatcher) Match(pattern string, next *types.FileInfo) (*Match, bool) {
+	logger := mm.logger.With().Str("func", "Match").Logger()
+
+	if next.IsBinary {
+		logger.Debug().Str("filename", next.Path).Msg("rejecting binary file")
+		return nil, false
+	}
+
+	if pattern == "" {
+		return nil, false
+	}
+
+	text, err := next.Contents()
+	if err != nil {
+		logger.Error().Err(err).Str("filename", next.Path).Msg("unable to read file")
+		return nil, false
+	}
+
+	index := newLineIndex(text)
+	match := &Match{}
+
+	for _, span := range mm.find(pattern, text) {
+		match.Positions = append(match.Positions, index.position(span[0], span[1]))
+	}
+
+	if len(match.Positions) == 0 {
+		return nil, false
+	}
+
+	return match, true
+}
+
+// find gives the start and end of every non-overlapping occurrence of a pattern in text.
+// Case-insensitive matching happens on the text itself, as lowering it can change the lengths of characters
+// and so move the offsets of everything after them.
+func (mm *multilineMatcher) find(pattern string, text string) [][]int {
+	if mm.caseInsensitive {
+		return regexp.MustCompile("(?i)"+regexp.QuoteMeta(pattern)).FindAllStringIndex(text, -1)
+	}
+
+	spans := [][]int{}
+	for offset := 0; offset < len(text); {
+		found := strings.Index(text[offset:], pattern)
+		if found == -1 {
+			break
+		}
+
+		start := offset + found
+		end := start + len(pattern)
+		spans = append(spans, []int{start, end})
+
+		offset = end
+	}
+
+	return spans
+}
+
+// lineIndex converts byte offsets within a text into line and column positions.
+type lineIndex struct {
+	text       string
+	lineStarts []int
+}
+
+func newLineIndex(text string) *lineIndex {
+	lineStarts := []int{0}
+	for idx := 0; idx < len(text); idx++ {
+		if text[idx] == '\n' && idx+1 < len(text) {
+			lineStarts = append(lineStarts, idx+1)
+		}
+	}
+
+	return &lineIndex{
+		text:       text,
+		lineStarts: lineStarts,
+	}
+}
+
+// lineOf returns the zero-indexed line containing the given byte offset.
+func (li *lineIndex) lineOf(offset int) int {
+	return sort.Search(len(li.lineStarts), func(i int) bool {
+		return li.lineStarts[i] > offset
+	}) - 1
+}
+
+// lineText returns the content of a line, without any line terminator.
+func (li *lineIndex) lineText(line int) string {
+	start := li.lineStarts[line]
+	end := len(li.text)
+	if line+1 < len(li.lineStarts) {
+		end = li.lineStarts[line+1]
+	}
+
+	text := strings.TrimSuffix(li.text[start:end], "\n")
+
+	return strings.TrimSuffix(text, "\r")
+}
+
+// position describes the half-open byte range [start, end) of the text.
+func (li *lineIndex) position(start int, end int) *FilePosition {
+	startLine := li.lineOf(start)
+	endLine := startLine
+	if end > start {
+		endLine = li.lineOf(end - 1)
+	}
+
+	lines := make([]string, 0, endLine-startLine+1)
+	for l := startLine; l <= endLine; l++ {
+		lines = append(lines, li.lineText(l))
+	}
+
+	// A match may include line terminators, which are not part of the text
+	columnStart := clamp(start-li.lineStarts[startLine], len(lines[0]))
+	columnEnd := clamp(end-li.lineStarts[endLine], len(lines[len(lines)-1]))
+
+	return &FilePosition{
+		Line:        uint(startLine),
+		LineEnd:     uint(endLine),
+		ColumnStart: uint(columnStart),
+		ColumnEnd:   uint(columnEnd),
+		Text:        strings.Join(lines, "\n"),
+	}
+}
+
+func clamp(n int, limit int) int {
+	if n > limit {
+		return limit
+	}
+
+	return n
+}
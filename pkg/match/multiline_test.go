@@ -0,0 +1,201 @@
+package match
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agrski/greg/pkg/types"
+)
+
+func TestMultilineMatch(t *testing.T) {
+	type test struct {
+		name              string
+		isBinary          bool
+		isCaseInsensitive bool
+		text              string
+		pattern           string
+		expected          *Match
+		expectedOk        bool
+	}
+
+	tests := []test{
+		{
+			name:       "should ignore binary files",
+			isBinary:   true,
+			text:       "foo\nbar",
+			pattern:    "foo\nbar",
+			expected:   nil,
+			expectedOk: false,
+		},
+		{
+			name:       "should reject empty pattern",
+			text:       "foo\nbar",
+			pattern:    "",
+			expected:   nil,
+			expectedOk: false,
+		},
+		{
+			name:       "should reject non-matching text file",
+			text:       "foo\nbar",
+			pattern:    "foo bar",
+			expected:   nil,
+			expectedOk: false,
+		},
+		{
+			name:    "should accept single-line match",
+			text:    "first\nfoo bar baz\n",
+			pattern: "bar",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{
+						Line:        1,
+						LineEnd:     1,
+						ColumnStart: 4,
+						ColumnEnd:   7,
+						Text:        "foo bar baz",
+					},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:    "should accept match spanning two lines",
+			text:    "first\nfunc foo(\n\tbar int,\n) {\n",
+			pattern: "foo(\n\tbar",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{
+						Line:        1,
+						LineEnd:     2,
+						ColumnStart: 5,
+						ColumnEnd:   4,
+						Text:        "func foo(\n\tbar int,",
+					},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:    "should accept match spanning several lines",
+			text:    "a\nb\nc\nd\n",
+			pattern: "b\nc\nd",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{
+						Line:        1,
+						LineEnd:     3,
+						ColumnStart: 0,
+						ColumnEnd:   1,
+						Text:        "b\nc\nd",
+					},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:    "should exclude trailing line break from text",
+			text:    "foo\r\nbar\r\n",
+			pattern: "bar\r\n",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{
+						Line:        1,
+						LineEnd:     1,
+						ColumnStart: 0,
+						ColumnEnd:   3,
+						Text:        "bar",
+					},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:              "should accept mixed-case match across lines when case-insensitive",
+			isCaseInsensitive: true,
+			text:              "Hello\nWORLD",
+			pattern:           "hello\nworld",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{
+						Line:        0,
+						LineEnd:     1,
+						ColumnStart: 0,
+						ColumnEnd:   5,
+						Text:        "Hello\nWORLD",
+					},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:              "should keep offsets after characters whose lowercase is longer when case-insensitive",
+			isCaseInsensitive: true,
+			text:              "ȺȺȺȺ\nFoo\nBAR",
+			pattern:           "foo\nbar",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{
+						Line:        1,
+						LineEnd:     2,
+						ColumnStart: 0,
+						ColumnEnd:   3,
+						Text:        "Foo\nBAR",
+					},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:              "should keep columns after characters whose lowercase is shorter when case-insensitive",
+			isCaseInsensitive: true,
+			text:              "İİ Foo\nBAR",
+			pattern:           "foo\nbar",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{
+						Line:        0,
+						LineEnd:     1,
+						ColumnStart: 5,
+						ColumnEnd:   3,
+						Text:        "İİ Foo\nBAR",
+					},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:    "should accept multiple non-overlapping matches",
+			text:    "ab\nab\nab",
+			pattern: "ab\nab",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{
+						Line:        0,
+						LineEnd:     1,
+						ColumnStart: 0,
+						ColumnEnd:   2,
+						Text:        "ab\nab",
+					},
+				},
+			},
+			expectedOk: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileInfo := &types.FileInfo{}
+			fileInfo.IsBinary = tt.isBinary
+			fileInfo.Text = tt.text
+
+			matcher := newMultilineMatcher(zerolog.Nop(), tt.isCaseInsensitive)
+
+			actual, ok := matcher.Match(tt.pattern, fileInfo)
+
+			require.Equal(t, tt.expectedOk, ok)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package match
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"github.com/agrski/greg/pkg/types"
+)
+
+// PathFilter restricts the files searched to those whose paths are included and not excluded.
+// A nil PathFilter allows all paths.
+//
+// Patterns are doublestar globs, e.g. cmd/** or **/*_test.go, with a few conveniences:
+//   - A pattern without any slashes, such as *.pb.go, matches a file or directory name at any depth.
+//   - A trailing slash, as in vendor/, restricts a pattern to matching directories.
+//   - A leading slash anchors a pattern to the repository root.
+//
+// Matching a directory also matches everything beneath it.
+//
+// Files within archives have paths like dist/logs.zip!app/out.log, which patterns containing ! match as a whole,
+// e.g. **/*.zip!**/*.log, and which basename patterns match by the name after the last / or !.
+// Matching an archive also matches everything within it.
+type PathFilter struct {
+	includes []*pathPattern
+	excludes []*pathPattern
+}
+
+type pathPattern struct {
+	glob          string
+	basenameOnly  bool
+	directoryOnly bool
+}
+
+func NewPathFilter(includes []string, excludes []string) (*PathFilter, error) {
+	pf := &PathFilter{}
+
+	for _, i := range includes {
+		p, err := newPathPattern(i)
+		if err != nil {
+			return nil, err
+		}
+		pf.includes = append(pf.includes, p)
+	}
+
+	for _, e := range excludes {
+		p, err := newPathPattern(e)
+		if err != nil {
+			return nil, err
+		}
+		pf.excludes = append(pf.excludes, p)
+	}
+
+	return pf, nil
+}
+
+func newPathPattern(raw string) (*pathPattern, error) {
+	glob := strings.TrimSpace(raw)
+	glob = strings.TrimPrefix(glob, "./")
+
+	anchored := strings.HasPrefix(glob, "/")
+	glob = strings.TrimPrefix(glob, "/")
+
+	directoryOnly := strings.HasSuffix(glob, "/")
+	glob = strings.TrimSuffix(glob, "/")
+
+	if glob == "" {
+		return nil, fmt.Errorf("path pattern '%s' cannot be empty", raw)
+	}
+	if !doublestar.ValidatePattern(glob) {
+		return nil, fmt.Errorf("invalid path pattern '%s'", raw)
+	}
+
+	return &pathPattern{
+		glob:          glob,
+		basenameOnly:  !anchored && !strings.ContainsAny(glob, "/"+types.MemberSeparator),
+		directoryOnly: directoryOnly,
+	}, nil
+}
+
+// AllowsFile reports whether a file should be searched.
+func (pf *PathFilter) AllowsFile(filePath string) bool {
+	if pf == nil {
+		return true
+	}
+
+	for _, e := range pf.excludes {
+		if e.covers(filePath, false) {
+			return false
+		}
+	}
+
+	if len(pf.includes) == 0 {
+		return true
+	}
+
+	for _, i := range pf.includes {
+		if i.covers(filePath, false) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsDirectory reports whether a directory might contain any files which should be searched.
+// Directories which are not allowed can be skipped entirely.
+func (pf *PathFilter) AllowsDirectory(dirPath string) bool {
+	if pf == nil {
+		return true
+	}
+
+	for _, e := range pf.excludes {
+		if e.covers(dirPath, true) {
+			return false
+		}
+	}
+
+	if len(pf.includes) == 0 {
+		return true
+	}
+
+	for _, i := range pf.includes {
+		if i.covers(dirPath, true) || i.couldMatchBeneath(dirPath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsArchive reports whether an archive might contain any files which should be searched,
+// even if the archive itself should not be.
+func (pf *PathFilter) AllowsArchive(archivePath string) bool {
+	if pf == nil {
+		return true
+	}
+
+	for _, e := range pf.excludes {
+		if e.covers(archivePath, false) {
+			return false
+		}
+	}
+
+	if len(pf.includes) == 0 {
+		return true
+	}
+
+	for _, i := range pf.includes {
+		if i.covers(archivePath, false) || i.couldMatchWithin(archivePath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// covers reports whether the pattern matches the path itself or any directory or archive containing it.
+func (pp *pathPattern) covers(p string, isDirectory bool) bool {
+	if pp.matches(p, isDirectory) {
+		return true
+	}
+
+	for idx := len(p) - 1; idx > 0; idx-- {
+		switch p[idx] {
+		case '/':
+			if pp.matches(p[:idx], true) {
+				return true
+			}
+		case types.MemberSeparator[0]:
+			if pp.matches(p[:idx], false) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (pp *pathPattern) matches(p string, isDirectory bool) bool {
+	if pp.directoryOnly && !isDirectory {
+		return false
+	}
+
+	if pp.basenameOnly {
+		p = p[strings.LastIndexAny(p, "/"+types.MemberSeparator)+1:]
+	}
+
+	ok, err := doublestar.Match(pp.glob, p)
+
+	return err == nil && ok
+}
+
+// couldMatchWithin reports whether the pattern might match some file within the given archive.
+func (pp *pathPattern) couldMatchWithin(archivePath string) bool {
+	if pp.basenameOnly || strings.Contains(pp.glob, "{") {
+		return true
+	}
+
+	container, _, ok := strings.Cut(pp.glob, types.MemberSeparator)
+	if !ok {
+		return false
+	}
+
+	matched, err := doublestar.Match(container, archivePath)
+
+	return err == nil && matched
+}
+
+// couldMatchBeneath reports whether the pattern might match some path within the given directory.
+// It errs on the side of caution, only ruling out directories which cannot possibly match.
+func (pp *pathPattern) couldMatchBeneath(dir string) bool {
+	if pp.basenameOnly || strings.Contains(pp.glob, "{") {
+		return true
+	}
+
+	patternSegments := strings.Split(pp.glob, "/")
+	dirSegments := strings.Split(dir, "/")
+
+	for idx, d := range dirSegments {
+		if idx >= len(patternSegments) {
+			return false
+		}
+
+		p := patternSegments[idx]
+		if p == "**" {
+			return true
+		}
+
+		ok, err := doublestar.Match(p, d)
+		if err != nil || !ok {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,347 @@
+package match
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPathFilter(t *testing.T) {
+	type test struct {
+		name     string
+		includes []string
+		excludes []string
+		wantErr  bool
+	}
+
+	tests := []test{
+		{
+			name:     "no patterns",
+			includes: nil,
+			excludes: nil,
+			wantErr:  false,
+		},
+		{
+			name:     "valid patterns",
+			includes: []string{"cmd/**", "*.go"},
+			excludes: []string{"vendor/", "**/*_test.go"},
+			wantErr:  false,
+		},
+		{
+			name:     "empty include fails",
+			includes: []string{" "},
+			wantErr:  true,
+		},
+		{
+			name:     "lone slash exclude fails",
+			excludes: []string{"/"},
+			wantErr:  true,
+		},
+		{
+			name:     "unbalanced bracket fails",
+			includes: []string{"[a-z"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPathFilter(tt.includes, tt.excludes)
+
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPathFilterAllowsFile(t *testing.T) {
+	type test struct {
+		name     string
+		includes []string
+		excludes []string
+		path     string
+		expected bool
+	}
+
+	tests := []test{
+		{
+			name:     "should allow everything when there are no patterns",
+			path:     "pkg/match/match.go",
+			expected: true,
+		},
+		{
+			name:     "should allow file under included directory glob",
+			includes: []string{"cmd/**"},
+			path:     "cmd/cli/main.go",
+			expected: true,
+		},
+		{
+			name:     "should reject file outside included directory glob",
+			includes: []string{"cmd/**"},
+			path:     "pkg/match/match.go",
+			expected: false,
+		},
+		{
+			name:     "should allow file under included directory name",
+			includes: []string{"cmd"},
+			path:     "cmd/cli/main.go",
+			expected: true,
+		},
+		{
+			name:     "should match basename pattern at any depth",
+			includes: []string{"*.go"},
+			path:     "pkg/match/match.go",
+			expected: true,
+		},
+		{
+			name:     "should reject file under excluded directory at root",
+			excludes: []string{"vendor/"},
+			path:     "vendor/github.com/foo/bar.go",
+			expected: false,
+		},
+		{
+			name:     "should reject file under excluded directory at any depth",
+			excludes: []string{"vendor/"},
+			path:     "tools/vendor/foo.go",
+			expected: false,
+		},
+		{
+			name:     "should not apply directory-only pattern to files",
+			excludes: []string{"vendor/"},
+			path:     "docs/vendor",
+			expected: true,
+		},
+		{
+			name:     "should reject file matching doublestar exclude",
+			excludes: []string{"**/*_test.go"},
+			path:     "pkg/match/exact_test.go",
+			expected: false,
+		},
+		{
+			name:     "should reject root-level file matching doublestar exclude",
+			excludes: []string{"**/*_test.go"},
+			path:     "main_test.go",
+			expected: false,
+		},
+		{
+			name:     "should reject generated file matching basename exclude",
+			excludes: []string{"*.pb.go"},
+			path:     "api/v1/service.pb.go",
+			expected: false,
+		},
+		{
+			name:     "should allow file not matching any exclude",
+			excludes: []string{"vendor/", "**/*_test.go", "*.pb.go"},
+			path:     "api/v1/service.go",
+			expected: true,
+		},
+		{
+			name:     "should only match anchored pattern at root",
+			excludes: []string{"/build"},
+			path:     "tools/build/script.sh",
+			expected: true,
+		},
+		{
+			name:     "exclude should override include",
+			includes: []string{"cmd/**"},
+			excludes: []string{"**/*_test.go"},
+			path:     "cmd/cli/main_test.go",
+			expected: false,
+		},
+		{
+			name:     "should match basename within archive",
+			includes: []string{"*.go"},
+			path:     "dist/src.zip!main.go",
+			expected: true,
+		},
+		{
+			name:     "should match pattern naming files within archive",
+			includes: []string{"**/*.zip!**/*.go"},
+			path:     "dist/src.zip!cmd/main.go",
+			expected: true,
+		},
+		{
+			name:     "should allow files within included archive",
+			includes: []string{"*.zip"},
+			path:     "dist/src.zip!cmd/main.go",
+			expected: true,
+		},
+		{
+			name:     "should reject files within excluded archive",
+			excludes: []string{"dist/*.zip"},
+			path:     "dist/src.zip!main.go",
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewPathFilter(tt.includes, tt.excludes)
+			require.NoError(t, err)
+
+			actual := filter.AllowsFile(tt.path)
+
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestPathFilterAllowsDirectory(t *testing.T) {
+	type test struct {
+		name     string
+		includes []string
+		excludes []string
+		path     string
+		expected bool
+	}
+
+	tests := []test{
+		{
+			name:     "should allow everything when there are no patterns",
+			path:     "pkg/match",
+			expected: true,
+		},
+		{
+			name:     "should allow directory leading to included glob",
+			includes: []string{"pkg/match/**"},
+			path:     "pkg",
+			expected: true,
+		},
+		{
+			name:     "should allow directory within included glob",
+			includes: []string{"pkg/**"},
+			path:     "pkg/match/testdata",
+			expected: true,
+		},
+		{
+			name:     "should reject directory which cannot contain included paths",
+			includes: []string{"cmd/**"},
+			path:     "pkg",
+			expected: false,
+		},
+		{
+			name:     "should reject directory deeper than included file pattern",
+			includes: []string{"cmd/*.go"},
+			path:     "cmd/cli",
+			expected: false,
+		},
+		{
+			name:     "should allow any directory for basename includes",
+			includes: []string{"*.go"},
+			path:     "docs",
+			expected: true,
+		},
+		{
+			name:     "should allow any directory under doublestar include",
+			includes: []string{"**/testdata/*"},
+			path:     "pkg/fetch",
+			expected: true,
+		},
+		{
+			name:     "should reject excluded directory",
+			excludes: []string{"vendor/"},
+			path:     "third_party/vendor",
+			expected: false,
+		},
+		{
+			name:     "should reject directory within excluded directory",
+			excludes: []string{"vendor/"},
+			path:     "vendor/github.com",
+			expected: false,
+		},
+		{
+			name:     "should allow directory when only files are excluded",
+			excludes: []string{"**/*_test.go"},
+			path:     "pkg/match",
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewPathFilter(tt.includes, tt.excludes)
+			require.NoError(t, err)
+
+			actual := filter.AllowsDirectory(tt.path)
+
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestPathFilterAllowsArchive(t *testing.T) {
+	type test struct {
+		name     string
+		includes []string
+		excludes []string
+		path     string
+		expected bool
+	}
+
+	tests := []test{
+		{
+			name:     "should allow everything when there are no patterns",
+			path:     "dist/src.zip",
+			expected: true,
+		},
+		{
+			name:     "should allow included archive",
+			includes: []string{"dist/**"},
+			path:     "dist/src.zip",
+			expected: true,
+		},
+		{
+			name:     "should allow archive which may hold files named by basename include",
+			includes: []string{"*.go"},
+			path:     "dist/src.zip",
+			expected: true,
+		},
+		{
+			name:     "should allow archive named by include of files within it",
+			includes: []string{"src.zip!*.go"},
+			path:     "src.zip",
+			expected: true,
+		},
+		{
+			name:     "should reject archive not named by include of files within archives",
+			includes: []string{"src.zip!*.go"},
+			path:     "docs.zip",
+			expected: false,
+		},
+		{
+			name:     "should reject archive which cannot contain included paths",
+			includes: []string{"cmd/**"},
+			path:     "dist/src.zip",
+			expected: false,
+		},
+		{
+			name:     "should reject excluded archive",
+			includes: []string{"*.go"},
+			excludes: []string{"*.zip"},
+			path:     "dist/src.zip",
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewPathFilter(tt.includes, tt.excludes)
+			require.NoError(t, err)
+
+			actual := filter.AllowsArchive(tt.path)
+
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestNilPathFilterAllowsEverything(t *testing.T) {
+	var filter *PathFilter
+
+	require.True(t, filter.AllowsFile("any/file.go"))
+	require.True(t, filter.AllowsDirectory("any"))
+	require.True(t, filter.AllowsArchive("any.zip"))
+}
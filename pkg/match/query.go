@@ -0,0 +1,460 @@
+package match
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/rs/zerolog"
+
+	"github.com/agrski/greg/pkg/types"
+)
+
+/*
+	Boolean queries combine several search terms at the level of a whole file, e.g.
+
+		"sql.Open" AND NOT "defer db.Close"
+		TODO OR FIXME
+		(foo OR bar) AND NOT baz
+
+	Terms are either double-quoted strings, which may contain spaces and escaped quotes,
+	or bare words delimited by whitespace and parentheses.
+	The operators AND, OR, and NOT must be written in upper case.
+	NOT binds most tightly, followed by AND, then OR.
+*/
+
+const (
+	keywordAnd = "AND"
+	keywordOr  = "OR"
+	keywordNot = "NOT"
+)
+
+// Query is a boolean expression over search terms.
+type Query interface {
+	fmt.Stringer
+	evaluate(lookup termLookup) bool
+	// positions returns the locations of terms which contribute positively to the query being satisfied.
+	positions(lookup termLookup) []*FilePosition
+}
+
+// termLookup returns the result of matching a single term against the current file.
+type termLookup func(pattern string) *Match
+
+type termQuery struct {
+	pattern string
+}
+
+type notQuery struct {
+	operand Query
+}
+
+type andQuery struct {
+	operands []Query
+}
+
+type orQuery struct {
+	operands []Query
+}
+
+func (q *termQuery) evaluate(lookup termLookup) bool {
+	return lookup(q.pattern) != nil
+}
+
+func (q *termQuery) positions(lookup termLookup) []*FilePosition {
+	if m := lookup(q.pattern); m != nil {
+		return m.Positions
+	}
+
+	return nil
+}
+
+func (q *termQuery) String() string {
+	return strconv.Quote(q.pattern)
+}
+
+func (q *notQuery) evaluate(lookup termLookup) bool {
+	return !q.operand.evaluate(lookup)
+}
+
+func (q *notQuery) positions(_ termLookup) []*FilePosition {
+	return nil
+}
+
+func (q *notQuery) String() string {
+	return fmt.Sprintf("(%s %s)", keywordNot, q.operand)
+}
+
+func (q *andQuery) evaluate(lookup termLookup) bool {
+	for _, o := range q.operands {
+		if !o.evaluate(lookup) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (q *andQuery) positions(lookup termLookup) []*FilePosition {
+	if !q.evaluate(lookup) {
+		return nil
+	}
+
+	positions := []*FilePosition{}
+	for _, o := range q.operands {
+		positions = append(positions, o.positions(lookup)...)
+	}
+
+	return positions
+}
+
+func (q *andQuery) String() string {
+	return joinOperands(keywordAnd, q.operands)
+}
+
+func (q *orQuery) evaluate(lookup termLookup) bool {
+	for _, o := range q.operands {
+		if o.evaluate(lookup) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (q *orQuery) positions(lookup termLookup) []*FilePosition {
+	positions := []*FilePosition{}
+	for _, o := range q.operands {
+		if o.evaluate(lookup) {
+			positions = append(positions, o.positions(lookup)...)
+		}
+	}
+
+	return positions
+}
+
+func (q *orQuery) String() string {
+	return joinOperands(keywordOr, q.operands)
+}
+
+func joinOperands(operator string, operands []Query) string {
+	parts := make([]string, 0, len(operands)+1)
+	parts = append(parts, operator)
+	for _, o := range operands {
+		parts = append(parts, o.String())
+	}
+
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+type tokenKind int
+
+const (
+	tokenTerm tokenKind = iota
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+// ParseQuery parses a boolean query over search terms.
+func ParseQuery(raw string) (Query, error) {
+	tokens, err := tokenise(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return nil, errors.New("query cannot be empty")
+	}
+
+	p := &queryParser{tokens: tokens}
+	q, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q in query", p.peek().value)
+	}
+
+	return q, nil
+}
+
+func tokenise(raw string) ([]token, error) {
+	tokens := []token{}
+	runes := []rune(raw)
+
+	for idx := 0; idx < len(runes); {
+		r := runes[idx]
+
+		switch {
+		case unicode.IsSpace(r):
+			idx++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenOpen, value: "("})
+			idx++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenClose, value: ")"})
+			idx++
+		case r == '"':
+			sb := strings.Builder{}
+			idx++
+			closed := false
+
+			for idx < len(runes) && !closed {
+				switch runes[idx] {
+				case '\\':
+					if idx+1 < len(runes) {
+						idx++
+					}
+					sb.WriteRune(runes[idx])
+				case '"':
+					closed = true
+				default:
+					sb.WriteRune(runes[idx])
+				}
+				idx++
+			}
+
+			if !closed {
+				return nil, errors.New("unterminated quoted term in query")
+			}
+			if sb.Len() == 0 {
+				return nil, errors.New("quoted term in query cannot be empty")
+			}
+			tokens = append(tokens, token{kind: tokenTerm, value: sb.String()})
+		default:
+			start := idx
+			for idx < len(runes) && !isTermDelimiter(runes[idx]) {
+				idx++
+			}
+
+			word := string(runes[start:idx])
+			switch word {
+			case keywordAnd:
+				tokens = append(tokens, token{kind: tokenAnd, value: word})
+			case keywordOr:
+				tokens = append(tokens, token{kind: tokenOr, value: word})
+			case keywordNot:
+				tokens = append(tokens, token{kind: tokenNot, value: word})
+			default:
+				tokens = append(tokens, token{kind: tokenTerm, value: word})
+			}
+		}
+	}
+
+	return tokens, nil
+}
+
+func isTermDelimiter(r rune) bool {
+	return unicode.IsSpace(r) || r == '(' || r == ')' || r == '"'
+}
+
+type queryParser struct {
+	tokens []token
+	next   int
+}
+
+func (p *queryParser) done() bool {
+	return p.next >= len(p.tokens)
+}
+
+func (p *queryParser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *queryParser) accept(kind tokenKind) bool {
+	if !p.done() && p.peek().kind == kind {
+		p.next++
+		return true
+	}
+
+	return false
+}
+
+func (p *queryParser) parseOr() (Query, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	operands := []Query{first}
+	for p.accept(tokenOr) {
+		q, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, q)
+	}
+
+	if len(operands) == 1 {
+		return first, nil
+	}
+
+	return &orQuery{operands: operands}, nil
+}
+
+func (p *queryParser) parseAnd() (Query, error) {
+	first, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	operands := []Query{first}
+	for p.accept(tokenAnd) {
+		q, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, q)
+	}
+
+	if len(operands) == 1 {
+		return first, nil
+	}
+
+	return &andQuery{operands: operands}, nil
+}
+
+func (p *queryParser) parseUnary() (Query, error) {
+	if p.accept(tokenNot) {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &notQuery{operand: operand}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (Query, error) {
+	if p.done() {
+		return nil, errors.New("unexpected end of query")
+	}
+
+	t := p.peek()
+	switch t.kind {
+	case tokenTerm:
+		p.next++
+		return &termQuery{pattern: t.value}, nil
+	case tokenOpen:
+		p.next++
+		q, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(tokenClose) {
+			return nil, errors.New("missing closing parenthesis in query")
+		}
+
+		return q, nil
+	case tokenAnd, tokenOr, tokenNot, tokenClose:
+		return nil, fmt.Errorf("unexpected %q in query", t.value)
+	default:
+		return nil, fmt.Errorf("unknown token %q in query", t.value)
+	}
+}
+
+type queryMatcher struct {
+	matcher Matcher
+	queries map[string]Query
+	logger  zerolog.Logger
+}
+
+var _ Matcher = (*queryMatcher)(nil)
+
+func newQueryMatcher(logger zerolog.Logger, matcher Matcher) *queryMatcher {
+	logger = logger.With().Str("source", "QueryMatcher").Logger()
+
+	return &queryMatcher{
+		matcher: matcher,
+		queries: map[string]Query{},
+		logger:  logger,
+	}
+}
+
+// Match treats the pattern as a boolean query, which is satisfied or not by the file as a whole.
+// The positions returned are those of terms which are not negated in the query.
+func (qm *queryMatcher) Match(pattern string, next *types.FileInfo) (*Match, bool) {
+	logger := qm.logger.With().Str("func", "Match").Logger()
+
+	query, ok := qm.queries[pattern]
+	if !ok {
+		q, err := ParseQuery(pattern)
+		if err != nil {
+			logger.Error().Err(err).Str("query", pattern).Msg("unable to parse query")
+			return nil, false
+		}
+
+		query = q
+		qm.queries[pattern] = query
+	}
+
+	// Each term reads the file afresh, which a streamed body does not allow
+	if _, err := next.Contents(); err != nil {
+		logger.Error().Err(err).Str("filename", next.Path).Msg("unable to read file")
+		return nil, false
+	}
+
+	results := map[string]*Match{}
+	lookup := func(term string) *Match {
+		if m, ok := results[term]; ok {
+			return m
+		}
+
+		m, ok := qm.matcher.Match(term, next)
+		if !ok {
+			m = nil
+		}
+		results[term] = m
+
+		return m
+	}
+
+	if !query.evaluate(lookup) {
+		return nil, false
+	}
+
+	return &Match{
+		Positions: sortPositions(query.positions(lookup)),
+	}, true
+}
+
+// sortPositions orders positions by where they start in a file, removing any duplicates.
+func sortPositions(positions []*FilePosition) []*FilePosition {
+	sort.SliceStable(positions, func(i, j int) bool {
+		if positions[i].Line != positions[j].Line {
+			return positions[i].Line < positions[j].Line
+		}
+
+		return positions[i].ColumnStart < positions[j].ColumnStart
+	})
+
+	deduplicated := make([]*FilePosition, 0, len(positions))
+	for idx, p := range positions {
+		if idx > 0 && isSameSpan(p, positions[idx-1]) {
+			continue
+		}
+		deduplicated = append(deduplicated, p)
+	}
+
+	return deduplicated
+}
+
+func isSameSpan(p *FilePosition, other *FilePosition) bool {
+	return p.Line == other.Line &&
+		p.LineEnd == other.LineEnd &&
+		p.ColumnStart == other.ColumnStart &&
+		p.ColumnEnd == other.ColumnEnd
+}
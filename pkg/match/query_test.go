@@ -0,0 +1,238 @@
+package match
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agrski/greg/pkg/types"
+)
+
+func TestParseQuery(t *testing.T) {
+	type test struct {
+		name     string
+		query    string
+		expected string
+		wantErr  bool
+	}
+
+	tests := []test{
+		{
+			name:     "single bare term",
+			query:    "TODO",
+			expected: `"TODO"`,
+		},
+		{
+			name:     "single quoted term with spaces",
+			query:    `"defer db.Close"`,
+			expected: `"defer db.Close"`,
+		},
+		{
+			name:     "quoted term with escaped quote",
+			query:    `"say \"hi\""`,
+			expected: `"say \"hi\""`,
+		},
+		{
+			name:     "lower-case keywords are terms",
+			query:    `"and" AND or`,
+			expected: `(AND "and" "or")`,
+		},
+		{
+			name:     "disjunction",
+			query:    "TODO OR FIXME",
+			expected: `(OR "TODO" "FIXME")`,
+		},
+		{
+			name:     "conjunction with negation",
+			query:    `sql.Open AND NOT "defer db.Close"`,
+			expected: `(AND "sql.Open" (NOT "defer db.Close"))`,
+		},
+		{
+			name:     "AND binds more tightly than OR",
+			query:    "a OR b AND c",
+			expected: `(OR "a" (AND "b" "c"))`,
+		},
+		{
+			name:     "parentheses override precedence",
+			query:    "(a OR b) AND c",
+			expected: `(AND (OR "a" "b") "c")`,
+		},
+		{
+			name:     "chained operators are flattened",
+			query:    "a OR b OR c",
+			expected: `(OR "a" "b" "c")`,
+		},
+		{
+			name:     "double negation",
+			query:    "NOT NOT a",
+			expected: `(NOT (NOT "a"))`,
+		},
+		{
+			name:     "parentheses delimit bare terms",
+			query:    "(a)AND(b)",
+			expected: `(AND "a" "b")`,
+		},
+		{
+			name:    "empty query fails",
+			query:   "  ",
+			wantErr: true,
+		},
+		{
+			name:    "adjacent terms without operator fail",
+			query:   "a b",
+			wantErr: true,
+		},
+		{
+			name:    "dangling operator fails",
+			query:   "a AND",
+			wantErr: true,
+		},
+		{
+			name:    "leading operator fails",
+			query:   "OR a",
+			wantErr: true,
+		},
+		{
+			name:    "unbalanced opening parenthesis fails",
+			query:   "(a OR b",
+			wantErr: true,
+		},
+		{
+			name:    "unbalanced closing parenthesis fails",
+			query:   "a OR b)",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated quote fails",
+			query:   `"a OR b`,
+			wantErr: true,
+		},
+		{
+			name:    "empty quoted term fails",
+			query:   `""`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ParseQuery(tt.query)
+
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual.String())
+		})
+	}
+}
+
+func TestQueryMatch(t *testing.T) {
+	type test struct {
+		name          string
+		text          string
+		query         string
+		expectedOk    bool
+		expectedLines []uint
+	}
+
+	text := `db, err := sql.Open("postgres", dsn)
+// TODO handle error
+defer db.Close()
+`
+
+	tests := []test{
+		{
+			name:          "single term behaves like plain matching",
+			text:          text,
+			query:         "TODO",
+			expectedOk:    true,
+			expectedLines: []uint{1},
+		},
+		{
+			name:       "conjunction with negation rejects file containing negated term",
+			text:       text,
+			query:      `sql.Open AND NOT "defer db.Close"`,
+			expectedOk: false,
+		},
+		{
+			name:          "conjunction with negation accepts file without negated term",
+			text:          `db, err := sql.Open("postgres", dsn)`,
+			query:         `sql.Open AND NOT "defer db.Close"`,
+			expectedOk:    true,
+			expectedLines: []uint{0},
+		},
+		{
+			name:          "disjunction reports only terms which are present",
+			text:          text,
+			query:         "TODO OR FIXME",
+			expectedOk:    true,
+			expectedLines: []uint{1},
+		},
+		{
+			name:          "conjunction reports all terms in order",
+			text:          text,
+			query:         `Close AND sql AND TODO`,
+			expectedOk:    true,
+			expectedLines: []uint{0, 1, 2},
+		},
+		{
+			name:          "negated terms are not reported",
+			text:          text,
+			query:         `TODO AND NOT (FIXME OR XXX)`,
+			expectedOk:    true,
+			expectedLines: []uint{1},
+		},
+		{
+			name:          "unsatisfied branch of disjunction is not reported",
+			text:          text,
+			query:         `TODO OR (sql AND NOT Close)`,
+			expectedOk:    true,
+			expectedLines: []uint{1},
+		},
+		{
+			name:          "purely negative query matches without positions",
+			text:          text,
+			query:         `NOT FIXME`,
+			expectedOk:    true,
+			expectedLines: []uint{},
+		},
+		{
+			name:          "repeated term is reported once",
+			text:          text,
+			query:         `TODO OR (TODO AND db)`,
+			expectedOk:    true,
+			expectedLines: []uint{0, 1, 2},
+		},
+		{
+			name:       "invalid query matches nothing",
+			text:       text,
+			query:      `TODO AND`,
+			expectedOk: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileInfo := &types.FileInfo{Text: tt.text}
+			matcher := newQueryMatcher(zerolog.Nop(), newExactMatcher(zerolog.Nop(), false, 0))
+
+			actual, ok := matcher.Match(tt.query, fileInfo)
+
+			require.Equal(t, tt.expectedOk, ok)
+			if !tt.expectedOk {
+				require.Nil(t, actual)
+				return
+			}
+
+			actualLines := []uint{}
+			for _, p := range actual.Positions {
+				actualLines = append(actualLines, p.Line)
+			}
+			require.Equal(t, tt.expectedLines, actualLines)
+		})
+	}
+}
@@ -0,0 +1,344 @@
+package match
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/agrski/greg/pkg/types"
+)
+
+// Scope restricts matches to particular kinds of source text.
+type Scope int
+
+const (
+	ScopeAll Scope = iota
+	ScopeCode
+	ScopeComments
+	ScopeStrings
+)
+
+func ParseScope(s string) (Scope, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "all":
+		return ScopeAll, nil
+	case "code":
+		return ScopeCode, nil
+	case "comment", "comments":
+		return ScopeComments, nil
+	case "string", "strings":
+		return ScopeStrings, nil
+	default:
+		return ScopeAll, fmt.Errorf("unknown scope '%s'; expected one of all, code, comments, or strings", s)
+	}
+}
+
+type regionKind int
+
+const (
+	regionCode regionKind = iota
+	regionComment
+	regionString
+)
+
+// region is a half-open range of bytes [start, end) in a file.
+type region struct {
+	start int
+	end   int
+	kind  regionKind
+}
+
+type stringDelimiter struct {
+	open      string
+	close     string
+	escape    byte
+	multiline bool
+}
+
+// language describes just enough of a language's syntax to distinguish comments and string literals from code.
+type language struct {
+	lineComments  []string
+	blockComments [][2]string
+	// Delimiters are tried in order, so longer delimiters should precede their prefixes.
+	strings []stringDelimiter
+	// commentsNeedSpace indicates that comments only start at the beginning of a line or after whitespace.
+	commentsNeedSpace bool
+	// stringsNeedBoundary indicates that quotes only start a string at the beginning of a value.
+	stringsNeedBoundary bool
+}
+
+var (
+	cFamilyComments = [][2]string{{"/*", "*/"}}
+
+	languageGo = &language{
+		lineComments:  []string{"//"},
+		blockComments: cFamilyComments,
+		strings: []stringDelimiter{
+			{open: `"`, close: `"`, escape: '\\'},
+			{open: `'`, close: `'`, escape: '\\'},
+			{open: "`", close: "`", multiline: true},
+		},
+	}
+	languagePython = &language{
+		lineComments: []string{"#"},
+		strings: []stringDelimiter{
+			{open: `"""`, close: `"""`, escape: '\\', multiline: true},
+			{open: `'''`, close: `'''`, escape: '\\', multiline: true},
+			{open: `"`, close: `"`, escape: '\\'},
+			{open: `'`, close: `'`, escape: '\\'},
+		},
+	}
+	languageJavaScript = &language{
+		lineComments:  []string{"//"},
+		blockComments: cFamilyComments,
+		strings: []stringDelimiter{
+			{open: `"`, close: `"`, escape: '\\'},
+			{open: `'`, close: `'`, escape: '\\'},
+			{open: "`", close: "`", escape: '\\', multiline: true},
+		},
+	}
+	languageJava = &language{
+		lineComments:  []string{"//"},
+		blockComments: cFamilyComments,
+		strings: []stringDelimiter{
+			{open: `"""`, close: `"""`, escape: '\\', multiline: true},
+			{open: `"`, close: `"`, escape: '\\'},
+			{open: `'`, close: `'`, escape: '\\'},
+		},
+	}
+	languageShell = &language{
+		lineComments: []string{"#"},
+		strings: []stringDelimiter{
+			{open: `"`, close: `"`, escape: '\\', multiline: true},
+			{open: `'`, close: `'`, multiline: true},
+		},
+		commentsNeedSpace: true,
+	}
+	languageYAML = &language{
+		lineComments: []string{"#"},
+		strings: []stringDelimiter{
+			{open: `"`, close: `"`, escape: '\\', multiline: true},
+			// Escaped quotes are doubled, which lexes as two adjacent strings
+			{open: `'`, close: `'`, multiline: true},
+		},
+		commentsNeedSpace:   true,
+		stringsNeedBoundary: true,
+	}
+)
+
+var languagesByExtension = map[types.FileExtension]*language{
+	"go":     languageGo,
+	"py":     languagePython,
+	"pyi":    languagePython,
+	"js":     languageJavaScript,
+	"jsx":    languageJavaScript,
+	"mjs":    languageJavaScript,
+	"cjs":    languageJavaScript,
+	"ts":     languageJavaScript,
+	"tsx":    languageJavaScript,
+	"mts":    languageJavaScript,
+	"cts":    languageJavaScript,
+	"java":   languageJava,
+	"sh":     languageShell,
+	"bash":   languageShell,
+	"zsh":    languageShell,
+	"ksh":    languageShell,
+	"bashrc": languageShell,
+	"zshrc":  languageShell,
+	"yaml":   languageYAML,
+	"yml":    languageYAML,
+}
+
+func languageFor(next *types.FileInfo) (*language, bool) {
+	extension := next.Extension
+	if extension == "" {
+		extension = types.FileExtension(path.Ext(next.Path))
+	}
+
+	l, ok := languagesByExtension[NormaliseExtension(extension)]
+
+	return l, ok
+}
+
+// regions divides text into comments and string literals, with everything else being code.
+// Only non-code regions are returned, in order.
+func (l *language) regions(text string) []region {
+	regions := []region{}
+
+	for idx := 0; idx < len(text); {
+		if end, ok := l.matchComment(text, idx); ok {
+			regions = append(regions, region{start: idx, end: end, kind: regionComment})
+			idx = end
+			continue
+		}
+
+		if end, ok := l.matchString(text, idx); ok {
+			regions = append(regions, region{start: idx, end: end, kind: regionString})
+			idx = end
+			continue
+		}
+
+		idx++
+	}
+
+	return regions
+}
+
+func (l *language) matchComment(text string, idx int) (int, bool) {
+	if l.commentsNeedSpace && idx > 0 && !isSpace(text[idx-1]) {
+		return 0, false
+	}
+
+	for _, bc := range l.blockComments {
+		if strings.HasPrefix(text[idx:], bc[0]) {
+			closing := strings.Index(text[idx+len(bc[0]):], bc[1])
+			if closing == -1 {
+				return len(text), true
+			}
+
+			return idx + len(bc[0]) + closing + len(bc[1]), true
+		}
+	}
+
+	for _, lc := range l.lineComments {
+		if strings.HasPrefix(text[idx:], lc) {
+			newline := strings.IndexByte(text[idx:], '\n')
+			if newline == -1 {
+				return len(text), true
+			}
+
+			return idx + newline, true
+		}
+	}
+
+	return 0, false
+}
+
+func (l *language) matchString(text string, idx int) (int, bool) {
+	if l.stringsNeedBoundary && idx > 0 && !isValueBoundary(text[idx-1]) {
+		return 0, false
+	}
+
+	for _, sd := range l.strings {
+		if !strings.HasPrefix(text[idx:], sd.open) {
+			continue
+		}
+
+		for end := idx + len(sd.open); end < len(text); end++ {
+			switch {
+			case sd.escape != 0 && text[end] == sd.escape:
+				end++
+			case strings.HasPrefix(text[end:], sd.close):
+				return end + len(sd.close), true
+			case text[end] == '\n' && !sd.multiline:
+				// Unterminated literals end with the line
+				return end, true
+			}
+		}
+
+		return len(text), true
+	}
+
+	return 0, false
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+func isValueBoundary(b byte) bool {
+	return isSpace(b) || strings.IndexByte(":-[{,", b) != -1
+}
+
+// kindOf returns the kind of region containing the whole of [start, end).
+// Spans crossing from one region into another, including via code, have no single kind.
+func kindOf(regions []region, start int, end int) (regionKind, bool) {
+	idx := sort.Search(len(regions), func(i int) bool {
+		return regions[i].end > start
+	})
+
+	if idx < len(regions) && regions[idx].start <= start {
+		return regions[idx].kind, end <= regions[idx].end
+	}
+
+	return regionCode, idx == len(regions) || regions[idx].start >= end
+}
+
+type scopeMatcher struct {
+	matcher Matcher
+	scope   Scope
+	logger  zerolog.Logger
+}
+
+var _ Matcher = (*scopeMatcher)(nil)
+
+func newScopeMatcher(logger zerolog.Logger, matcher Matcher, scope Scope) *scopeMatcher {
+	logger = logger.With().Str("source", "ScopeMatcher").Logger()
+
+	return &scopeMatcher{
+		matcher: matcher,
+		scope:   scope,
+		logger:  logger,
+	}
+}
+
+// Match keeps only those positions which lie entirely within the desired kind of text.
+// Files in languages which are not understood are rejected, as their matches cannot be classified.
+func (sm *scopeMatcher) Match(pattern string, next *types.FileInfo) (*Match, bool) {
+	logger := sm.logger.With().Str("func", "Match").Logger()
+
+	lang, ok := languageFor(next)
+	if !ok {
+		logger.Debug().Str("filename", next.Path).Msg("rejecting file in unsupported language")
+		return nil, false
+	}
+
+	// The wrapped matcher may only stream the file, but regions need all of it
+	text, err := next.Contents()
+	if err != nil {
+		logger.Error().Err(err).Str("filename", next.Path).Msg("unable to read file")
+		return nil, false
+	}
+
+	match, ok := sm.matcher.Match(pattern, next)
+	if !ok {
+		return nil, false
+	}
+
+	want := sm.regionKind()
+	regions := lang.regions(text)
+	index := newLineIndex(text)
+
+	positions := make([]*FilePosition, 0, len(match.Positions))
+	for _, p := range match.Positions {
+		start := index.lineStarts[p.Line] + int(p.ColumnStart)
+		end := index.lineStarts[p.LineEnd] + int(p.ColumnEnd)
+
+		if kind, ok := kindOf(regions, start, end); ok && kind == want {
+			positions = append(positions, p)
+		}
+	}
+
+	if len(positions) == 0 {
+		return nil, false
+	}
+	match.Positions = positions
+
+	return match, true
+}
+
+func (sm *scopeMatcher) regionKind() regionKind {
+	switch sm.scope {
+	case ScopeComments:
+		return regionComment
+	case ScopeStrings:
+		return regionString
+	case ScopeAll, ScopeCode:
+		return regionCode
+	default:
+		return regionCode
+	}
+}
@@ -0,0 +1,231 @@
+package match
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agrski/greg/pkg/types"
+)
+
+func TestParseScope(t *testing.T) {
+	type test struct {
+		name     string
+		scope    string
+		expected Scope
+		wantErr  bool
+	}
+
+	tests := []test{
+		{name: "empty means all", scope: "", expected: ScopeAll},
+		{name: "all", scope: "all", expected: ScopeAll},
+		{name: "code", scope: "code", expected: ScopeCode},
+		{name: "comments", scope: "comments", expected: ScopeComments},
+		{name: "singular comment", scope: "comment", expected: ScopeComments},
+		{name: "strings with whitespace and capitals", scope: " Strings ", expected: ScopeStrings},
+		{name: "unknown scope fails", scope: "identifiers", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ParseScope(tt.scope)
+
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestScopeMatch(t *testing.T) {
+	type test struct {
+		name          string
+		extension     types.FileExtension
+		text          string
+		pattern       string
+		scope         Scope
+		expectedOk    bool
+		expectedLines []uint
+	}
+
+	goText := `package main
+
+// Get fetches the secret
+func main() {
+	http.Get("https://example.com/secret") /* secret */
+	s := ` + "`raw\nsecret`" + `
+	r := 'x' // http.Get
+}
+`
+
+	tests := []test{
+		{
+			name:          "go code excludes comments and strings",
+			extension:     ".go",
+			text:          goText,
+			pattern:       "http.Get",
+			scope:         ScopeCode,
+			expectedOk:    true,
+			expectedLines: []uint{4},
+		},
+		{
+			name:          "go comments include line and block comments",
+			extension:     ".go",
+			text:          goText,
+			pattern:       "secret",
+			scope:         ScopeComments,
+			expectedOk:    true,
+			expectedLines: []uint{2, 4},
+		},
+		{
+			name:          "go strings include raw multi-line strings",
+			extension:     ".go",
+			text:          goText,
+			pattern:       "secret",
+			scope:         ScopeStrings,
+			expectedOk:    true,
+			expectedLines: []uint{4, 6},
+		},
+		{
+			name:       "match straddling code and string is rejected",
+			extension:  ".go",
+			text:       goText,
+			pattern:    `Get("https`,
+			scope:      ScopeStrings,
+			expectedOk: false,
+		},
+		{
+			name:       "match crossing code between two strings is rejected",
+			extension:  ".go",
+			text:       `f("key", x, "value")`,
+			pattern:    `key", x, "value`,
+			scope:      ScopeStrings,
+			expectedOk: false,
+		},
+		{
+			name:       "match crossing a string between code is rejected",
+			extension:  ".go",
+			text:       `f("key", x)`,
+			pattern:    `f("key", x`,
+			scope:      ScopeCode,
+			expectedOk: false,
+		},
+		{
+			name:          "python triple-quoted strings and hash comments",
+			extension:     ".py",
+			text:          "x = '''token\n# not a comment\n'''  # token\ntoken = 1\n",
+			pattern:       "token",
+			scope:         ScopeStrings,
+			expectedOk:    true,
+			expectedLines: []uint{0},
+		},
+		{
+			name:          "python comments after strings",
+			extension:     ".py",
+			text:          "x = '''token\n# not a comment\n'''  # token\ntoken = 1\n",
+			pattern:       "token",
+			scope:         ScopeComments,
+			expectedOk:    true,
+			expectedLines: []uint{2},
+		},
+		{
+			name:          "escaped quotes do not end strings",
+			extension:     ".js",
+			text:          `const s = "say \"key\" twice"; key()`,
+			pattern:       "key",
+			scope:         ScopeCode,
+			expectedOk:    true,
+			expectedLines: []uint{0},
+		},
+		{
+			name:          "typescript template literals are strings",
+			extension:     ".ts",
+			text:          "const s = `multi\nline key`;\nkey();\n",
+			pattern:       "key",
+			scope:         ScopeStrings,
+			expectedOk:    true,
+			expectedLines: []uint{1},
+		},
+		{
+			name:          "java text blocks are strings",
+			extension:     ".java",
+			text:          "String s = \"\"\"\n  key\n  \"\"\";\n// key\n",
+			pattern:       "key",
+			scope:         ScopeStrings,
+			expectedOk:    true,
+			expectedLines: []uint{1},
+		},
+		{
+			name:          "shell hash only starts comments after whitespace",
+			extension:     ".sh",
+			text:          "echo ${#args} # args\n# args\n",
+			pattern:       "args",
+			scope:         ScopeComments,
+			expectedOk:    true,
+			expectedLines: []uint{0, 1},
+		},
+		{
+			name:          "shell hash within word is code",
+			extension:     ".sh",
+			text:          "echo ${#args} # args\n# args\n",
+			pattern:       "args",
+			scope:         ScopeCode,
+			expectedOk:    true,
+			expectedLines: []uint{0},
+		},
+		{
+			name:          "yaml apostrophes within plain scalars are code",
+			extension:     ".yaml",
+			text:          "name: it's a password\nkey: 'password' # password\n",
+			pattern:       "password",
+			scope:         ScopeCode,
+			expectedOk:    true,
+			expectedLines: []uint{0},
+		},
+		{
+			name:          "yaml quoted scalars are strings",
+			extension:     ".yml",
+			text:          "name: it's a password\nkey: 'password' # password\n",
+			pattern:       "password",
+			scope:         ScopeStrings,
+			expectedOk:    true,
+			expectedLines: []uint{1},
+		},
+		{
+			name:       "unsupported language is rejected",
+			extension:  ".md",
+			text:       "password",
+			pattern:    "password",
+			scope:      ScopeCode,
+			expectedOk: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileInfo := &types.FileInfo{
+				Extension: tt.extension,
+				Text:      tt.text,
+			}
+			matcher := newScopeMatcher(zerolog.Nop(), newExactMatcher(zerolog.Nop(), false, 0), tt.scope)
+
+			actual, ok := matcher.Match(tt.pattern, fileInfo)
+
+			require.Equal(t, tt.expectedOk, ok)
+			if !tt.expectedOk {
+				require.Nil(t, actual)
+				return
+			}
+
+			actualLines := []uint{}
+			for _, p := range actual.Positions {
+				actualLines = append(actualLines, p.Line)
+			}
+			require.Equal(t, tt.expectedLines, actualLines)
+		})
+	}
+}
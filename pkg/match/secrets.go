@@ -0,0 +1,314 @@
+package match
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/agrski/greg/pkg/types"
+)
+
+const (
+	redactedPrefixLength = 4
+	redactedMask         = "*****"
+)
+
+// SecretRule describes one kind of secret to detect.
+// When Pattern has a capturing group, the first group is the secret; otherwise the whole match is.
+type SecretRule struct {
+	ID          string
+	Description string
+	Pattern     *regexp.Regexp
+	// MinEntropy is the minimum Shannon entropy, in bits per character, a secret must have to be reported.
+	MinEntropy float64
+}
+
+var builtinSecretRules = []*SecretRule{
+	{
+		ID:          "aws-access-key-id",
+		Description: "AWS access key ID",
+		Pattern:     regexp.MustCompile(`\b((?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16})\b`),
+	},
+	{
+		ID:          "aws-secret-access-key",
+		Description: "AWS secret access key",
+		Pattern: regexp.MustCompile(
+			`(?i)aws_?(?:secret)?_?(?:access)?_?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})\b`,
+		),
+		MinEntropy: 3.5,
+	},
+	{
+		ID:          "github-pat",
+		Description: "GitHub personal access token",
+		Pattern:     regexp.MustCompile(`\b(ghp_[A-Za-z0-9]{36})\b`),
+	},
+	{
+		ID:          "github-fine-grained-pat",
+		Description: "GitHub fine-grained personal access token",
+		Pattern:     regexp.MustCompile(`\b(github_pat_[A-Za-z0-9_]{82})\b`),
+	},
+	{
+		ID:          "github-oauth-token",
+		Description: "GitHub OAuth access token",
+		Pattern:     regexp.MustCompile(`\b(gho_[A-Za-z0-9]{36})\b`),
+	},
+	{
+		ID:          "github-app-token",
+		Description: "GitHub App user-to-server or server-to-server token",
+		Pattern:     regexp.MustCompile(`\b((?:ghu|ghs)_[A-Za-z0-9]{36})\b`),
+	},
+	{
+		ID:          "github-refresh-token",
+		Description: "GitHub refresh token",
+		Pattern:     regexp.MustCompile(`\b(ghr_[A-Za-z0-9]{36})\b`),
+	},
+	{
+		ID:          "private-key",
+		Description: "Private key header",
+		Pattern:     regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----`),
+	},
+	{
+		ID:          "generic-high-entropy-secret",
+		Description: "High-entropy string assigned to a secret-like name",
+		Pattern: regexp.MustCompile(
+			`(?i)(?:api|access|auth|client|private|secret)?_?(?:key|secret|token|password|passwd|pwd)["']?\s*[:=]+\s*["']([A-Za-z0-9+/_.=-]{16,})["']`,
+		),
+		MinEntropy: 3.5,
+	},
+}
+
+// DefaultSecretRules returns a copy of the built-in secret rules, which callers may safely modify.
+func DefaultSecretRules() []*SecretRule {
+	rules := make([]*SecretRule, len(builtinSecretRules))
+	for idx, r := range builtinSecretRules {
+		rule := *r
+		rules[idx] = &rule
+	}
+
+	return rules
+}
+
+type rawSecretRule struct {
+	ID          string  `json:"id"`
+	Description string  `json:"description"`
+	Regex       string  `json:"regex"`
+	MinEntropy  float64 `json:"minEntropy"`
+}
+
+// ParseSecretRules reads custom secret rules from a JSON array of objects with the fields
+// id, description, regex, and optionally minEntropy.
+func ParseSecretRules(r io.Reader) ([]*SecretRule, error) {
+	raw := []*rawSecretRule{}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("unable to parse secret rules: %w", err)
+	}
+
+	rules := make([]*SecretRule, 0, len(raw))
+	seen := map[string]struct{}{}
+	for idx, r := range raw {
+		id := strings.TrimSpace(r.ID)
+		if id == "" {
+			return nil, fmt.Errorf("secret rule %d has no id", idx)
+		}
+		if _, ok := seen[id]; ok {
+			return nil, fmt.Errorf("secret rule %s is defined more than once", id)
+		}
+		seen[id] = struct{}{}
+
+		if r.Regex == "" {
+			return nil, fmt.Errorf("secret rule %s has no regex", id)
+		}
+		pattern, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("secret rule %s has an invalid regex: %w", id, err)
+		}
+
+		if r.MinEntropy < 0 {
+			return nil, fmt.Errorf("secret rule %s cannot have a negative minimum entropy", id)
+		}
+
+		rules = append(
+			rules,
+			&SecretRule{
+				ID:          id,
+				Description: r.Description,
+				Pattern:     pattern,
+				MinEntropy:  r.MinEntropy,
+			},
+		)
+	}
+
+	return rules, nil
+}
+
+// MergeSecretRules adds the additional rules to the base ones, replacing any base rule with the same ID.
+func MergeSecretRules(base []*SecretRule, additional []*SecretRule) []*SecretRule {
+	replacements := map[string]*SecretRule{}
+	for _, r := range additional {
+		replacements[r.ID] = r
+	}
+
+	merged := make([]*SecretRule, 0, len(base)+len(additional))
+	for _, r := range base {
+		if replacement, ok := replacements[r.ID]; ok {
+			merged = append(merged, replacement)
+			delete(replacements, r.ID)
+		} else {
+			merged = append(merged, r)
+		}
+	}
+	for _, r := range additional {
+		if _, ok := replacements[r.ID]; ok {
+			merged = append(merged, r)
+		}
+	}
+
+	return merged
+}
+
+// RedactSecret hides all but the first few characters of a secret.
+func RedactSecret(secret string) string {
+	runes := []rune(secret)
+	if len(runes) <= redactedPrefixLength*2 {
+		return redactedMask
+	}
+
+	return string(runes[:redactedPrefixLength]) + redactedMask
+}
+
+type secretMatcher struct {
+	rules  []*SecretRule
+	logger zerolog.Logger
+}
+
+var _ Matcher = (*secretMatcher)(nil)
+
+func newSecretMatcher(logger zerolog.Logger, rules []*SecretRule) *secretMatcher {
+	logger = logger.With().Str("source", "SecretMatcher").Logger()
+	if rules == nil {
+		rules = builtinSecretRules
+	}
+
+	return &secretMatcher{
+		rules:  rules,
+		logger: logger,
+	}
+}
+
+// Match looks for secrets using the configured rules, ignoring the pattern.
+func (sm *secretMatcher) Match(_ string, next *types.FileInfo) (*Match, bool) {
+	logger := sm.logger.With().Str("func", "Match").Logger()
+
+	if next.IsBinary {
+		logger.Debug().Str("filename", next.Path).Msg("rejecting binary file")
+		return nil, false
+	}
+
+	text, err := next.Contents()
+	if err != nil {
+		logger.Error().Err(err).Str("filename", next.Path).Msg("unable to read file")
+		return nil, false
+	}
+
+	match := &Match{}
+	for row, line := range splitLines(text) {
+		match.Positions = append(match.Positions, sm.matchLine(uint(row), line)...)
+	}
+
+	if len(match.Positions) == 0 {
+		return nil, false
+	}
+
+	match.Positions = sortPositions(match.Positions)
+
+	return match, true
+}
+
+// matchLine finds the secrets in a line, in the order of the rules.
+// Rules are ordered from most to least specific, so where spans overlap, only the first rule to find one is kept.
+func (sm *secretMatcher) matchLine(row uint, line string) []*FilePosition {
+	positions := []*FilePosition{}
+
+	for _, rule := range sm.rules {
+		for _, indices := range rule.Pattern.FindAllStringSubmatchIndex(line, -1) {
+			start, end, err := secretSpan(indices)
+			if err != nil {
+				continue
+			}
+
+			if shannonEntropy(line[start:end]) < rule.MinEntropy {
+				continue
+			}
+
+			if overlapsAny(positions, uint(start), uint(end)) {
+				continue
+			}
+
+			positions = append(
+				positions,
+				&FilePosition{
+					Line:        row,
+					LineEnd:     row,
+					ColumnStart: uint(start),
+					ColumnEnd:   uint(end),
+					Text:        line,
+					RuleID:      rule.ID,
+				},
+			)
+		}
+	}
+
+	return positions
+}
+
+func overlapsAny(positions []*FilePosition, start uint, end uint) bool {
+	for _, p := range positions {
+		if start < p.ColumnEnd && p.ColumnStart < end {
+			return true
+		}
+	}
+
+	return false
+}
+
+// secretSpan picks the first capturing group out of a regex match, if there is one.
+func secretSpan(indices []int) (int, int, error) {
+	start, end := indices[0], indices[1]
+	if len(indices) > 2 {
+		start, end = indices[2], indices[3]
+	}
+
+	if start < 0 || start == end {
+		return 0, 0, errors.New("empty secret")
+	}
+
+	return start, end, nil
+}
+
+// shannonEntropy is the average information content of s in bits per character.
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+
+	counts := map[rune]int{}
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+
+	return entropy
+}
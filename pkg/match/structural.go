@@ -0,0 +1,481 @@
+package match
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+
+	"github.com/agrski/greg/pkg/types"
+)
+
+/*
+	Structural patterns match code by its shape rather than its exact text, e.g.
+
+		http.Get(:[url])
+		if err != nil { return :[_] }
+
+	Holes, written :[name], match any text with balanced brackets and string literals,
+	so :[url] above matches the whole argument list, however many nested calls it contains.
+	Holes named _ match in the same way but are not captured.
+	Whitespace in a pattern matches any amount of whitespace in the text, including line breaks.
+
+	This works for C-family syntax, including Go, Java, and JavaScript.
+	Comments and string literals are recognised in the languages understood by scopes;
+	in other files, only double quotes and backticks delimit strings.
+*/
+
+const anonymousHole = "_"
+
+type structuralElementKind int
+
+const (
+	elementLiteral structuralElementKind = iota
+	elementWhitespace
+	elementHole
+)
+
+type structuralElement struct {
+	kind  structuralElementKind
+	value string
+	// wordBoundary indicates that whitespace separates two words, so cannot be omitted.
+	wordBoundary bool
+}
+
+// StructuralPattern is a parsed structural pattern, ready for matching.
+type StructuralPattern struct {
+	elements []*structuralElement
+}
+
+// Capture is the text matched by a named hole in a structural pattern.
+type Capture struct {
+	Name string
+	Text string
+}
+
+// ParseStructuralPattern validates and parses a structural pattern.
+// Patterns must begin and end with literal text, and holes must be separated by literal text.
+func ParseStructuralPattern(raw string) (*StructuralPattern, error) {
+	elements := []*structuralElement{}
+
+	for idx := 0; idx < len(raw); {
+		switch {
+		case isSpace(raw[idx]):
+			end := idx
+			for end < len(raw) && isSpace(raw[end]) {
+				end++
+			}
+			elements = append(elements, &structuralElement{kind: elementWhitespace})
+			idx = end
+		case strings.HasPrefix(raw[idx:], ":["):
+			closing := strings.IndexByte(raw[idx:], ']')
+			if closing == -1 {
+				return nil, fmt.Errorf("unterminated hole in structural pattern at offset %d", idx)
+			}
+
+			name := raw[idx+2 : idx+closing]
+			if !isHoleName(name) {
+				return nil, fmt.Errorf("invalid hole name '%s' in structural pattern", name)
+			}
+
+			elements = append(elements, &structuralElement{kind: elementHole, value: name})
+			idx += closing + 1
+		default:
+			end := idx + 1
+			for end < len(raw) && !isSpace(raw[end]) && !strings.HasPrefix(raw[end:], ":[") {
+				end++
+			}
+
+			if n := len(elements); n > 0 && elements[n-1].kind == elementLiteral {
+				elements[n-1].value += raw[idx:end]
+			} else {
+				elements = append(elements, &structuralElement{kind: elementLiteral, value: raw[idx:end]})
+			}
+			idx = end
+		}
+	}
+
+	elements = trimWhitespaceElements(elements)
+
+	if len(elements) == 0 {
+		return nil, errors.New("structural pattern cannot be empty")
+	}
+	if elements[0].kind != elementLiteral || elements[len(elements)-1].kind != elementLiteral {
+		return nil, errors.New("structural pattern must begin and end with literal text")
+	}
+
+	for idx, e := range elements {
+		switch e.kind {
+		case elementHole:
+			if next := nextNonWhitespace(elements, idx); next != nil && next.kind == elementHole {
+				return nil, errors.New("holes in structural pattern must be separated by literal text")
+			}
+		case elementWhitespace:
+			previous, next := elements[idx-1], elements[idx+1]
+			e.wordBoundary = previous.kind == elementLiteral && next.kind == elementLiteral &&
+				endsWithWordChar(previous.value) && startsWithWordChar(next.value)
+		case elementLiteral:
+		}
+	}
+
+	return &StructuralPattern{elements: elements}, nil
+}
+
+func trimWhitespaceElements(elements []*structuralElement) []*structuralElement {
+	for len(elements) > 0 && elements[0].kind == elementWhitespace {
+		elements = elements[1:]
+	}
+	for len(elements) > 0 && elements[len(elements)-1].kind == elementWhitespace {
+		elements = elements[:len(elements)-1]
+	}
+
+	return elements
+}
+
+func nextNonWhitespace(elements []*structuralElement, idx int) *structuralElement {
+	for _, e := range elements[idx+1:] {
+		if e.kind != elementWhitespace {
+			return e
+		}
+	}
+
+	return nil
+}
+
+func isHoleName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, r := range name {
+		if !isWordChar(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isWordChar(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func startsWithWordChar(s string) bool {
+	for _, r := range s {
+		return isWordChar(r)
+	}
+
+	return false
+}
+
+func endsWithWordChar(s string) bool {
+	runes := []rune(s)
+
+	return len(runes) > 0 && isWordChar(runes[len(runes)-1])
+}
+
+type structuralMatcher struct {
+	caseInsensitive bool
+	patterns        map[string]*StructuralPattern
+	logger          zerolog.Logger
+}
+
+var _ Matcher = (*structuralMatcher)(nil)
+
+func newStructuralMatcher(logger zerolog.Logger, caseInsensitive bool) *structuralMatcher {
+	logger = logger.With().Str("source", "StructuralMatcher").Logger()
+
+	return &structuralMatcher{
+		caseInsensitive: caseInsensitive,
+		patterns:        map[string]*StructuralPattern{},
+		logger:          logger,
+	}
+}
+
+func (sm *structuralMatcher) Match(pattern string, next *types.FileInfo) (*Match, bool) {
+	logger := sm.logger.With().Str("func", "Match").Logger()
+
+	if next.IsBinary {
+		logger.Debug().Str("filename", next.Path).Msg("rejecting binary file")
+		return nil, false
+	}
+
+	sp, ok := sm.patterns[pattern]
+	if !ok {
+		parsed, err := ParseStructuralPattern(pattern)
+		if err != nil {
+			logger.Error().Err(err).Str("pattern", pattern).Msg("unable to parse structural pattern")
+			return nil, false
+		}
+
+		sp = parsed
+		sm.patterns[pattern] = sp
+	}
+
+	text, err := next.Contents()
+	if err != nil {
+		logger.Error().Err(err).Str("filename", next.Path).Msg("unable to read file")
+		return nil, false
+	}
+
+	search := &structuralSearch{
+		matcher:  sm,
+		elements: sp.elements,
+		text:     text,
+		literals: literalsIn(next, text),
+		failed:   map[structuralState]bool{},
+	}
+	index := newLineIndex(text)
+	match := &Match{}
+	candidates := sm.newCandidates(sp.elements[0].value)
+
+	for offset := 0; offset < len(text); {
+		found := candidates(text[offset:])
+		if found == -1 {
+			break
+		}
+
+		start := offset + found
+		end, captures, ok := search.matchElements(0, start)
+		if !ok {
+			offset = start + 1
+			continue
+		}
+
+		position := index.position(start, end)
+		position.Captures = captures
+		match.Positions = append(match.Positions, position)
+
+		offset = end
+	}
+
+	if len(match.Positions) == 0 {
+		return nil, false
+	}
+
+	return match, true
+}
+
+// newCandidates gives a function finding where a match might start in text, by the pattern's first literal.
+// Case-insensitive matching happens on the text itself, as lowering it can change the lengths of characters
+// and so move the offsets of everything after them.
+func (sm *structuralMatcher) newCandidates(first string) func(text string) int {
+	if !sm.caseInsensitive {
+		return func(text string) int {
+			return strings.Index(text, first)
+		}
+	}
+
+	re := regexp.MustCompile("(?i)" + regexp.QuoteMeta(first))
+
+	return func(text string) int {
+		loc := re.FindStringIndex(text)
+		if loc == nil {
+			return -1
+		}
+
+		return loc[0]
+	}
+}
+
+// languageFallback is used to find string literals in files in languages which are not understood.
+// Single quotes are too often apostrophes to be treated as delimiters without knowing the language.
+var languageFallback = &language{
+	strings: []stringDelimiter{
+		{open: `"`, close: `"`, escape: '\\'},
+		{open: "`", close: "`", multiline: true},
+	},
+}
+
+// literalsIn maps the start of each comment and string literal in text to its end.
+func literalsIn(next *types.FileInfo, text string) map[int]int {
+	lang, ok := languageFor(next)
+	if !ok {
+		lang = languageFallback
+	}
+
+	regions := lang.regions(text)
+	literals := make(map[int]int, len(regions))
+	for _, r := range regions {
+		literals[r.start] = r.end
+	}
+
+	return literals
+}
+
+type structuralState struct {
+	element int
+	offset  int
+}
+
+// structuralSearch holds the state for matching a pattern against one file.
+type structuralSearch struct {
+	matcher  *structuralMatcher
+	elements []*structuralElement
+	text     string
+	// literals are skipped whole by holes, so brackets and quotes within them are not counted.
+	literals map[int]int
+	// failed records where the remaining elements cannot match, so holes do not try them again.
+	// Without this, each hole multiplies the work of those after it.
+	failed map[structuralState]bool
+}
+
+// matchElements attempts to match the pattern elements from the given one onwards at the given offset,
+// returning the end of the match and the captured holes.
+func (ss *structuralSearch) matchElements(element int, offset int) (int, []Capture, bool) {
+	if element == len(ss.elements) {
+		return offset, nil, true
+	}
+
+	// Only holes are worth remembering, as other elements fail or succeed immediately
+	if ss.elements[element].kind != elementHole {
+		return ss.matchElement(element, offset)
+	}
+
+	state := structuralState{element: element, offset: offset}
+	if ss.failed[state] {
+		return 0, nil, false
+	}
+
+	end, captures, ok := ss.matchElement(element, offset)
+	if !ok {
+		ss.failed[state] = true
+	}
+
+	return end, captures, ok
+}
+
+func (ss *structuralSearch) matchElement(element int, offset int) (int, []Capture, bool) {
+	e := ss.elements[element]
+	text := ss.text
+
+	switch e.kind {
+	case elementLiteral:
+		length, ok := ss.matcher.hasPrefix(text[offset:], e.value)
+		if !ok {
+			return 0, nil, false
+		}
+
+		return ss.matchElements(element+1, offset+length)
+	case elementWhitespace:
+		end := offset
+		for end < len(text) && isSpace(text[end]) {
+			end++
+		}
+		if e.wordBoundary && end == offset {
+			return 0, nil, false
+		}
+
+		return ss.matchElements(element+1, end)
+	case elementHole:
+		// Extend the hole one balanced unit at a time until the rest of the pattern matches
+		for end := offset; end <= len(text); {
+			if restEnd, captures, ok := ss.matchElements(element+1, end); ok {
+				if e.value != anonymousHole {
+					captured := Capture{Name: e.value, Text: strings.TrimSpace(text[offset:end])}
+					captures = append([]Capture{captured}, captures...)
+				}
+
+				return restEnd, captures, true
+			}
+
+			next, ok := ss.skipBalanced(end)
+			if !ok {
+				break
+			}
+			end = next
+		}
+
+		return 0, nil, false
+	default:
+		return 0, nil, false
+	}
+}
+
+// hasPrefix reports whether text starts with a prefix, and how long that start of the text is,
+// which may differ from the length of the prefix when ignoring case.
+func (sm *structuralMatcher) hasPrefix(text string, prefix string) (int, bool) {
+	if !sm.caseInsensitive {
+		return len(prefix), strings.HasPrefix(text, prefix)
+	}
+
+	length := 0
+	for _, p := range prefix {
+		if length >= len(text) {
+			return 0, false
+		}
+
+		r, size := utf8.DecodeRuneInString(text[length:])
+		if !equalFoldRune(r, p) {
+			return 0, false
+		}
+		length += size
+	}
+
+	return length, true
+}
+
+// equalFoldRune reports whether two characters are the same under simple Unicode case folding.
+func equalFoldRune(a rune, b rune) bool {
+	if a == b {
+		return true
+	}
+
+	for r := unicode.SimpleFold(a); r != a; r = unicode.SimpleFold(r) {
+		if r == b {
+			return true
+		}
+	}
+
+	return false
+}
+
+var closingBrackets = map[byte]byte{
+	'(': ')',
+	'[': ']',
+	'{': '}',
+}
+
+// skipBalanced returns the offset after the unit of text starting at the given offset,
+// where a unit is a bracketed group, a comment or string literal, or otherwise a single byte.
+// It fails at the end of the text, or at a closing bracket which was not opened.
+func (ss *structuralSearch) skipBalanced(offset int) (int, bool) {
+	text := ss.text
+	if offset >= len(text) {
+		return 0, false
+	}
+
+	if end, ok := ss.literals[offset]; ok {
+		return end, true
+	}
+
+	c := text[offset]
+
+	switch c {
+	case ')', ']', '}':
+		return 0, false
+	}
+
+	closing, isOpening := closingBrackets[c]
+	if !isOpening {
+		return offset + 1, true
+	}
+
+	for end := offset + 1; end < len(text); {
+		if text[end] == closing {
+			return end + 1, true
+		}
+
+		next, ok := ss.skipBalanced(end)
+		if !ok {
+			return 0, false
+		}
+		end = next
+	}
+
+	return 0, false
+}
@@ -0,0 +1,336 @@
+package match
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/agrski/greg/pkg/types"
+)
+
+func TestParseStructuralPattern(t *testing.T) {
+	type test struct {
+		name    string
+		pattern string
+		wantErr bool
+	}
+
+	tests := []test{
+		{name: "literal only", pattern: "http.Get"},
+		{name: "single hole", pattern: "http.Get(:[url])"},
+		{name: "several holes", pattern: "foo(:[a], :[b])"},
+		{name: "anonymous hole", pattern: "return :[_], err"},
+		{name: "surrounding whitespace is ignored", pattern: "  foo(:[x])  "},
+		{name: "empty pattern fails", pattern: "  ", wantErr: true},
+		{name: "leading hole fails", pattern: ":[x].Close()", wantErr: true},
+		{name: "trailing hole fails", pattern: "return :[x]", wantErr: true},
+		{name: "adjacent holes fail", pattern: "foo(:[a]:[b])", wantErr: true},
+		{name: "whitespace-separated holes fail", pattern: "foo(:[a] :[b])", wantErr: true},
+		{name: "unterminated hole fails", pattern: "foo(:[a)", wantErr: true},
+		{name: "invalid hole name fails", pattern: "foo(:[a-b])", wantErr: true},
+		{name: "empty hole name fails", pattern: "foo(:[])", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseStructuralPattern(tt.pattern)
+
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestStructuralMatch(t *testing.T) {
+	type test struct {
+		name              string
+		isBinary          bool
+		isCaseInsensitive bool
+		path              string
+		text              string
+		pattern           string
+		expected          *Match
+		expectedOk        bool
+	}
+
+	tests := []test{
+		{
+			name:       "should ignore binary files",
+			isBinary:   true,
+			text:       "http.Get(url)",
+			pattern:    "http.Get(:[url])",
+			expected:   nil,
+			expectedOk: false,
+		},
+		{
+			name:       "should reject invalid pattern",
+			text:       "http.Get(url)",
+			pattern:    ":[x](url)",
+			expected:   nil,
+			expectedOk: false,
+		},
+		{
+			name:    "should capture simple argument",
+			text:    `resp, err := http.Get(url)`,
+			pattern: "http.Get(:[url])",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{
+						Line:        0,
+						LineEnd:     0,
+						ColumnStart: 13,
+						ColumnEnd:   26,
+						Text:        `resp, err := http.Get(url)`,
+						Captures:    []Capture{{Name: "url", Text: "url"}},
+					},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:    "should capture nested brackets and strings",
+			text:    `http.Get(fmt.Sprintf("%s/(%d", base, ids[0]))`,
+			pattern: "http.Get(:[url])",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{
+						Line:        0,
+						LineEnd:     0,
+						ColumnStart: 0,
+						ColumnEnd:   45,
+						Text:        `http.Get(fmt.Sprintf("%s/(%d", base, ids[0]))`,
+						Captures:    []Capture{{Name: "url", Text: `fmt.Sprintf("%s/(%d", base, ids[0])`}},
+					},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:    "should match across lines with flexible whitespace",
+			text:    "if err != nil {\n\treturn nil, err\n}\n",
+			pattern: "if err != nil { return :[_], err }",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{
+						Line:        0,
+						LineEnd:     2,
+						ColumnStart: 0,
+						ColumnEnd:   1,
+						Text:        "if err != nil {\n\treturn nil, err\n}",
+					},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:    "should capture several holes",
+			text:    "copy(dst[1:], src)",
+			pattern: "copy(:[to], :[from])",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{
+						Line:        0,
+						LineEnd:     0,
+						ColumnStart: 0,
+						ColumnEnd:   18,
+						Text:        "copy(dst[1:], src)",
+						Captures: []Capture{
+							{Name: "to", Text: "dst[1:]"},
+							{Name: "from", Text: "src"},
+						},
+					},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:       "should not let holes escape enclosing brackets",
+			text:       "f(a) + g(b, c)",
+			pattern:    "f(:[x], :[y])",
+			expected:   nil,
+			expectedOk: false,
+		},
+		{
+			name:       "should require whitespace between words",
+			text:       "returnx",
+			pattern:    "return x",
+			expected:   nil,
+			expectedOk: false,
+		},
+		{
+			name:    "should find several matches",
+			text:    "a := f(1)\nb := f(g(2))\n",
+			pattern: "f(:[arg])",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{
+						Line:        0,
+						LineEnd:     0,
+						ColumnStart: 5,
+						ColumnEnd:   9,
+						Text:        "a := f(1)",
+						Captures:    []Capture{{Name: "arg", Text: "1"}},
+					},
+					{
+						Line:        1,
+						LineEnd:     1,
+						ColumnStart: 5,
+						ColumnEnd:   12,
+						Text:        "b := f(g(2))",
+						Captures:    []Capture{{Name: "arg", Text: "g(2)"}},
+					},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:              "should match literals case-insensitively",
+			isCaseInsensitive: true,
+			text:              "HTTP.GET(url)",
+			pattern:           "http.Get(:[url])",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{
+						Line:        0,
+						LineEnd:     0,
+						ColumnStart: 0,
+						ColumnEnd:   13,
+						Text:        "HTTP.GET(url)",
+						Captures:    []Capture{{Name: "url", Text: "url"}},
+					},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:              "should keep offsets after characters whose lowercase is longer when case-insensitive",
+			isCaseInsensitive: true,
+			text:              "ȺȺȺȺȺȺȺȺȺȺ foo(x)",
+			pattern:           "foo(:[a])",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{
+						Line:        0,
+						LineEnd:     0,
+						ColumnStart: 21,
+						ColumnEnd:   27,
+						Text:        "ȺȺȺȺȺȺȺȺȺȺ foo(x)",
+						Captures:    []Capture{{Name: "a", Text: "x"}},
+					},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:              "should match literals whose case differs in length when case-insensitive",
+			isCaseInsensitive: true,
+			text:              "ȺBC(x)",
+			pattern:           "ⱥbc(:[a])",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{
+						Line:        0,
+						LineEnd:     0,
+						ColumnStart: 0,
+						ColumnEnd:   7,
+						Text:        "ȺBC(x)",
+						Captures:    []Capture{{Name: "a", Text: "x"}},
+					},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:    "should not treat apostrophes in prose as quotes",
+			path:    "README.md",
+			text:    "Don't call f(a) twice, or f(b)",
+			pattern: "f(:[arg])",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{
+						Line:        0,
+						LineEnd:     0,
+						ColumnStart: 11,
+						ColumnEnd:   15,
+						Text:        "Don't call f(a) twice, or f(b)",
+						Captures:    []Capture{{Name: "arg", Text: "a"}},
+					},
+					{
+						Line:        0,
+						LineEnd:     0,
+						ColumnStart: 26,
+						ColumnEnd:   30,
+						Text:        "Don't call f(a) twice, or f(b)",
+						Captures:    []Capture{{Name: "arg", Text: "b"}},
+					},
+				},
+			},
+			expectedOk: true,
+		},
+		{
+			name:    "should skip brackets in comments and rune literals",
+			path:    "main.go",
+			text:    "f(x /* don't ) */, ')')",
+			pattern: "f(:[a], :[b])",
+			expected: &Match{
+				Positions: []*FilePosition{
+					{
+						Line:        0,
+						LineEnd:     0,
+						ColumnStart: 0,
+						ColumnEnd:   23,
+						Text:        "f(x /* don't ) */, ')')",
+						Captures: []Capture{
+							{Name: "a", Text: "x /* don't ) */"},
+							{Name: "b", Text: "')'"},
+						},
+					},
+				},
+			},
+			expectedOk: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileInfo := &types.FileInfo{}
+			fileInfo.Path = tt.path
+			fileInfo.IsBinary = tt.isBinary
+			fileInfo.Text = tt.text
+
+			matcher := newStructuralMatcher(zerolog.Nop(), tt.isCaseInsensitive)
+
+			actual, ok := matcher.Match(tt.pattern, fileInfo)
+
+			require.Equal(t, tt.expectedOk, ok)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestStructuralMatchManyHolesIsNotExponential(t *testing.T) {
+	// Each hole could end at any comma, so naive backtracking tries every combination
+	text := "f(" + strings.Repeat("a, ", 1_000) + "a"
+	fileInfo := &types.FileInfo{Path: "main.go", Text: text}
+
+	matcher := newStructuralMatcher(zerolog.Nop(), false)
+
+	done := make(chan bool)
+	go func() {
+		_, ok := matcher.Match("f(:[a], :[b], :[c], :[d])", fileInfo)
+		done <- ok
+	}()
+
+	select {
+	case ok := <-done:
+		require.False(t, ok)
+	case <-time.After(10 * time.Second):
+		t.Fatal("structural match did not finish")
+	}
+}
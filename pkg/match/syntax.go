@@ -0,0 +1,47 @@
+package match
+
+import (
+	"github.com/agrski/greg/pkg/types"
+)
+
+type SyntaxKind int
+
+const (
+	SyntaxCode SyntaxKind = iota
+	SyntaxComment
+	SyntaxString
+)
+
+// SyntaxSpan is a half-open range of bytes [Start, End) holding one kind of source text.
+type SyntaxSpan struct {
+	Start int
+	End   int
+	Kind  SyntaxKind
+}
+
+// Syntax finds the comments and string literals in text from a file, such as for highlighting them.
+// Only spans which are not code are returned, in order, and none are found in languages which are not understood.
+// Text from part way through a file may start inside a comment or string, so be classified wrongly.
+func Syntax(next *types.FileInfo, text string) []SyntaxSpan {
+	l, ok := languageFor(next)
+	if !ok {
+		return nil
+	}
+
+	regions := l.regions(text)
+	spans := make([]SyntaxSpan, 0, len(regions))
+	for _, r := range regions {
+		kind := SyntaxCode
+		switch r.kind {
+		case regionComment:
+			kind = SyntaxComment
+		case regionString:
+			kind = SyntaxString
+		case regionCode:
+		}
+
+		spans = append(spans, SyntaxSpan{Start: r.start, End: r.end, Kind: kind})
+	}
+
+	return spans
+}
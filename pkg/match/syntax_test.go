@@ -0,0 +1,49 @@
+package match
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/agrski/greg/pkg/types"
+)
+
+func TestSyntax(t *testing.T) {
+	type test struct {
+		name     string
+		fileInfo *types.FileInfo
+		text     string
+		expected []SyntaxSpan
+	}
+
+	tests := []test{
+		{
+			name:     "comments and strings in Go",
+			fileInfo: &types.FileInfo{Path: "main.go", Extension: "go"},
+			text:     `fmt.Println("hi") // greet`,
+			expected: []SyntaxSpan{
+				{Start: 12, End: 16, Kind: SyntaxString},
+				{Start: 18, End: 26, Kind: SyntaxComment},
+			},
+		},
+		{
+			name:     "only code",
+			fileInfo: &types.FileInfo{Path: "main.go", Extension: "go"},
+			text:     "x := 1",
+			expected: []SyntaxSpan{},
+		},
+		{
+			name:     "unknown language",
+			fileInfo: &types.FileInfo{Path: "notes.unknown", Extension: "unknown"},
+			text:     `"quoted" # hash`,
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := Syntax(tt.fileInfo, tt.text)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -59,3 +59,8 @@ const (
 	bgIntenseCyan    ansiCode = codePrefix + "106" + codeSuffix
 	bgIntenseWhite   ansiCode = codePrefix + "107" + codeSuffix
 )
+
+// sgr sets the style given by the parameters of a Select Graphic Rendition sequence, such as "01;31".
+func sgr(parameters string) ansiCode {
+	return codePrefix + ansiCode(parameters) + codeSuffix
+}
@@ -6,69 +6,368 @@ import (
 	"strings"
 
 	"github.com/agrski/greg/pkg/match"
+	"github.com/agrski/greg/pkg/present"
 	"github.com/agrski/greg/pkg/types"
 )
 
+const (
+	matchSeparator   = ':'
+	contextSeparator = '-'
+	hunkSeparator    = "--"
+	captureIndent    = "    "
+	captureSeparator = ": "
+	ruleLabel        = "rule"
+	linkLabel        = "link"
+	hexdumpGroup     = 8
+	hexDigits        = "0123456789abcdef"
+)
+
+type Config struct {
+	EnableColour bool
+	// Theme styles the output when colour is enabled, or the default theme if nil.
+	Theme *Theme
+	// RedactSecrets hides most of the text of matches found by secret rules.
+	RedactSecrets bool
+	// Source is where matches were found, for linking to them.
+	Source present.Source
+	// Permalinks shows a link to the commit searched under each match.
+	Permalinks bool
+	// Hyperlinks makes paths and line numbers clickable in terminals supporting OSC 8 hyperlinks.
+	Hyperlinks bool
+}
+
 type Console struct {
-	enableColour bool
-	out          io.StringWriter
+	enableColour  bool
+	theme         Theme
+	redactSecrets bool
+	source        present.Source
+	permalinks    bool
+	hyperlinks    bool
+	out           io.StringWriter
+	// path is that of the file being written, for linking to its lines.
+	path string
 }
 
-func New(out io.StringWriter, enableColour bool) *Console {
+var _ present.Presenter = (*Console)(nil)
+
+func New(out io.StringWriter, config Config) *Console {
+	theme := DefaultTheme()
+	if config.Theme != nil {
+		theme = *config.Theme
+	}
+
 	return &Console{
-		enableColour: enableColour,
-		out:          out,
+		enableColour:  config.EnableColour,
+		theme:         theme,
+		redactSecrets: config.RedactSecrets,
+		source:        config.Source,
+		permalinks:    config.Permalinks,
+		hyperlinks:    config.Hyperlinks,
+		out:           out,
 	}
 }
 
-func (c *Console) Write(fileInfo *types.FileInfo, match *match.Match) {
+func (c *Console) Close() error {
+	return nil
+}
+
+func (c *Console) Write(fileInfo *types.FileInfo, m *match.Match) {
+	c.path = fileInfo.Path
 	sb := strings.Builder{}
 
-	if c.enableColour {
-		sb.WriteString(string(fgBlue))
-		sb.WriteString(fileInfo.Path)
-		sb.WriteString(string(reset))
-	} else {
-		sb.WriteString(fileInfo.Path)
+	path := fileInfo.Path
+	if c.hyperlinks {
+		pathLink := strings.Builder{}
+		writeHyperlink(&pathLink, c.source.Permalink(fileInfo.Path, 0, 0, true), path)
+		path = pathLink.String()
 	}
+
+	c.writeStyled(&sb, path, c.theme.Path)
 	sb.WriteString("\n")
 	_, err := c.out.WriteString(sb.String())
 	if err != nil {
 		return
 	}
 
-	for _, p := range match.Positions {
-		sb := strings.Builder{}
-
-		line := strconv.Itoa(int(p.Line + 1))
-
-		if c.enableColour {
-			// Line number
-			sb.WriteString(string(fgMagenta))
-			sb.WriteString(line)
-			sb.WriteString(string(reset))
-			sb.WriteByte(':')
-			// Text
-			sb.WriteString(p.Text[:p.ColumnStart])
-			sb.WriteString(string(fgRed))
-			sb.WriteString(p.Text[p.ColumnStart:p.ColumnEnd])
-			sb.WriteString(string(reset))
-			sb.WriteString(p.Text[p.ColumnEnd:])
-		} else {
-			// Line number
-			sb.WriteString(line)
-			sb.WriteByte(':')
-			// Text
-			sb.WriteString(p.Text)
+	groups := present.GroupByLine(m, c.redactSecrets)
+
+	if len(m.Hunks) > 0 {
+		c.writeHunks(m, groups)
+	} else {
+		for _, g := range groups {
+			if err := c.writeGroup(g); err != nil {
+				return
+			}
 		}
+	}
+
+	_, _ = c.out.WriteString("\n")
+}
+
+func (c *Console) writeHunks(m *match.Match, groups []*present.LineGroup) {
+	groupsByLine := map[uint][]*present.LineGroup{}
+	for _, g := range groups {
+		groupsByLine[g.Line] = append(groupsByLine[g.Line], g)
+	}
+
+	for idx, h := range m.Hunks {
+		if idx > 0 {
+			if err := c.writeHunkSeparator(); err != nil {
+				return
+			}
+		}
+
+		// Lines spanned by multi-line matches have already been written
+		var writtenUntil uint
+		hasWritten := false
+
+		for _, l := range h.Lines {
+			lineGroups, ok := groupsByLine[l.Number]
+			if !ok {
+				if hasWritten && l.Number <= writtenUntil {
+					continue
+				}
+				if err := c.writeContextLine(l); err != nil {
+					return
+				}
+				continue
+			}
+
+			for _, g := range lineGroups {
+				if err := c.writeGroup(g); err != nil {
+					return
+				}
+				if !hasWritten || g.LineEnd > writtenUntil {
+					writtenUntil = g.LineEnd
+					hasWritten = true
+				}
+			}
+		}
+	}
+}
+
+// writeGroup shows the text of a line group once, with every match in it highlighted.
+func (c *Console) writeGroup(g *present.LineGroup) error {
+	if g.Positions[0].Binary {
+		return c.writeHexdump(g.Positions[0])
+	}
+
+	sb := strings.Builder{}
 
+	var offset uint
+	for idx, line := range strings.Split(g.Text, "\n") {
+		c.writeLineNumber(&sb, g.Line+uint(idx), matchSeparator)
+		c.writeHighlightedLine(&sb, line, offset, g.Spans)
 		sb.WriteString("\n")
 
-		_, err := c.out.WriteString(sb.String())
-		if err != nil {
-			return
+		offset += uint(len(line)) + 1
+	}
+
+	ruleIDs := map[string]bool{}
+	for _, p := range g.Positions {
+		for _, capture := range p.Captures {
+			c.writeCapture(&sb, capture)
+		}
+
+		if p.RuleID != "" && !ruleIDs[p.RuleID] {
+			ruleIDs[p.RuleID] = true
+			c.writeRuleID(&sb, p.RuleID)
 		}
 	}
 
-	_, _ = c.out.WriteString("\n")
+	if c.permalinks {
+		c.writePermalink(&sb, c.source.Permalink(c.path, g.Line, g.LineEnd, false))
+	}
+
+	_, err := c.out.WriteString(sb.String())
+
+	return err
+}
+
+// writeHighlightedLine writes a line starting at an offset within the text of its group,
+// highlighting the parts of it covered by any of the spans, which may overlap.
+func (c *Console) writeHighlightedLine(sb *strings.Builder, line string, offset uint, spans []present.Span) {
+	lineEnd := offset + uint(len(line))
+	var written uint
+
+	for _, s := range spans {
+		if s.End <= offset+written || s.Start >= lineEnd {
+			continue
+		}
+
+		start := written
+		if s.Start > offset+start {
+			start = s.Start - offset
+		}
+		end := uint(len(line))
+		if s.End < lineEnd {
+			end = s.End - offset
+		}
+
+		c.writeStyled(sb, line[written:start], c.theme.MatchedLine)
+		c.writeStyled(sb, line[start:end], c.theme.Match)
+		written = end
+	}
+
+	c.writeStyled(sb, line[written:], c.theme.MatchedLine)
+}
+
+// writeHexdump shows the bytes around a binary match in the style of hexdump -C,
+// with the byte offset of each row in place of a line number.
+func (c *Console) writeHexdump(p *match.FilePosition) error {
+	sb := strings.Builder{}
+	text := []byte(p.Text)
+
+	for rowStart := 0; rowStart < len(text); rowStart += match.HexdumpWidth {
+		rowEnd := rowStart + match.HexdumpWidth
+		if rowEnd > len(text) {
+			rowEnd = len(text)
+		}
+		row := text[rowStart:rowEnd]
+		offset := p.TextColumn + uint(rowStart)
+
+		c.writeByteOffset(&sb, offset)
+
+		for idx := 0; idx < match.HexdumpWidth; idx++ {
+			if idx%hexdumpGroup == 0 {
+				sb.WriteByte(' ')
+			}
+			if idx >= len(row) {
+				sb.WriteString("   ")
+				continue
+			}
+
+			b := row[idx]
+			sb.WriteByte(' ')
+			c.writeHighlighted(&sb, string([]byte{hexDigits[b>>4], hexDigits[b&0x0f]}), isInMatch(p, offset+uint(idx)))
+		}
+
+		sb.WriteString("  |")
+		for idx, b := range row {
+			printable := "."
+			if b >= ' ' && b <= '~' {
+				printable = string(b)
+			}
+			c.writeHighlighted(&sb, printable, isInMatch(p, offset+uint(idx)))
+		}
+		sb.WriteString("|\n")
+	}
+
+	if c.permalinks {
+		c.writePermalink(&sb, c.source.Permalink(c.path, 0, 0, true))
+	}
+
+	_, err := c.out.WriteString(sb.String())
+
+	return err
+}
+
+func isInMatch(p *match.FilePosition, offset uint) bool {
+	return p.ColumnStart <= offset && offset < p.ColumnEnd
+}
+
+func (c *Console) writeHighlighted(sb *strings.Builder, s string, highlight bool) {
+	if highlight {
+		c.writeStyled(sb, s, c.theme.Match)
+	} else {
+		sb.WriteString(s)
+	}
+}
+
+// writeStyled writes text in a style from the theme, if colour is enabled.
+func (c *Console) writeStyled(sb *strings.Builder, text string, style string) {
+	if !c.enableColour || style == "" || text == "" {
+		sb.WriteString(text)
+		return
+	}
+
+	sb.WriteString(string(sgr(style)))
+	sb.WriteString(text)
+	sb.WriteString(string(reset))
+}
+
+func (c *Console) writeByteOffset(sb *strings.Builder, offset uint) {
+	hex := strconv.FormatUint(uint64(offset), 16)
+	if len(hex) < 8 {
+		hex = strings.Repeat("0", 8-len(hex)) + hex
+	}
+
+	c.writeStyled(sb, hex, c.theme.ByteOffset)
+}
+
+// writeCapture shows the text of a structural hole on a single, indented line.
+func (c *Console) writeCapture(sb *strings.Builder, capture match.Capture) {
+	sb.WriteString(captureIndent)
+
+	c.writeStyled(sb, capture.Name, c.theme.Label)
+	c.writeStyled(sb, captureSeparator, c.theme.Separator)
+
+	sb.WriteString(strings.Join(strings.Fields(capture.Text), " "))
+	sb.WriteString("\n")
+}
+
+// writeRuleID shows which secret rule found a match on a single, indented line.
+func (c *Console) writeRuleID(sb *strings.Builder, ruleID string) {
+	sb.WriteString(captureIndent)
+
+	c.writeStyled(sb, ruleLabel, c.theme.Label)
+	c.writeStyled(sb, captureSeparator, c.theme.Separator)
+
+	sb.WriteString(ruleID)
+	sb.WriteString("\n")
+}
+
+// writePermalink shows a link to a match on a single, indented line.
+func (c *Console) writePermalink(sb *strings.Builder, url string) {
+	sb.WriteString(captureIndent)
+
+	c.writeStyled(sb, linkLabel, c.theme.Label)
+	c.writeStyled(sb, captureSeparator, c.theme.Separator)
+
+	if c.hyperlinks {
+		writeHyperlink(sb, url, url)
+	} else {
+		sb.WriteString(url)
+	}
+	sb.WriteString("\n")
+}
+
+func (c *Console) writeContextLine(l *match.Line) error {
+	sb := strings.Builder{}
+
+	text := l.Text
+	if c.redactSecrets {
+		text = present.RedactLine(l)
+	}
+
+	c.writeLineNumber(&sb, l.Number, contextSeparator)
+	c.writeStyled(&sb, text, c.theme.ContextLine)
+	sb.WriteString("\n")
+
+	_, err := c.out.WriteString(sb.String())
+
+	return err
+}
+
+func (c *Console) writeHunkSeparator() error {
+	sb := strings.Builder{}
+
+	c.writeStyled(&sb, hunkSeparator, c.theme.Separator)
+	sb.WriteString("\n")
+
+	_, err := c.out.WriteString(sb.String())
+
+	return err
+}
+
+func (c *Console) writeLineNumber(sb *strings.Builder, lineNumber uint, separator byte) {
+	line := strconv.Itoa(int(lineNumber + 1))
+	if c.hyperlinks {
+		lineLink := strings.Builder{}
+		writeHyperlink(&lineLink, c.source.Permalink(c.path, lineNumber, lineNumber, false), line)
+		line = lineLink.String()
+	}
+
+	c.writeStyled(sb, line, c.theme.LineNumber)
+	c.writeStyled(sb, string(separator), c.theme.Separator)
 }
@@ -0,0 +1,22 @@
+package console
+
+import (
+	"strings"
+)
+
+// OSC 8 hyperlinks, which supporting terminals show as clickable text and others ignore
+
+const (
+	osc             = escape + "]"
+	stringTerminal  = escape + "\\"
+	hyperlinkPrefix = osc + "8;;"
+)
+
+func writeHyperlink(sb *strings.Builder, url string, text string) {
+	sb.WriteString(string(hyperlinkPrefix))
+	sb.WriteString(url)
+	sb.WriteString(string(stringTerminal))
+	sb.WriteString(text)
+	sb.WriteString(string(hyperlinkPrefix))
+	sb.WriteString(string(stringTerminal))
+}
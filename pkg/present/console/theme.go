@@ -0,0 +1,197 @@
+package console
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Theme gives the style of each part of the output as the parameters of an SGR escape sequence, such as "01;31".
+// An empty style leaves that part unstyled.
+type Theme struct {
+	Path        string
+	LineNumber  string
+	ByteOffset  string
+	Match       string
+	MatchedLine string
+	ContextLine string
+	Separator   string
+	// Label styles the names of captures, secret rules, and links shown under matches.
+	Label string
+}
+
+func DefaultTheme() Theme {
+	return Theme{
+		Path:       "34",
+		LineNumber: "35",
+		ByteOffset: "35",
+		Match:      "31",
+		Separator:  "36",
+		Label:      "32",
+	}
+}
+
+var sgrParameters = regexp.MustCompile(`^[0-9;]*$`)
+
+var hexColour = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+var styleNames = map[string]string{
+	"bold":          "1",
+	"faint":         "2",
+	"dim":           "2",
+	"italic":        "3",
+	"underline":     "4",
+	"invert":        "7",
+	"reverse":       "7",
+	"strikethrough": "9",
+}
+
+// colourNames are offsets from the first code of each range of colours, such as 30 for the standard foregrounds.
+var colourNames = map[string]int{
+	"black":   0,
+	"red":     1,
+	"green":   2,
+	"yellow":  3,
+	"blue":    4,
+	"magenta": 5,
+	"cyan":    6,
+	"white":   7,
+	"default": 9,
+}
+
+const (
+	foreground         = 30
+	background         = 40
+	brightForeground   = 90
+	brightBackground   = 100
+	extendedColour     = 8
+	extendedPalette    = "5"
+	extendedTrueColour = "2"
+	backgroundPrefix   = "on-"
+	brightPrefix       = "bright-"
+)
+
+// ParseTheme changes a theme using the syntax of grep's GREP_COLORS, such as "ms=01;31:fn=35".
+// Capabilities are separated by colons or new lines, and lines starting with # are comments.
+//
+// Besides SGR parameters, a style may be a list of words, such as "bold blue on-black", "colour208", or "#ff8800".
+// Like grep, unknown capabilities are ignored so that settings shared with newer versions of grep still work.
+func ParseTheme(theme Theme, spec string) (Theme, error) {
+	capabilities := map[string]*string{
+		"fn": &theme.Path,
+		"ln": &theme.LineNumber,
+		"bn": &theme.ByteOffset,
+		"ms": &theme.Match,
+		"sl": &theme.MatchedLine,
+		"cx": &theme.ContextLine,
+		"se": &theme.Separator,
+		"lb": &theme.Label,
+	}
+
+	for _, line := range strings.Split(spec, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		for _, entry := range strings.Split(line, ":") {
+			name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			if !ok {
+				// Boolean capabilities, such as rv and ne, have no bearing on how greg draws its output
+				continue
+			}
+
+			name = strings.TrimSpace(name)
+			style, err := parseStyle(value)
+			if err != nil {
+				return Theme{}, fmt.Errorf("invalid style for %s: %w", name, err)
+			}
+
+			// Greg only highlights matches in matched lines, so all matches take the same style
+			if name == "mt" {
+				theme.Match = style
+				continue
+			}
+			if field, ok := capabilities[name]; ok {
+				*field = style
+			}
+		}
+	}
+
+	return theme, nil
+}
+
+func parseStyle(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if sgrParameters.MatchString(value) {
+		return value, nil
+	}
+
+	parameters := []string{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(value), isStyleSeparator) {
+		if p, ok := styleNames[word]; ok {
+			parameters = append(parameters, p)
+			continue
+		}
+
+		p, err := parseColour(word)
+		if err != nil {
+			return "", err
+		}
+		parameters = append(parameters, p)
+	}
+
+	return strings.Join(parameters, ";"), nil
+}
+
+func isStyleSeparator(r rune) bool {
+	return r == ' ' || r == ',' || r == '+'
+}
+
+// parseColour accepts a named colour, one of the 256 colours of the extended palette, or a 24-bit colour.
+// Colours are for the foreground unless they start with "on-".
+func parseColour(word string) (string, error) {
+	base, brightBase := foreground, brightForeground
+	name := word
+	if strings.HasPrefix(name, backgroundPrefix) {
+		base, brightBase = background, brightBackground
+		name = strings.TrimPrefix(name, backgroundPrefix)
+	}
+
+	if offset, ok := colourNames[name]; ok {
+		return strconv.Itoa(base + offset), nil
+	}
+
+	if strings.HasPrefix(name, brightPrefix) {
+		if offset, ok := colourNames[strings.TrimPrefix(name, brightPrefix)]; ok && name != brightPrefix+"default" {
+			return strconv.Itoa(brightBase + offset), nil
+		}
+	}
+
+	extended := strconv.Itoa(base + extendedColour)
+
+	for _, prefix := range []string{"colour", "color"} {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		n, err := strconv.ParseUint(strings.TrimPrefix(name, prefix), 10, 8)
+		if err != nil {
+			return "", fmt.Errorf("palette colour must be from 0 to 255 but found %s", word)
+		}
+
+		return extended + ";" + extendedPalette + ";" + strconv.FormatUint(n, 10), nil
+	}
+
+	if hexColour.MatchString(name) {
+		rgb := make([]string, 0, 3)
+		for idx := 1; idx < len(name); idx += 2 {
+			n, _ := strconv.ParseUint(name[idx:idx+2], 16, 8)
+			rgb = append(rgb, strconv.FormatUint(n, 10))
+		}
+
+		return extended + ";" + extendedTrueColour + ";" + strings.Join(rgb, ";"), nil
+	}
+
+	return "", fmt.Errorf("unknown style %s", word)
+}
@@ -0,0 +1,94 @@
+package console
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTheme(t *testing.T) {
+	type test struct {
+		name    string
+		spec    string
+		want    func(*Theme)
+		wantErr bool
+	}
+
+	tests := []test{
+		{name: "empty keeps theme", spec: "", want: func(*Theme) {}},
+		{
+			name: "grep syntax",
+			spec: "ms=01;31:mc=01;31:sl=:cx=2:fn=35:ln=32:bn=32:se=36:rv:ne",
+			want: func(theme *Theme) {
+				theme.Match = "01;31"
+				theme.ContextLine = "2"
+				theme.Path = "35"
+				theme.LineNumber = "32"
+				theme.ByteOffset = "32"
+			},
+		},
+		{
+			name: "mt sets matches",
+			spec: "mt=4",
+			want: func(theme *Theme) { theme.Match = "4" },
+		},
+		{
+			name: "empty style removes styling",
+			spec: "fn=:se=",
+			want: func(theme *Theme) {
+				theme.Path = ""
+				theme.Separator = ""
+			},
+		},
+		{
+			name: "named styles and colours",
+			spec: "fn=bold blue:ms=underline,bright-red+on-black:lb=italic default",
+			want: func(theme *Theme) {
+				theme.Path = "1;34"
+				theme.Match = "4;91;40"
+				theme.Label = "3;39"
+			},
+		},
+		{
+			name: "palette and true colours",
+			spec: "ms=colour208 on-color236:fn=#FF8800:ln=on-#000010",
+			want: func(theme *Theme) {
+				theme.Match = "38;5;208;48;5;236"
+				theme.Path = "38;2;255;136;0"
+				theme.LineNumber = "48;2;0;0;16"
+			},
+		},
+		{
+			name: "lines and comments",
+			spec: "# greg colours\nfn=35\n\n  # paths above\nln=1:bn=2\n",
+			want: func(theme *Theme) {
+				theme.Path = "35"
+				theme.LineNumber = "1"
+				theme.ByteOffset = "2"
+			},
+		},
+		{
+			name: "unknown capabilities are ignored",
+			spec: "zz=1:fn=35",
+			want: func(theme *Theme) { theme.Path = "35" },
+		},
+		{name: "unknown style fails", spec: "fn=sparkly", wantErr: true},
+		{name: "palette colour out of range fails", spec: "fn=colour256", wantErr: true},
+		{name: "malformed true colour fails", spec: "fn=#ff88", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ParseTheme(DefaultTheme(), tt.spec)
+
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				expected := DefaultTheme()
+				tt.want(&expected)
+				require.Equal(t, expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,434 @@
+package html
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agrski/greg/pkg/match"
+	"github.com/agrski/greg/pkg/present"
+	"github.com/agrski/greg/pkg/stats"
+	"github.com/agrski/greg/pkg/types"
+)
+
+const (
+	classComment = "comment"
+	classString  = "string"
+	// binaryPlaceholder stands in for bytes which cannot be shown as text.
+	binaryPlaceholder = '.'
+)
+
+type Config struct {
+	Source  present.Source
+	Pattern string
+	// RedactSecrets hides most of the text of matches found by secret rules.
+	RedactSecrets bool
+}
+
+// HTML builds a single self-contained page of every match, which is written on Close.
+// Files are grouped by repository, with a summary table linking to each one.
+type HTML struct {
+	out           io.Writer
+	source        present.Source
+	pattern       string
+	redactSecrets bool
+	files         []*fileReport
+	summary       *summaryReport
+}
+
+var _ present.SummaryPresenter = (*HTML)(nil)
+
+type report struct {
+	Pattern      string
+	Repositories []*repositoryReport
+	Files        int
+	Matches      int
+	Summary      *summaryReport
+}
+
+// summaryReport describes the search as a whole, when statistics are requested.
+type summaryReport struct {
+	Statistics []statistic
+	Failures   []failure
+}
+
+type statistic struct {
+	Name  string
+	Value string
+}
+
+type failure struct {
+	Path  string
+	Error string
+}
+
+type repositoryReport struct {
+	Name    string
+	Ref     string
+	Commit  string
+	Files   []*fileReport
+	Matches int
+}
+
+type fileReport struct {
+	ID        string
+	Path      string
+	Permalink string
+	Matches   int
+	Blocks    []*block
+}
+
+// block is a run of consecutive lines, such as a hunk of context around matches.
+type block struct {
+	Lines []*line
+}
+
+type line struct {
+	Number    string
+	Permalink string
+	IsMatch   bool
+	Segments  []segment
+}
+
+// segment is a piece of a line with the same highlighting throughout.
+type segment struct {
+	Text  string
+	Class string
+	Mark  bool
+}
+
+// span is a half-open range of bytes [start, end) in a line.
+type span struct {
+	start int
+	end   int
+}
+
+func New(out io.Writer, config Config) *HTML {
+	return &HTML{
+		out:           out,
+		source:        config.Source,
+		pattern:       config.Pattern,
+		redactSecrets: config.RedactSecrets,
+	}
+}
+
+func (h *HTML) Write(fileInfo *types.FileInfo, m *match.Match) {
+	f := &fileReport{
+		ID:        "file-" + strconv.Itoa(len(h.files)+1),
+		Path:      fileInfo.Path,
+		Permalink: h.source.Permalink(fileInfo.Path, 0, 0, true),
+		Matches:   len(m.Positions),
+	}
+
+	if len(m.Hunks) > 0 {
+		for _, hunk := range m.Hunks {
+			f.Blocks = append(f.Blocks, h.makeHunkBlock(fileInfo, hunk, m.Positions))
+		}
+	} else {
+		for _, g := range present.GroupByLine(m, h.redactSecrets) {
+			f.Blocks = append(f.Blocks, h.makeGroupBlock(fileInfo, g))
+		}
+	}
+
+	h.files = append(h.files, f)
+}
+
+func (h *HTML) WriteSummary(summary stats.Summary) {
+	formatCount := func(n uint64) string {
+		return strconv.FormatUint(n, 10)
+	}
+
+	h.summary = &summaryReport{
+		Statistics: []statistic{
+			{Name: "Files fetched", Value: formatCount(summary.FilesFetched)},
+			{Name: "Files matched", Value: formatCount(summary.FilesMatched)},
+			{Name: "Matches", Value: formatCount(summary.Matches)},
+			{Name: "Binary files skipped", Value: formatCount(summary.SkippedBinary)},
+			{Name: "Filtered by file type", Value: formatCount(summary.FilteredByType)},
+			{Name: "Filtered by path", Value: formatCount(summary.FilteredByPath)},
+			{Name: "Failed", Value: formatCount(uint64(len(summary.Failures)))},
+			{Name: "Downloaded", Value: present.FormatBytes(summary.BytesDownloaded)},
+			{Name: "API requests", Value: formatCount(summary.APIRequests)},
+			{Name: "API cost", Value: formatCount(summary.APICost)},
+			{Name: "Elapsed", Value: summary.Elapsed.Round(time.Millisecond).String()},
+		},
+	}
+	for _, f := range summary.Failures {
+		h.summary.Failures = append(h.summary.Failures, failure{Path: present.FailedPath(f.Path), Error: f.Err.Error()})
+	}
+}
+
+// Close writes the page, which is complete even when there were no matches.
+func (h *HTML) Close() error {
+	repo := &repositoryReport{
+		Name:   h.source.Repository(),
+		Ref:    h.source.Ref,
+		Commit: h.source.Commit,
+		Files:  h.files,
+	}
+	for _, f := range h.files {
+		repo.Matches += f.Matches
+	}
+
+	r := &report{
+		Pattern:      h.pattern,
+		Repositories: []*repositoryReport{repo},
+		Files:        len(h.files),
+		Matches:      repo.Matches,
+		Summary:      h.summary,
+	}
+
+	return reportTemplate.Execute(h.out, r)
+}
+
+// makeHunkBlock shows the lines of a hunk, marking every match which covers part of each line.
+func (h *HTML) makeHunkBlock(fileInfo *types.FileInfo, hunk *match.Hunk, positions []*match.FilePosition) *block {
+	texts := make([]string, len(hunk.Lines))
+	for idx, l := range hunk.Lines {
+		texts[idx] = l.Text
+	}
+	syntax := match.Syntax(fileInfo, strings.Join(texts, "\n"))
+
+	b := &block{}
+	offset := 0
+	for _, l := range hunk.Lines {
+		text := l.Text
+		marks := []span{}
+		onLine := []*match.FilePosition{}
+
+		for _, p := range positions {
+			if p.Line > l.Number || p.LineEnd < l.Number {
+				continue
+			}
+			onLine = append(onLine, p)
+
+			start, end := 0, len(text)
+			if p.Line == l.Number {
+				start = int(p.ColumnStart)
+			}
+			if p.LineEnd == l.Number {
+				end = int(p.ColumnEnd)
+			}
+			marks = append(marks, span{start: start, end: end})
+		}
+
+		lineSyntax := clipSyntax(syntax, offset, offset+len(text))
+		offset += len(text) + 1
+
+		if redacted, redactedMarks, ok := h.redact(l, onLine); ok {
+			// Redaction moves text about, so its highlighting no longer lines up
+			text, marks, lineSyntax = redacted, redactedMarks, nil
+		}
+
+		b.Lines = append(
+			b.Lines,
+			&line{
+				Number:    strconv.FormatUint(uint64(l.Number+1), 10),
+				Permalink: h.source.Permalink(fileInfo.Path, l.Number, l.Number, false),
+				IsMatch:   len(marks) > 0,
+				Segments:  makeSegments(text, lineSyntax, marks),
+			},
+		)
+	}
+
+	return b
+}
+
+// makeGroupBlock shows the text of the matches starting on a line, which may be an excerpt of the line or span several lines.
+func (h *HTML) makeGroupBlock(fileInfo *types.FileInfo, g *present.LineGroup) *block {
+	if g.Positions[0].Binary {
+		return makeBinaryBlock(g.Positions[0])
+	}
+
+	syntax := match.Syntax(fileInfo, g.Text)
+	if h.redactSecrets && g.Text != g.Positions[0].Text {
+		// Redaction moves text about, so its highlighting no longer lines up
+		syntax = nil
+	}
+
+	b := &block{}
+	offset := 0
+	for idx, l := range strings.Split(g.Text, "\n") {
+		lineStart, lineEnd := offset, offset+len(l)
+		marks := []span{}
+		for _, s := range g.Spans {
+			if int(s.End) <= lineStart || int(s.Start) >= lineEnd {
+				continue
+			}
+			marks = append(marks, span{start: maxInt(int(s.Start), lineStart) - lineStart, end: minInt(int(s.End), lineEnd) - lineStart})
+		}
+
+		b.Lines = append(
+			b.Lines,
+			&line{
+				Number:    strconv.FormatUint(uint64(g.Line+uint(idx)+1), 10),
+				Permalink: h.source.Permalink(fileInfo.Path, g.Line+uint(idx), g.Line+uint(idx), false),
+				IsMatch:   true,
+				Segments:  makeSegments(l, clipSyntax(syntax, lineStart, lineEnd), marks),
+			},
+		)
+		offset = lineEnd + 1
+	}
+
+	return b
+}
+
+// makeBinaryBlock shows the bytes around a match in a binary file, labelled by the offset of the first of them.
+func makeBinaryBlock(p *match.FilePosition) *block {
+	// Bytes are replaced one for one, so offsets within the text are kept
+	printable := []byte(p.Text)
+	for idx, c := range printable {
+		if c < ' ' || c > '~' {
+			printable[idx] = binaryPlaceholder
+		}
+	}
+
+	start, end := p.Span()
+
+	return &block{
+		Lines: []*line{
+			{
+				Number:   fmt.Sprintf("0x%08x", p.TextColumn),
+				IsMatch:  true,
+				Segments: makeSegments(string(printable), nil, []span{{start: int(start), end: int(end)}}),
+			},
+		},
+	}
+}
+
+// redact hides the secrets on a line, returning the new line and the new spans of its matches.
+// Lines without matches may still have secrets, which were not reported because of limits on matches.
+func (h *HTML) redact(l *match.Line, onLine []*match.FilePosition) (string, []span, bool) {
+	if !h.redactSecrets {
+		return "", nil, false
+	}
+
+	startingHere := []*match.FilePosition{}
+	for _, p := range onLine {
+		if p.Line == l.Number && p.RuleID != "" {
+			startingHere = append(startingHere, p)
+		}
+	}
+	if len(startingHere) == 0 {
+		if len(l.Secrets) == 0 {
+			return "", nil, false
+		}
+
+		return present.RedactLine(l), nil, true
+	}
+
+	var text string
+	marks := make([]span, 0, len(startingHere))
+	for _, p := range startingHere {
+		var start, end uint
+		text, start, end = present.RedactSecrets(p, startingHere)
+		marks = append(marks, span{start: int(start), end: int(end)})
+	}
+
+	return text, marks, true
+}
+
+// clipSyntax gives the parts of syntax spans within [start, end), relative to start.
+func clipSyntax(syntax []match.SyntaxSpan, start int, end int) []match.SyntaxSpan {
+	clipped := []match.SyntaxSpan{}
+	for _, s := range syntax {
+		if s.End <= start || s.Start >= end {
+			continue
+		}
+
+		clipped = append(
+			clipped,
+			match.SyntaxSpan{
+				Start: maxInt(s.Start, start) - start,
+				End:   minInt(s.End, end) - start,
+				Kind:  s.Kind,
+			},
+		)
+	}
+
+	return clipped
+}
+
+// makeSegments divides a line wherever its syntax or marking changes.
+func makeSegments(text string, syntax []match.SyntaxSpan, marks []span) []segment {
+	boundaries := []int{0, len(text)}
+	for _, s := range syntax {
+		boundaries = append(boundaries, s.Start, s.End)
+	}
+	for _, m := range marks {
+		boundaries = append(boundaries, m.start, m.end)
+	}
+	sort.Ints(boundaries)
+
+	segments := []segment{}
+	for idx := 0; idx+1 < len(boundaries); idx++ {
+		start, end := clamp(boundaries[idx], len(text)), clamp(boundaries[idx+1], len(text))
+		if start >= end {
+			continue
+		}
+
+		segments = append(
+			segments,
+			segment{
+				Text:  text[start:end],
+				Class: syntaxClass(syntax, start),
+				Mark:  isMarked(marks, start),
+			},
+		)
+	}
+
+	return segments
+}
+
+func syntaxClass(syntax []match.SyntaxSpan, offset int) string {
+	for _, s := range syntax {
+		if s.Start <= offset && offset < s.End {
+			switch s.Kind {
+			case match.SyntaxComment:
+				return classComment
+			case match.SyntaxString:
+				return classString
+			case match.SyntaxCode:
+			}
+		}
+	}
+
+	return ""
+}
+
+func isMarked(marks []span, offset int) bool {
+	for _, m := range marks {
+		if m.start <= offset && offset < m.end {
+			return true
+		}
+	}
+
+	return false
+}
+
+func clamp(n int, limit int) int {
+	if n < 0 {
+		return 0
+	}
+
+	return minInt(n, limit)
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,86 @@
+package html
+
+import (
+	"html/template"
+)
+
+// reportTemplate lays out the page, with its styles inline so it can be shared as a single file.
+// Sections are collapsible without any scripting by using details elements.
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>greg: {{ .Pattern }}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #1f2328; }
+table.summary { border-collapse: collapse; margin-bottom: 2em; }
+table.summary th, table.summary td { border: 1px solid #d0d7de; padding: 0.3em 0.8em; text-align: left; }
+table.summary td.count { text-align: right; }
+details { margin: 0.5em 0; }
+summary { cursor: pointer; font-weight: 600; }
+details.file > summary { font-family: ui-monospace, Menlo, Consolas, monospace; }
+table.code { border-collapse: collapse; font-family: ui-monospace, Menlo, Consolas, monospace; font-size: 0.9em; margin: 0.5em 0; }
+table.code td { padding: 0 0.6em; white-space: pre; vertical-align: top; }
+table.code td.number { color: #6e7781; text-align: right; user-select: none; }
+table.code td.number a { color: inherit; text-decoration: none; }
+table.code tr.match td.number { color: #1f2328; font-weight: 600; }
+table.code tr.match { background: #fff8c5; }
+hr.gap { border: none; border-top: 1px dashed #d0d7de; margin: 0.3em 0; }
+mark { background: #ffd33d; color: inherit; }
+.comment { color: #6e7781; font-style: italic; }
+.string { color: #0a3069; }
+</style>
+</head>
+<body>
+<h1>greg results for <code>{{ .Pattern }}</code></h1>
+<p>{{ .Matches }} {{ if eq .Matches 1 }}match{{ else }}matches{{ end }} in {{ .Files }} {{ if eq .Files 1 }}file{{ else }}files{{ end }}</p>
+{{- with .Summary }}
+<details class="statistics">
+<summary>Statistics</summary>
+<table class="summary">
+<tbody>
+{{- range .Statistics }}
+<tr><th>{{ .Name }}</th><td class="count">{{ .Value }}</td></tr>
+{{- end }}
+</tbody>
+</table>
+{{- if .Failures }}
+<ul class="failures">
+{{- range .Failures }}
+<li><code>{{ .Path }}</code>: {{ .Error }}</li>
+{{- end }}
+</ul>
+{{- end }}
+</details>
+{{- end }}
+<table class="summary">
+<thead><tr><th>Repository</th><th>Path</th><th>Matches</th></tr></thead>
+<tbody>
+{{- range .Repositories }}{{ $repo := .Name }}{{ range .Files }}
+<tr><td>{{ $repo }}</td><td><a href="#{{ .ID }}">{{ .Path }}</a></td><td class="count">{{ .Matches }}</td></tr>
+{{- end }}{{ end }}
+</tbody>
+</table>
+{{- range .Repositories }}
+<details class="repository" open>
+<summary>{{ .Name }}{{ if .Ref }} at {{ .Ref }}{{ end }}{{ if .Commit }} ({{ .Commit }}){{ end }}: {{ .Matches }} {{ if eq .Matches 1 }}match{{ else }}matches{{ end }}</summary>
+{{- range .Files }}
+<details class="file" id="{{ .ID }}" open>
+<summary><a href="{{ .Permalink }}">{{ .Path }}</a>: {{ .Matches }} {{ if eq .Matches 1 }}match{{ else }}matches{{ end }}</summary>
+{{- range $idx, $block := .Blocks }}{{ if $idx }}
+<hr class="gap">{{ end }}
+<table class="code">
+{{- range .Lines }}
+<tr{{ if .IsMatch }} class="match"{{ end }}><td class="number">{{ if .Permalink }}<a href="{{ .Permalink }}">{{ .Number }}</a>{{ else }}{{ .Number }}{{ end }}</td><td>
+{{- range .Segments }}{{ if .Mark }}<mark>{{ end }}{{ if .Class }}<span class="{{ .Class }}">{{ .Text }}</span>{{ else }}{{ .Text }}{{ end }}{{ if .Mark }}</mark>{{ end }}{{ end -}}
+</td></tr>
+{{- end }}
+</table>
+{{- end }}
+</details>
+{{- end }}
+</details>
+{{- end }}
+</body>
+</html>
+`))
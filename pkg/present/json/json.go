@@ -0,0 +1,179 @@
+package json
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/agrski/greg/pkg/match"
+	"github.com/agrski/greg/pkg/present"
+	"github.com/agrski/greg/pkg/stats"
+	"github.com/agrski/greg/pkg/types"
+)
+
+const (
+	recordTypeMatch   = "match"
+	recordTypeSummary = "summary"
+)
+
+// Record is a line of JSON output describing a single match.
+// Fields are only ever added to it, so consumers should ignore any they do not recognise.
+type Record struct {
+	// Type is always "match", to tell matches apart from other kinds of record, such as SummaryRecord.
+	Type string `json:"type"`
+	// Repo names the repository including its host, e.g. github.com/agrski/greg.
+	Repo string `json:"repo"`
+	// Ref is the branch, tag, or other expression which was searched.
+	Ref string `json:"ref"`
+	// Commit is the full SHA of the commit which was searched.
+	Commit string `json:"commit"`
+	// Path is the path of the file within the repository.
+	Path string `json:"path"`
+	// Line and LineEnd number the lines the match starts and ends on, from 1.
+	Line    uint `json:"line"`
+	LineEnd uint `json:"lineEnd"`
+	// ColumnStart and ColumnEnd are the byte offsets of the match in its first and last lines,
+	// from 0 and with the end excluded.
+	ColumnStart uint `json:"columnStart"`
+	ColumnEnd   uint `json:"columnEnd"`
+	// Text holds the lines of the match, or only an excerpt of a very long line.
+	Text string `json:"text"`
+	// TextColumn is the byte offset in the first line at which Text starts, which is 0 unless it is an excerpt.
+	TextColumn uint `json:"textColumn"`
+	// Pattern is the search term.
+	Pattern string `json:"pattern"`
+	// RuleID names the secret rule which found the match, when looking for secrets.
+	RuleID string `json:"ruleId,omitempty"`
+	// Binary is set for matches in binary files, whose columns are byte offsets in the whole file.
+	Binary bool `json:"binary,omitempty"`
+	// Permalink links to the lines of the match as of the commit searched.
+	Permalink string `json:"permalink"`
+}
+
+// SummaryRecord is the last line of JSON output when statistics are requested, describing the search as a whole.
+type SummaryRecord struct {
+	// Type is always "summary".
+	Type         string `json:"type"`
+	Repo         string `json:"repo"`
+	Ref          string `json:"ref"`
+	Commit       string `json:"commit"`
+	Pattern      string `json:"pattern"`
+	FilesFetched uint64 `json:"filesFetched"`
+	FilesMatched uint64 `json:"filesMatched"`
+	Matches      uint64 `json:"matches"`
+	// SkippedBinary counts binary files which were not searched.
+	SkippedBinary  uint64 `json:"skippedBinary"`
+	FilteredByType uint64 `json:"filteredByType"`
+	// FilteredByPath counts files and directories excluded by path filters.
+	FilteredByPath uint64          `json:"filteredByPath"`
+	Failures       []FailureRecord `json:"failures"`
+	// BytesDownloaded counts the bytes of every response from the forge, including the text of files.
+	BytesDownloaded uint64 `json:"bytesDownloaded"`
+	APIRequests     uint64 `json:"apiRequests"`
+	// APICost is the number of rate-limit points spent on queries which report their cost.
+	APICost        uint64  `json:"apiCost"`
+	ElapsedSeconds float64 `json:"elapsedSeconds"`
+}
+
+// FailureRecord is a path which could not be fetched or read, with the root of the repository given as ".".
+type FailureRecord struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+type Config struct {
+	Source  present.Source
+	Pattern string
+	// RedactSecrets hides most of the text of matches found by secret rules.
+	RedactSecrets bool
+}
+
+// JSON writes matches as JSON Lines, with one Record per match.
+type JSON struct {
+	source        present.Source
+	pattern       string
+	redactSecrets bool
+	encoder       *json.Encoder
+}
+
+var _ present.SummaryPresenter = (*JSON)(nil)
+
+func New(out io.Writer, config Config) *JSON {
+	encoder := json.NewEncoder(out)
+	// Code is full of angle brackets and ampersands, which should stay readable
+	encoder.SetEscapeHTML(false)
+
+	return &JSON{
+		source:        config.Source,
+		pattern:       config.Pattern,
+		redactSecrets: config.RedactSecrets,
+		encoder:       encoder,
+	}
+}
+
+func (j *JSON) Write(fileInfo *types.FileInfo, m *match.Match) {
+	positionsByLine := present.PositionsByLine(m)
+
+	for _, p := range m.Positions {
+		if err := j.encoder.Encode(j.makeRecord(fileInfo, p, positionsByLine[p.Line])); err != nil {
+			return
+		}
+	}
+}
+
+func (j *JSON) WriteSummary(summary stats.Summary) {
+	failures := make([]FailureRecord, 0, len(summary.Failures))
+	for _, f := range summary.Failures {
+		failures = append(failures, FailureRecord{Path: present.FailedPath(f.Path), Error: f.Err.Error()})
+	}
+
+	_ = j.encoder.Encode(
+		&SummaryRecord{
+			Type:            recordTypeSummary,
+			Repo:            j.source.Repository(),
+			Ref:             j.source.Ref,
+			Commit:          j.source.Commit,
+			Pattern:         j.pattern,
+			FilesFetched:    summary.FilesFetched,
+			FilesMatched:    summary.FilesMatched,
+			Matches:         summary.Matches,
+			SkippedBinary:   summary.SkippedBinary,
+			FilteredByType:  summary.FilteredByType,
+			FilteredByPath:  summary.FilteredByPath,
+			Failures:        failures,
+			BytesDownloaded: summary.BytesDownloaded,
+			APIRequests:     summary.APIRequests,
+			APICost:         summary.APICost,
+			ElapsedSeconds:  summary.Elapsed.Seconds(),
+		},
+	)
+}
+
+func (j *JSON) Close() error {
+	return nil
+}
+
+func (j *JSON) makeRecord(fileInfo *types.FileInfo, p *match.FilePosition, sameLine []*match.FilePosition) *Record {
+	// Columns locate the match in the file, so are unaffected by redacting the text
+	text := p.Text
+	if j.redactSecrets && p.RuleID != "" {
+		text, _, _ = present.RedactSecrets(p, sameLine)
+	}
+
+	return &Record{
+		Type:        recordTypeMatch,
+		Repo:        j.source.Repository(),
+		Ref:         j.source.Ref,
+		Commit:      j.source.Commit,
+		Path:        fileInfo.Path,
+		Line:        p.Line + 1,
+		LineEnd:     p.LineEnd + 1,
+		ColumnStart: p.ColumnStart,
+		ColumnEnd:   p.ColumnEnd,
+		Text:        text,
+		TextColumn:  p.TextColumn,
+		Pattern:     j.pattern,
+		RuleID:      p.RuleID,
+		Binary:      p.Binary,
+		Permalink:   j.source.Permalink(fileInfo.Path, p.Line, p.LineEnd, p.Binary),
+	}
+}
@@ -0,0 +1,221 @@
+package present
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	fetchTypes "github.com/agrski/greg/pkg/fetch/types"
+	"github.com/agrski/greg/pkg/match"
+	"github.com/agrski/greg/pkg/types"
+)
+
+// Presenter shows the matches found in each file, such as for people to read or for other tools to ingest.
+// Close must be called once all files have been written, as some formats are only complete at the end.
+type Presenter interface {
+	Write(fileInfo *types.FileInfo, m *match.Match)
+	Close() error
+}
+
+// Source describes where matches were found, for presenters which record it alongside them.
+type Source struct {
+	Location fetchTypes.Location
+	// Ref is the branch, tag, or other expression which was searched, such as main.
+	Ref string
+	// Commit is the full SHA of the commit which was searched.
+	Commit string
+}
+
+// Repository names the repository, including its host, e.g. github.com/agrski/greg.
+func (s Source) Repository() string {
+	return fmt.Sprintf("%s/%s/%s", s.Location.Host, s.Location.Organisation, s.Location.Repository)
+}
+
+// Permalink links to lines of a file as of the commit searched, so the link keeps working as the file changes.
+// Lines count from 0, as in positions. Files in archives and binary files are linked to as a whole.
+func (s Source) Permalink(path string, line uint, lineEnd uint, binary bool) string {
+	revision := s.Commit
+	if revision == "" {
+		revision = s.Ref
+	}
+
+	wholeFile := binary
+	if idx := strings.Index(path, types.MemberSeparator); idx != -1 {
+		path = path[:idx]
+		wholeFile = true
+	}
+
+	segments := strings.Split(path, "/")
+	for idx, segment := range segments {
+		segments[idx] = url.PathEscape(segment)
+	}
+
+	link := fmt.Sprintf(
+		"https://%s/blob/%s/%s",
+		s.Repository(),
+		url.PathEscape(revision),
+		strings.Join(segments, "/"),
+	)
+	if wholeFile {
+		return link
+	}
+
+	link += fmt.Sprintf("#L%d", line+1)
+	if lineEnd > line {
+		link += fmt.Sprintf("-L%d", lineEnd+1)
+	}
+
+	return link
+}
+
+// RedactSecrets hides every secret on the line of p, given all the positions starting on that line,
+// and returns the new line and the new span of p within it.
+func RedactSecrets(p *match.FilePosition, sameLine []*match.FilePosition) (string, uint, uint) {
+	secrets := make([]*match.FilePosition, 0, len(sameLine))
+	for _, other := range sameLine {
+		if other.RuleID != "" {
+			secrets = append(secrets, other)
+		}
+	}
+	sort.SliceStable(secrets, func(i, j int) bool {
+		return secrets[i].ColumnStart < secrets[j].ColumnStart
+	})
+
+	sb := strings.Builder{}
+	columnStart, columnEnd := p.ColumnStart, p.ColumnEnd
+	var written, redactedStart uint
+
+	for _, s := range secrets {
+		// Overlapping secrets are hidden by the redaction of the earlier one
+		if s.ColumnStart >= written {
+			sb.WriteString(p.Text[written:s.ColumnStart])
+			redactedStart = uint(sb.Len())
+			sb.WriteString(match.RedactSecret(p.Text[s.ColumnStart:s.ColumnEnd]))
+		}
+		if s == p {
+			columnStart, columnEnd = redactedStart, uint(sb.Len())
+		}
+		if s.ColumnEnd > written {
+			written = s.ColumnEnd
+		}
+	}
+	sb.WriteString(p.Text[written:])
+
+	return sb.String(), columnStart, columnEnd
+}
+
+// RedactLine hides every secret on a line of context.
+func RedactLine(l *match.Line) string {
+	if len(l.Secrets) == 0 {
+		return l.Text
+	}
+
+	text, _, _ := RedactSecrets(l.Secrets[0], l.Secrets)
+
+	return text
+}
+
+// PositionsByLine groups positions by the line they start on.
+func PositionsByLine(m *match.Match) map[uint][]*match.FilePosition {
+	positionsByLine := map[uint][]*match.FilePosition{}
+	for _, p := range m.Positions {
+		positionsByLine[p.Line] = append(positionsByLine[p.Line], p)
+	}
+
+	return positionsByLine
+}
+
+// LineGroup is the text of a line with every match starting on it, so the line can be shown once with all of them highlighted.
+type LineGroup struct {
+	// Line is the first line of Text, counting from 0, and LineEnd the last line any of the positions cover.
+	Line    uint
+	LineEnd uint
+	// Text is shared by all the positions, with any secrets already redacted.
+	Text string
+	// Positions are in order of where they start, and each has the span at the same index.
+	Positions []*match.FilePosition
+	Spans     []Span
+}
+
+// Span is a half-open range of bytes [Start, End) within the text of a line group.
+type Span struct {
+	Start uint
+	End   uint
+}
+
+// GroupByLine groups positions by the line they start on, in the order the lines were first matched.
+// Positions are only grouped when they share the same text, so excerpts of different parts of a line,
+// matches spanning several lines, and binary matches may each have a group of their own.
+func GroupByLine(m *match.Match, redactSecrets bool) []*LineGroup {
+	groups := []*LineGroup{}
+	byLine := map[uint][]*LineGroup{}
+
+	for _, p := range m.Positions {
+		var group *LineGroup
+		for _, g := range byLine[p.Line] {
+			first := g.Positions[0]
+			if !p.Binary && !first.Binary && p.Text == first.Text && p.TextColumn == first.TextColumn {
+				group = g
+				break
+			}
+		}
+
+		if group == nil {
+			group = &LineGroup{Line: p.Line, LineEnd: p.LineEnd, Text: p.Text}
+			groups = append(groups, group)
+			byLine[p.Line] = append(byLine[p.Line], group)
+		}
+
+		group.Positions = append(group.Positions, p)
+		if p.LineEnd > group.LineEnd {
+			group.LineEnd = p.LineEnd
+		}
+	}
+
+	for _, g := range groups {
+		sort.SliceStable(g.Positions, func(i, j int) bool {
+			return g.Positions[i].ColumnStart < g.Positions[j].ColumnStart
+		})
+
+		g.Spans = make([]Span, len(g.Positions))
+		for idx, p := range g.Positions {
+			if redactSecrets && hasSecrets(g.Positions) {
+				var start, end uint
+				g.Text, start, end = RedactSecrets(p, g.Positions)
+				g.Spans[idx] = Span{Start: start, End: end}
+				continue
+			}
+
+			// The end column of a match spanning several lines is within its last line
+			start, end := p.Span()
+			g.Spans[idx] = Span{Start: start, End: lineOffset(p.Text, p.LineEnd-p.Line) + end}
+		}
+	}
+
+	return groups
+}
+
+// lineOffset gives where a line of text starts, counting lines from 0.
+func lineOffset(text string, line uint) uint {
+	var offset uint
+	for ; line > 0; line-- {
+		idx := strings.IndexByte(text[offset:], '\n')
+		if idx == -1 {
+			break
+		}
+		offset += uint(idx) + 1
+	}
+
+	return offset
+}
+
+func hasSecrets(positions []*match.FilePosition) bool {
+	for _, p := range positions {
+		if p.RuleID != "" {
+			return true
+		}
+	}
+
+	return false
+}
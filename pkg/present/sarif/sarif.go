@@ -0,0 +1,266 @@
+package sarif
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/agrski/greg/pkg/match"
+	"github.com/agrski/greg/pkg/present"
+	"github.com/agrski/greg/pkg/stats"
+	"github.com/agrski/greg/pkg/types"
+)
+
+const (
+	schemaURI      = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion   = "2.1.0"
+	toolName       = "greg"
+	toolURI        = "https://github.com/agrski/greg"
+	columnKind     = "unicodeCodePoints"
+	levelWarning   = "warning"
+	levelError     = "error"
+	indent         = "  "
+	httpsScheme    = "https://"
+	DefaultRuleID  = "pattern"
+	patternMessage = "Found %q"
+	secretMessage  = "Possible secret: %s"
+	failureMessage = "Unable to search: %v"
+	timeFormat     = "2006-01-02T15:04:05.000Z"
+)
+
+type Config struct {
+	Source  present.Source
+	Pattern string
+	// RuleID names the rule for matches of the pattern, or is DefaultRuleID if empty.
+	RuleID string
+	// SecretRules describe the rules of any matches found when looking for secrets.
+	SecretRules []*match.SecretRule
+	// RedactSecrets hides most of the text of matches found by secret rules.
+	RedactSecrets bool
+}
+
+// SARIF collects matches as the results of a single SARIF 2.1.0 run, which is written as one document on Close.
+type SARIF struct {
+	out           io.Writer
+	source        present.Source
+	pattern       string
+	ruleID        string
+	secretRules   map[string]*match.SecretRule
+	redactSecrets bool
+	rules         []*reportingDescriptor
+	ruleIndices   map[string]int
+	results       []*result
+	invocations   []*invocation
+}
+
+var _ present.SummaryPresenter = (*SARIF)(nil)
+
+func New(out io.Writer, config Config) *SARIF {
+	ruleID := config.RuleID
+	if ruleID == "" {
+		ruleID = DefaultRuleID
+	}
+
+	secretRules := map[string]*match.SecretRule{}
+	for _, r := range config.SecretRules {
+		secretRules[r.ID] = r
+	}
+
+	return &SARIF{
+		out:           out,
+		source:        config.Source,
+		pattern:       config.Pattern,
+		ruleID:        ruleID,
+		secretRules:   secretRules,
+		redactSecrets: config.RedactSecrets,
+		ruleIndices:   map[string]int{},
+	}
+}
+
+func (s *SARIF) Write(fileInfo *types.FileInfo, m *match.Match) {
+	positionsByLine := present.PositionsByLine(m)
+
+	for _, p := range m.Positions {
+		s.results = append(s.results, s.makeResult(fileInfo, p, positionsByLine[p.Line]))
+	}
+}
+
+// WriteSummary records the search as an invocation, which is successful if every path could be searched.
+func (s *SARIF) WriteSummary(summary stats.Summary) {
+	notifications := make([]*notification, 0, len(summary.Failures))
+	for _, f := range summary.Failures {
+		notifications = append(
+			notifications,
+			&notification{
+				Level:   levelError,
+				Message: message{Text: fmt.Sprintf(failureMessage, f.Err)},
+				Locations: []*location{
+					{PhysicalLocation: physicalLocation{ArtifactLocation: artifactLocation{URI: present.FailedPath(f.Path)}}},
+				},
+			},
+		)
+	}
+
+	s.invocations = append(
+		s.invocations,
+		&invocation{
+			ExecutionSuccessful:        len(summary.Failures) == 0,
+			StartTimeUTC:               summary.Started.UTC().Format(timeFormat),
+			EndTimeUTC:                 summary.Started.Add(summary.Elapsed).UTC().Format(timeFormat),
+			ToolExecutionNotifications: notifications,
+			Properties: &invocationProperties{
+				FilesFetched:    summary.FilesFetched,
+				FilesMatched:    summary.FilesMatched,
+				Matches:         summary.Matches,
+				SkippedBinary:   summary.SkippedBinary,
+				FilteredByType:  summary.FilteredByType,
+				FilteredByPath:  summary.FilteredByPath,
+				BytesDownloaded: summary.BytesDownloaded,
+				APIRequests:     summary.APIRequests,
+				APICost:         summary.APICost,
+			},
+		},
+	)
+}
+
+// Close writes the document holding every result, which is valid even when there are none.
+func (s *SARIF) Close() error {
+	doc := &log{
+		Schema:  schemaURI,
+		Version: sarifVersion,
+		Runs: []*run{
+			{
+				Tool: tool{
+					Driver: toolComponent{
+						Name:           toolName,
+						InformationURI: toolURI,
+						Rules:          s.rules,
+					},
+				},
+				VersionControlProvenance: []*versionControlDetails{
+					{
+						RepositoryURI: httpsScheme + s.source.Repository(),
+						RevisionID:    s.source.Commit,
+						Branch:        s.source.Ref,
+					},
+				},
+				ColumnKind:  columnKind,
+				Results:     s.results,
+				Invocations: s.invocations,
+			},
+		},
+	}
+	if doc.Runs[0].Results == nil {
+		doc.Runs[0].Results = []*result{}
+	}
+
+	b, err := json.MarshalIndent(doc, "", indent)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	_, err = s.out.Write(b)
+
+	return err
+}
+
+func (s *SARIF) makeResult(fileInfo *types.FileInfo, p *match.FilePosition, sameLine []*match.FilePosition) *result {
+	ruleID, level, msg := s.ruleID, levelWarning, fmt.Sprintf(patternMessage, s.pattern)
+	if p.RuleID != "" {
+		ruleID, level, msg = p.RuleID, levelError, fmt.Sprintf(secretMessage, s.describeSecret(p.RuleID))
+	}
+
+	snippet := p.Text
+	if s.redactSecrets && p.RuleID != "" {
+		snippet, _, _ = present.RedactSecrets(p, sameLine)
+	}
+
+	return &result{
+		RuleID:    ruleID,
+		RuleIndex: s.ensureRule(ruleID),
+		Level:     level,
+		Message:   message{Text: msg},
+		Locations: []*location{
+			{
+				PhysicalLocation: physicalLocation{
+					ArtifactLocation: artifactLocation{URI: fileInfo.Path},
+					Region:           makeRegion(p, snippet),
+				},
+			},
+		},
+	}
+}
+
+// makeRegion locates a match in its file, showing the given snippet of the text around it.
+// Binary files are located by byte offsets, and text files by lines and columns of code points.
+func makeRegion(p *match.FilePosition, snippet string) *region {
+	if p.Binary {
+		offset := p.ColumnStart
+		length := p.ColumnEnd - p.ColumnStart
+
+		return &region{ByteOffset: &offset, ByteLength: &length}
+	}
+
+	// Columns count code points in the original text, as a redacted snippet no longer lines up with the file
+	lines := strings.Split(p.Text, "\n")
+	firstLine, lastLine := lines[0], lines[len(lines)-1]
+	columnStart, columnEnd := p.Span()
+
+	startColumn := p.TextCharacters + codePoints(firstLine, columnStart) + 1
+	endColumn := codePoints(lastLine, columnEnd) + 1
+	if len(lines) == 1 {
+		endColumn += p.TextCharacters
+	}
+
+	return &region{
+		StartLine:   p.Line + 1,
+		StartColumn: startColumn,
+		EndLine:     p.LineEnd + 1,
+		EndColumn:   endColumn,
+		Snippet:     &artifactContent{Text: snippet},
+	}
+}
+
+// codePoints counts the code points before a byte offset in a line.
+func codePoints(line string, byteColumn uint) uint {
+	if int(byteColumn) > len(line) {
+		byteColumn = uint(len(line))
+	}
+
+	return uint(utf8.RuneCountInString(line[:byteColumn]))
+}
+
+// ensureRule describes a rule the first time it is used, and gives its index in the list of rules.
+func (s *SARIF) ensureRule(ruleID string) int {
+	if idx, ok := s.ruleIndices[ruleID]; ok {
+		return idx
+	}
+
+	description := fmt.Sprintf(patternMessage, s.pattern)
+	if ruleID != s.ruleID {
+		description = s.describeSecret(ruleID)
+	}
+
+	idx := len(s.rules)
+	s.rules = append(
+		s.rules,
+		&reportingDescriptor{
+			ID:               ruleID,
+			ShortDescription: &message{Text: description},
+		},
+	)
+	s.ruleIndices[ruleID] = idx
+
+	return idx
+}
+
+func (s *SARIF) describeSecret(ruleID string) string {
+	if r, ok := s.secretRules[ruleID]; ok && r.Description != "" {
+		return r.Description
+	}
+
+	return ruleID
+}
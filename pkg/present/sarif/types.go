@@ -0,0 +1,107 @@
+package sarif
+
+// The types below are the subset of the SARIF 2.1.0 object model which greg produces.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html for the full specification.
+
+type log struct {
+	Schema  string `json:"$schema"`
+	Version string `json:"version"`
+	Runs    []*run `json:"runs"`
+}
+
+type run struct {
+	Tool                     tool                     `json:"tool"`
+	VersionControlProvenance []*versionControlDetails `json:"versionControlProvenance,omitempty"`
+	ColumnKind               string                   `json:"columnKind"`
+	Results                  []*result                `json:"results"`
+	Invocations              []*invocation            `json:"invocations,omitempty"`
+}
+
+type tool struct {
+	Driver toolComponent `json:"driver"`
+}
+
+type toolComponent struct {
+	Name           string                 `json:"name"`
+	InformationURI string                 `json:"informationUri,omitempty"`
+	Rules          []*reportingDescriptor `json:"rules,omitempty"`
+}
+
+type reportingDescriptor struct {
+	ID               string   `json:"id"`
+	ShortDescription *message `json:"shortDescription,omitempty"`
+}
+
+type versionControlDetails struct {
+	RepositoryURI string `json:"repositoryUri"`
+	RevisionID    string `json:"revisionId,omitempty"`
+	Branch        string `json:"branch,omitempty"`
+}
+
+type result struct {
+	RuleID    string      `json:"ruleId"`
+	RuleIndex int         `json:"ruleIndex"`
+	Level     string      `json:"level"`
+	Message   message     `json:"message"`
+	Locations []*location `json:"locations"`
+}
+
+type message struct {
+	Text string `json:"text"`
+}
+
+type location struct {
+	PhysicalLocation physicalLocation `json:"physicalLocation"`
+}
+
+type physicalLocation struct {
+	ArtifactLocation artifactLocation `json:"artifactLocation"`
+	Region           *region          `json:"region,omitempty"`
+}
+
+type artifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// region is located either by lines and columns, counting from 1, or by bytes, counting from 0.
+type region struct {
+	StartLine   uint             `json:"startLine,omitempty"`
+	StartColumn uint             `json:"startColumn,omitempty"`
+	EndLine     uint             `json:"endLine,omitempty"`
+	EndColumn   uint             `json:"endColumn,omitempty"`
+	ByteOffset  *uint            `json:"byteOffset,omitempty"`
+	ByteLength  *uint            `json:"byteLength,omitempty"`
+	Snippet     *artifactContent `json:"snippet,omitempty"`
+}
+
+type artifactContent struct {
+	Text string `json:"text"`
+}
+
+// invocation describes the search, with any paths which could not be searched as notifications.
+type invocation struct {
+	ExecutionSuccessful        bool                  `json:"executionSuccessful"`
+	StartTimeUTC               string                `json:"startTimeUtc"`
+	EndTimeUTC                 string                `json:"endTimeUtc"`
+	ToolExecutionNotifications []*notification       `json:"toolExecutionNotifications,omitempty"`
+	Properties                 *invocationProperties `json:"properties"`
+}
+
+type notification struct {
+	Level     string      `json:"level"`
+	Message   message     `json:"message"`
+	Locations []*location `json:"locations"`
+}
+
+// invocationProperties is a property bag of greg's own statistics, which SARIF has no properties for.
+type invocationProperties struct {
+	FilesFetched    uint64 `json:"filesFetched"`
+	FilesMatched    uint64 `json:"filesMatched"`
+	Matches         uint64 `json:"matches"`
+	SkippedBinary   uint64 `json:"skippedBinary"`
+	FilteredByType  uint64 `json:"filteredByType"`
+	FilteredByPath  uint64 `json:"filteredByPath"`
+	BytesDownloaded uint64 `json:"bytesDownloaded"`
+	APIRequests     uint64 `json:"apiRequests"`
+	APICost         uint64 `json:"apiCost"`
+}
@@ -0,0 +1,94 @@
+package present
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/agrski/greg/pkg/stats"
+)
+
+const bytesPerUnit = 1024
+
+// SummaryPresenter is implemented by presenters which include a summary of the search in their own output.
+// The summary is given before Close, so presenters which write everything on Close can include it.
+type SummaryPresenter interface {
+	Presenter
+	WriteSummary(summary stats.Summary)
+}
+
+// WriteSummary describes a search for people to read, for when the presenter cannot include it.
+func WriteSummary(out io.Writer, summary stats.Summary) error {
+	sb := strings.Builder{}
+
+	sb.WriteString(
+		fmt.Sprintf(
+			"%s in %s of %s fetched\n",
+			plural(summary.Matches, "match", "matches"),
+			plural(summary.FilesMatched, "file", "files"),
+			plural(summary.FilesFetched, "file", "files"),
+		),
+	)
+	sb.WriteString(
+		fmt.Sprintf(
+			"skipped %d binary, %d by file type, %d by path\n",
+			summary.SkippedBinary,
+			summary.FilteredByType,
+			summary.FilteredByPath,
+		),
+	)
+	if len(summary.Failures) > 0 {
+		sb.WriteString(fmt.Sprintf("failed %d:\n", len(summary.Failures)))
+		for _, f := range summary.Failures {
+			sb.WriteString(fmt.Sprintf("  %s: %v\n", FailedPath(f.Path), f.Err))
+		}
+	}
+	sb.WriteString(
+		fmt.Sprintf(
+			"downloaded %s in %s costing %s\n",
+			FormatBytes(summary.BytesDownloaded),
+			plural(summary.APIRequests, "API request", "API requests"),
+			plural(summary.APICost, "point", "points"),
+		),
+	)
+	sb.WriteString(fmt.Sprintf("took %s\n", summary.Elapsed.Round(time.Millisecond)))
+
+	_, err := io.WriteString(out, sb.String())
+
+	return err
+}
+
+// FailedPath names the root of the repository, which has an empty path, so it can be told apart in output.
+func FailedPath(path string) string {
+	if path == "" {
+		return "."
+	}
+
+	return path
+}
+
+// FormatBytes gives a size in the largest binary unit it fills, such as 1.5 KiB.
+func FormatBytes(bytes uint64) string {
+	if bytes < bytesPerUnit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	size := float64(bytes) / bytesPerUnit
+	units := []string{"KiB", "MiB", "GiB", "TiB"}
+	unit := 0
+	for size >= bytesPerUnit && unit < len(units)-1 {
+		size /= bytesPerUnit
+		unit++
+	}
+
+	return fmt.Sprintf("%.1f %s", size, units[unit])
+}
+
+func plural(n uint64, singular string, plural string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+
+	return fmt.Sprintf("%d %s", n, plural)
+}
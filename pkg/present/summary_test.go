@@ -0,0 +1,86 @@
+package present
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/agrski/greg/pkg/stats"
+)
+
+func TestWriteSummary(t *testing.T) {
+	type test struct {
+		name     string
+		summary  stats.Summary
+		expected string
+	}
+
+	tests := []test{
+		{
+			name: "everything",
+			summary: stats.Summary{
+				FilesFetched:    120,
+				FilesMatched:    3,
+				Matches:         12,
+				SkippedBinary:   4,
+				FilteredByType:  10,
+				FilteredByPath:  2,
+				Failures:        []stats.Failure{{Path: "", Err: errors.New("timeout")}, {Path: "a.gz", Err: errors.New("unexpected EOF")}},
+				BytesDownloaded: 1536,
+				APIRequests:     15,
+				APICost:         14,
+				Elapsed:         2345678 * time.Microsecond,
+			},
+			expected: `12 matches in 3 files of 120 files fetched
+skipped 4 binary, 10 by file type, 2 by path
+failed 2:
+  .: timeout
+  a.gz: unexpected EOF
+downloaded 1.5 KiB in 15 API requests costing 14 points
+took 2.346s
+`,
+		},
+		{
+			name:    "singular",
+			summary: stats.Summary{FilesFetched: 1, FilesMatched: 1, Matches: 1, BytesDownloaded: 10, APIRequests: 1, APICost: 1},
+			expected: `1 match in 1 file of 1 file fetched
+skipped 0 binary, 0 by file type, 0 by path
+downloaded 10 B in 1 API request costing 1 point
+took 0s
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sb := &strings.Builder{}
+			require.NoError(t, WriteSummary(sb, tt.summary))
+			require.Equal(t, tt.expected, sb.String())
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	type test struct {
+		bytes    uint64
+		expected string
+	}
+
+	tests := []test{
+		{bytes: 0, expected: "0 B"},
+		{bytes: 1023, expected: "1023 B"},
+		{bytes: 1024, expected: "1.0 KiB"},
+		{bytes: 5 * 1024 * 1024, expected: "5.0 MiB"},
+		{bytes: 3 << 40, expected: "3.0 TiB"},
+		{bytes: 2048 << 40, expected: "2048.0 TiB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			require.Equal(t, tt.expected, FormatBytes(tt.bytes))
+		})
+	}
+}
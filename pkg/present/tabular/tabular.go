@@ -0,0 +1,216 @@
+package tabular
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/agrski/greg/pkg/match"
+	"github.com/agrski/greg/pkg/present"
+	"github.com/agrski/greg/pkg/types"
+)
+
+type Column string
+
+const (
+	ColumnRepository Column = "repository"
+	ColumnPath       Column = "path"
+	ColumnLine       Column = "line"
+	ColumnColumn     Column = "column"
+	ColumnMatch      Column = "match"
+	ColumnText       Column = "text"
+	ColumnPermalink  Column = "permalink"
+)
+
+var (
+	// DefaultColumns are every column, in the order of the fields of a match.
+	DefaultColumns = []Column{
+		ColumnRepository,
+		ColumnPath,
+		ColumnLine,
+		ColumnColumn,
+		ColumnMatch,
+		ColumnText,
+		ColumnPermalink,
+	}
+	columnAliases = map[string]Column{
+		"repo": ColumnRepository,
+		"file": ColumnPath,
+		"col":  ColumnColumn,
+		"url":  ColumnPermalink,
+	}
+)
+
+const (
+	SeparatorCSV = ','
+	SeparatorTSV = '\t'
+)
+
+// ParseColumns reads a comma-separated list of column names, such as path,line,text.
+func ParseColumns(s string) ([]Column, error) {
+	if strings.TrimSpace(s) == "" {
+		return DefaultColumns, nil
+	}
+
+	columns := []Column{}
+	for _, name := range strings.Split(s, ",") {
+		normalised := strings.ToLower(strings.TrimSpace(name))
+		if c, ok := columnAliases[normalised]; ok {
+			normalised = string(c)
+		}
+
+		known := false
+		for _, c := range DefaultColumns {
+			if Column(normalised) == c {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown column %s", name)
+		}
+
+		columns = append(columns, Column(normalised))
+	}
+
+	return columns, nil
+}
+
+type Config struct {
+	Source present.Source
+	// Separator is the character between fields, such as SeparatorCSV or SeparatorTSV.
+	Separator rune
+	// Columns are the fields written for each match, in order.
+	Columns []Column
+	// Header writes the names of the columns as the first row.
+	Header bool
+	// RedactSecrets hides most of the text of matches found by secret rules.
+	RedactSecrets bool
+}
+
+// Tabular writes a row per match, quoting fields as in RFC 4180 so line text may hold any characters.
+type Tabular struct {
+	source        present.Source
+	columns       []Column
+	header        bool
+	wroteHeader   bool
+	redactSecrets bool
+	writer        *csv.Writer
+}
+
+var _ present.Presenter = (*Tabular)(nil)
+
+func New(out io.Writer, config Config) *Tabular {
+	writer := csv.NewWriter(out)
+	if config.Separator != 0 {
+		writer.Comma = config.Separator
+	}
+
+	columns := config.Columns
+	if len(columns) == 0 {
+		columns = DefaultColumns
+	}
+
+	return &Tabular{
+		source:        config.Source,
+		columns:       columns,
+		header:        config.Header,
+		redactSecrets: config.RedactSecrets,
+		writer:        writer,
+	}
+}
+
+func (t *Tabular) Write(fileInfo *types.FileInfo, m *match.Match) {
+	if err := t.ensureHeader(); err != nil {
+		return
+	}
+
+	positionsByLine := present.PositionsByLine(m)
+	for _, p := range m.Positions {
+		if err := t.writer.Write(t.makeRow(fileInfo, p, positionsByLine[p.Line])); err != nil {
+			return
+		}
+	}
+
+	// Rows are written as they are found, rather than only once the buffer fills
+	t.writer.Flush()
+}
+
+// Close writes the header if there were no matches, so the output always has the same shape.
+func (t *Tabular) Close() error {
+	if err := t.ensureHeader(); err != nil {
+		return err
+	}
+
+	t.writer.Flush()
+
+	return t.writer.Error()
+}
+
+func (t *Tabular) ensureHeader() error {
+	if !t.header || t.wroteHeader {
+		return nil
+	}
+	t.wroteHeader = true
+
+	names := make([]string, len(t.columns))
+	for idx, c := range t.columns {
+		names[idx] = string(c)
+	}
+
+	return t.writer.Write(names)
+}
+
+func (t *Tabular) makeRow(fileInfo *types.FileInfo, p *match.FilePosition, sameLine []*match.FilePosition) []string {
+	text := p.Text
+	columnStart, columnEnd := p.Span()
+	if t.redactSecrets && p.RuleID != "" {
+		text, columnStart, columnEnd = present.RedactSecrets(p, sameLine)
+	}
+
+	row := make([]string, len(t.columns))
+	for idx, c := range t.columns {
+		switch c {
+		case ColumnRepository:
+			row[idx] = t.source.Repository()
+		case ColumnPath:
+			row[idx] = fileInfo.Path
+		case ColumnLine:
+			if !p.Binary {
+				row[idx] = strconv.FormatUint(uint64(p.Line+1), 10)
+			}
+		case ColumnColumn:
+			// Columns count bytes from 1, like line numbers
+			row[idx] = strconv.FormatUint(uint64(p.ColumnStart+1), 10)
+		case ColumnMatch:
+			row[idx] = matchedText(text, columnStart, columnEnd)
+		case ColumnText:
+			// Binary files have no lines to show
+			if !p.Binary {
+				row[idx] = text
+			}
+		case ColumnPermalink:
+			row[idx] = t.source.Permalink(fileInfo.Path, p.Line, p.LineEnd, p.Binary)
+		}
+	}
+
+	return row
+}
+
+// matchedText gives the text of a match, which ends in the last line of its text.
+func matchedText(text string, columnStart uint, columnEnd uint) string {
+	end := int(columnEnd)
+	if lastLineStart := strings.LastIndex(text, "\n"); lastLineStart != -1 {
+		end += lastLineStart + 1
+	}
+	if end > len(text) {
+		end = len(text)
+	}
+	if int(columnStart) > end {
+		return ""
+	}
+
+	return text[columnStart:end]
+}
@@ -0,0 +1,95 @@
+package tui
+
+import (
+	"unicode/utf8"
+)
+
+type key int
+
+const (
+	keyRune key = iota
+	keyUp
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyEnter
+	keyEscape
+	keyBackspace
+	keyInterrupt
+)
+
+// keyPress is a key read from the terminal, with the character typed for keyRune.
+type keyPress struct {
+	key  key
+	char rune
+}
+
+var escapeSequences = map[string]key{
+	csi + "A":     keyUp,
+	csi + "B":     keyDown,
+	csi + "5~":    keyPageUp,
+	csi + "6~":    keyPageDown,
+	csi + "H":     keyHome,
+	csi + "F":     keyEnd,
+	csi + "1~":    keyHome,
+	csi + "4~":    keyEnd,
+	escape + "OA": keyUp,
+	escape + "OB": keyDown,
+	escape + "OH": keyHome,
+	escape + "OF": keyEnd,
+}
+
+// parseKeys reads the keys from a single read of a terminal in raw mode.
+// Escape sequences arrive whole, so an escape byte by itself at the end is the escape key.
+func parseKeys(b []byte) []keyPress {
+	keys := []keyPress{}
+
+	for len(b) > 0 {
+		if b[0] == escape[0] {
+			k, length := parseEscapeSequence(b)
+			if length > 0 {
+				keys = append(keys, keyPress{key: k})
+				b = b[length:]
+				continue
+			}
+		}
+
+		switch b[0] {
+		case '\r', '\n':
+			keys = append(keys, keyPress{key: keyEnter})
+		case 0x7f, 0x08:
+			keys = append(keys, keyPress{key: keyBackspace})
+		case 0x03:
+			keys = append(keys, keyPress{key: keyInterrupt})
+		case 0x1b:
+			keys = append(keys, keyPress{key: keyEscape})
+		case 0x02:
+			keys = append(keys, keyPress{key: keyPageUp})
+		case 0x06:
+			keys = append(keys, keyPress{key: keyPageDown})
+		default:
+			r, size := utf8.DecodeRune(b)
+			if r >= ' ' {
+				keys = append(keys, keyPress{key: keyRune, char: r})
+			}
+			b = b[size:]
+			continue
+		}
+
+		b = b[1:]
+	}
+
+	return keys
+}
+
+func parseEscapeSequence(b []byte) (key, int) {
+	for sequence, k := range escapeSequences {
+		if len(b) >= len(sequence) && string(b[:len(sequence)]) == sequence {
+			return k, len(sequence)
+		}
+	}
+
+	return keyEscape, 0
+}
@@ -0,0 +1,55 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseKeys(t *testing.T) {
+	type test struct {
+		name     string
+		input    string
+		expected []keyPress
+	}
+
+	tests := []test{
+		{
+			name:     "characters",
+			input:    "jké",
+			expected: []keyPress{{key: keyRune, char: 'j'}, {key: keyRune, char: 'k'}, {key: keyRune, char: 'é'}},
+		},
+		{
+			name:     "arrows in both modes",
+			input:    "\x1b[A\x1bOB",
+			expected: []keyPress{{key: keyUp}, {key: keyDown}},
+		},
+		{
+			name:     "paging and jumping",
+			input:    "\x1b[5~\x1b[6~\x1b[H\x1b[4~\x02\x06",
+			expected: []keyPress{{key: keyPageUp}, {key: keyPageDown}, {key: keyHome}, {key: keyEnd}, {key: keyPageUp}, {key: keyPageDown}},
+		},
+		{
+			name:     "escape by itself",
+			input:    "\x1b",
+			expected: []keyPress{{key: keyEscape}},
+		},
+		{
+			name:     "control keys",
+			input:    "\r\x7f\x03",
+			expected: []keyPress{{key: keyEnter}, {key: keyBackspace}, {key: keyInterrupt}},
+		},
+		{
+			name:     "unknown control characters are ignored",
+			input:    "\x01q",
+			expected: []keyPress{{key: keyRune, char: 'q'}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := parseKeys([]byte(tt.input))
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
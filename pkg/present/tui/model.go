@@ -0,0 +1,534 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/agrski/greg/pkg/match"
+	"github.com/agrski/greg/pkg/present"
+	"github.com/agrski/greg/pkg/types"
+)
+
+const (
+	tabWidth = 4
+	// minListHeight keeps some results visible in even the smallest terminals.
+	minListHeight = 1
+	// binaryPlaceholder stands in for bytes which cannot be shown as text.
+	binaryPlaceholder = '.'
+	helpText          = "j/k move  n/N file  / filter  o open  q quit"
+	filterPrompt      = "/"
+)
+
+// file holds what is known of a file with matches, which is all its lines when they were fetched as text.
+type file struct {
+	path  string
+	lines []string
+}
+
+// entry is a single match, shown as a row in the list of results.
+type entry struct {
+	file     *file
+	position *match.FilePosition
+	// text and the span of the match within it are as shown, so any secrets are already redacted.
+	text        string
+	columnStart uint
+	columnEnd   uint
+}
+
+// model is the state of the interface, kept apart from the terminal so it can be tested.
+// It is not safe for concurrent use.
+type model struct {
+	source        present.Source
+	redactSecrets bool
+	entries       []*entry
+	// visible indexes the entries which pass the filter, in order.
+	visible []int
+	// selected indexes visible, and scroll is the first of those shown in the list.
+	selected  int
+	scroll    int
+	filter    string
+	filtering bool
+	done      bool
+	message   string
+}
+
+func newModel(source present.Source, redactSecrets bool) *model {
+	return &model{
+		source:        source,
+		redactSecrets: redactSecrets,
+	}
+}
+
+func (m *model) add(fileInfo *types.FileInfo, found *match.Match) {
+	f := &file{path: fileInfo.Path}
+	// Matchers which stream files leave no text behind, so only the matched lines are known
+	if !fileInfo.IsBinary && fileInfo.Body == nil && fileInfo.Text != "" {
+		f.lines = splitLines(fileInfo.Text)
+	}
+
+	positionsByLine := present.PositionsByLine(found)
+	for _, p := range found.Positions {
+		e := &entry{file: f, position: p, text: p.Text}
+		e.columnStart, e.columnEnd = p.Span()
+		if m.redactSecrets && p.RuleID != "" {
+			e.text, e.columnStart, e.columnEnd = present.RedactSecrets(p, positionsByLine[p.Line])
+		}
+		if p.Binary {
+			e.text = printable(e.text)
+		}
+
+		m.entries = append(m.entries, e)
+		if m.passesFilter(e) {
+			m.visible = append(m.visible, len(m.entries)-1)
+		}
+	}
+}
+
+func (m *model) passesFilter(e *entry) bool {
+	if m.filter == "" {
+		return true
+	}
+
+	filter := strings.ToLower(m.filter)
+
+	return strings.Contains(strings.ToLower(e.file.path), filter) || strings.Contains(strings.ToLower(e.text), filter)
+}
+
+// setFilter shows only the entries whose path or text contain the filter, ignoring case,
+// keeping the same entry selected if it is still shown.
+func (m *model) setFilter(filter string) {
+	var previous *entry
+	if e, ok := m.selectedEntry(); ok {
+		previous = e
+	}
+
+	m.filter = filter
+	m.visible = m.visible[:0]
+	m.selected = 0
+	for idx, e := range m.entries {
+		if !m.passesFilter(e) {
+			continue
+		}
+		if e == previous {
+			m.selected = len(m.visible)
+		}
+		m.visible = append(m.visible, idx)
+	}
+}
+
+func (m *model) selectedEntry() (*entry, bool) {
+	if len(m.visible) == 0 {
+		return nil, false
+	}
+
+	return m.entries[m.visible[m.selected]], true
+}
+
+// move changes the selection by some number of rows, stopping at either end.
+func (m *model) move(delta int) {
+	m.selected += delta
+	if m.selected >= len(m.visible) {
+		m.selected = len(m.visible) - 1
+	}
+	if m.selected < 0 {
+		m.selected = 0
+	}
+}
+
+// moveFile selects the first match of the next file, or of the previous file when going backwards.
+func (m *model) moveFile(forwards bool) {
+	current, ok := m.selectedEntry()
+	if !ok {
+		return
+	}
+
+	if forwards {
+		for idx := m.selected + 1; idx < len(m.visible); idx++ {
+			if m.entries[m.visible[idx]].file != current.file {
+				m.selected = idx
+				return
+			}
+		}
+
+		return
+	}
+
+	// Find the start of the previous file by going back past the start of this one
+	target := m.selected
+	for target > 0 && m.entries[m.visible[target-1]].file == current.file {
+		target--
+	}
+	if target == 0 {
+		m.selected = 0
+		return
+	}
+
+	previous := m.entries[m.visible[target-1]].file
+	for target > 0 && m.entries[m.visible[target-1]].file == previous {
+		target--
+	}
+	m.selected = target
+}
+
+// view draws the interface as lines of exactly the given height, none wider than the given width.
+// The list of results takes the top half, above a status line, a preview of the selected match, and a prompt.
+func (m *model) view(width int, height int) []string {
+	listHeight := listHeight(height)
+	previewHeight := height - 2 - listHeight
+	if previewHeight < 0 {
+		previewHeight = 0
+	}
+
+	m.scrollTo(listHeight)
+
+	lines := make([]string, 0, height)
+	lines = append(lines, m.viewList(width, listHeight)...)
+	lines = append(lines, styled(fit(m.status(), width), styleStatus))
+	lines = append(lines, m.viewPreview(width, previewHeight)...)
+	lines = append(lines, fit(m.prompt(), width))
+
+	return lines[:height]
+}
+
+// listHeight is how many rows of the given height of terminal show results.
+func listHeight(height int) int {
+	rows := (height - 2) / 2
+	if rows < minListHeight {
+		return minListHeight
+	}
+
+	return rows
+}
+
+// scrollTo keeps the selection within the rows shown in the list.
+func (m *model) scrollTo(listHeight int) {
+	if m.selected < m.scroll {
+		m.scroll = m.selected
+	}
+	if m.selected >= m.scroll+listHeight {
+		m.scroll = m.selected - listHeight + 1
+	}
+	if m.scroll < 0 {
+		m.scroll = 0
+	}
+}
+
+func (m *model) viewList(width int, height int) []string {
+	lines := make([]string, 0, height)
+
+	for row := m.scroll; row < m.scroll+height; row++ {
+		if row >= len(m.visible) {
+			lines = append(lines, "")
+			continue
+		}
+
+		e := m.entries[m.visible[row]]
+		text := e.text
+		if idx := strings.IndexByte(text, '\n'); idx != -1 {
+			text = text[:idx]
+		}
+		line := fmt.Sprintf("%s:%s: %s", e.file.path, location(e.position), strings.TrimSpace(text))
+
+		if row == m.selected {
+			lines = append(lines, styled(fit(line, width), styleSelected))
+		} else {
+			lines = append(lines, fit(line, width))
+		}
+	}
+
+	return lines
+}
+
+func (m *model) status() string {
+	state := "searching"
+	if m.done {
+		state = "done"
+	}
+
+	shown := strconv.Itoa(len(m.visible))
+	if m.filter != "" {
+		shown += "/" + strconv.Itoa(len(m.entries))
+	}
+
+	status := fmt.Sprintf(" %s matches, %s", shown, state)
+	if m.message != "" {
+		status += " - " + m.message
+	}
+
+	return status
+}
+
+func (m *model) prompt() string {
+	switch {
+	case m.filtering:
+		return filterPrompt + m.filter
+	case m.filter != "":
+		return filterPrompt + m.filter + "  (esc to clear)"
+	default:
+		return helpText
+	}
+}
+
+// viewPreview shows the lines of the file around the selected match, or just the matched text if that is all there is.
+func (m *model) viewPreview(width int, height int) []string {
+	lines := make([]string, 0, height)
+	e, ok := m.selectedEntry()
+	if !ok || height == 0 {
+		for len(lines) < height {
+			lines = append(lines, "")
+		}
+		return lines
+	}
+
+	p := e.position
+	numbered := []numberedLine{}
+	if e.file.lines != nil && !p.Binary && p.TextColumn == 0 && !(m.redactSecrets && p.RuleID != "") {
+		first := int(p.Line) - height/2
+		if first < 0 {
+			first = 0
+		}
+		for n := first; n < len(e.file.lines) && len(numbered) < height; n++ {
+			numbered = append(numbered, numberedLine{number: uint(n), text: e.file.lines[n]})
+		}
+	} else {
+		for idx, text := range splitLines(e.text) {
+			numbered = append(numbered, numberedLine{number: p.Line + uint(idx), text: text})
+		}
+	}
+
+	gutter := len(strconv.Itoa(int(p.LineEnd) + 1))
+	if len(numbered) > 0 {
+		gutter = len(strconv.Itoa(int(numbered[len(numbered)-1].number) + 1))
+	}
+
+	for _, l := range numbered {
+		if len(lines) == height {
+			break
+		}
+
+		prefix := fmt.Sprintf("%*d  ", gutter, l.number+1)
+		if p.Binary {
+			prefix = fmt.Sprintf("%08x  ", p.TextColumn)
+		}
+
+		start, end := highlightSpan(e, l)
+		lines = append(
+			lines,
+			render(
+				[]segment{
+					{text: prefix, style: styleLineNumber},
+					{text: l.text[:start]},
+					{text: l.text[start:end], style: styleMatch},
+					{text: l.text[end:]},
+				},
+				width,
+			),
+		)
+	}
+
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+
+	return lines
+}
+
+type numberedLine struct {
+	number uint
+	text   string
+}
+
+// highlightSpan gives the part of a line of the preview covered by the selected match.
+func highlightSpan(e *entry, l numberedLine) (int, int) {
+	p := e.position
+	if l.number < p.Line || l.number > p.LineEnd {
+		return 0, 0
+	}
+
+	start, end := 0, len(l.text)
+	if l.number == p.Line {
+		start = int(e.columnStart)
+	}
+	if l.number == p.LineEnd {
+		end = int(e.columnEnd)
+	}
+	if end > len(l.text) {
+		end = len(l.text)
+	}
+	if start > end {
+		start = end
+	}
+
+	return start, end
+}
+
+func location(p *match.FilePosition) string {
+	if p.Binary {
+		return "0x" + strconv.FormatUint(uint64(p.ColumnStart), 16)
+	}
+
+	return strconv.Itoa(int(p.Line) + 1)
+}
+
+// printable replaces bytes which are not printable ASCII one for one, so offsets within the text are kept.
+func printable(text string) string {
+	b := []byte(text)
+	for idx, c := range b {
+		if c < ' ' || c > '~' {
+			b[idx] = binaryPlaceholder
+		}
+	}
+
+	return string(b)
+}
+
+// fit expands tabs and control characters and cuts text to a width, counting each character as one column.
+func fit(text string, width int) string {
+	return render([]segment{{text: text}}, width)
+}
+
+// splitLines divides text into lines, dropping the carriage returns of CRLF line endings.
+func splitLines(text string) []string {
+	lines := strings.Split(text, "\n")
+	for idx, l := range lines {
+		lines[idx] = strings.TrimSuffix(l, "\r")
+	}
+
+	return lines
+}
+
+// expandText replaces tabs with spaces up to the next tab stop, given the column text starts at,
+// and other control characters with a placeholder, so text from files cannot move the cursor or restyle the terminal.
+func expandText(text string, column int) (string, int) {
+	if strings.IndexFunc(text, isControl) == -1 {
+		return text, column + utf8.RuneCountInString(text)
+	}
+
+	sb := strings.Builder{}
+	for _, r := range text {
+		switch {
+		case r == '\t':
+			spaces := tabWidth - column%tabWidth
+			sb.WriteString(strings.Repeat(" ", spaces))
+			column += spaces
+			continue
+		case isControl(r):
+			r = binaryPlaceholder
+		}
+
+		sb.WriteRune(r)
+		column++
+	}
+
+	return sb.String(), column
+}
+
+// isControl reports whether a character is a C0 or C1 control character, including tabs and escapes.
+func isControl(r rune) bool {
+	return r < ' ' || (0x7f <= r && r <= 0x9f)
+}
+
+type action int
+
+const (
+	actionNone action = iota
+	actionQuit
+	actionOpen
+)
+
+// update changes the state for a key press, given how many rows a page of results holds,
+// and says what else should be done in response.
+func (m *model) update(k keyPress, pageSize int) action {
+	m.message = ""
+
+	if k.key == keyInterrupt {
+		return actionQuit
+	}
+
+	if m.filtering {
+		m.updateFilter(k)
+		return actionNone
+	}
+
+	switch k.key {
+	case keyUp:
+		m.move(-1)
+	case keyDown:
+		m.move(1)
+	case keyPageUp:
+		m.move(-pageSize)
+	case keyPageDown:
+		m.move(pageSize)
+	case keyHome:
+		m.move(-len(m.visible))
+	case keyEnd:
+		m.move(len(m.visible))
+	case keyEscape:
+		m.setFilter("")
+	case keyEnter:
+		return actionOpen
+	case keyRune:
+		return m.updateCommand(k.char, pageSize)
+	case keyBackspace, keyInterrupt:
+	}
+
+	return actionNone
+}
+
+func (m *model) updateCommand(char rune, pageSize int) action {
+	switch char {
+	case 'q':
+		return actionQuit
+	case 'k':
+		m.move(-1)
+	case 'j':
+		m.move(1)
+	case 'g':
+		m.move(-len(m.visible))
+	case 'G':
+		m.move(len(m.visible))
+	case 'n':
+		m.moveFile(true)
+	case 'N':
+		m.moveFile(false)
+	case ' ':
+		m.move(pageSize)
+	case '/':
+		m.filtering = true
+	case 'o':
+		return actionOpen
+	}
+
+	return actionNone
+}
+
+// updateFilter edits the filter, applying it after each change so results narrow as it is typed.
+func (m *model) updateFilter(k keyPress) {
+	switch k.key {
+	case keyRune:
+		m.setFilter(m.filter + string(k.char))
+	case keyBackspace:
+		if m.filter != "" {
+			_, size := utf8.DecodeLastRuneInString(m.filter)
+			m.setFilter(m.filter[:len(m.filter)-size])
+		}
+	case keyEnter:
+		m.filtering = false
+	case keyEscape:
+		m.filtering = false
+		m.setFilter("")
+	case keyUp, keyDown, keyPageUp, keyPageDown, keyHome, keyEnd, keyInterrupt:
+	}
+}
+
+// selectedPermalink links to the selected match as of the commit searched.
+func (m *model) selectedPermalink() (string, bool) {
+	e, ok := m.selectedEntry()
+	if !ok {
+		return "", false
+	}
+
+	return m.source.Permalink(e.file.path, e.position.Line, e.position.LineEnd, e.position.Binary), true
+}
@@ -0,0 +1,28 @@
+package tui
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+// openURL opens a link in the default browser without waiting for it.
+func openURL(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// Reap the process in the background so it does not linger once done
+	go func() { _ = cmd.Wait() }()
+
+	return nil
+}
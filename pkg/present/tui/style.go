@@ -0,0 +1,84 @@
+package tui
+
+import (
+	"strings"
+)
+
+// ANSI escape sequences, which every terminal able to run the interface understands
+
+type style string
+
+const (
+	escape          = "\u001b"
+	csi             = escape + "["
+	reset           = csi + "0m"
+	clearScreen     = csi + "H" + csi + "2J"
+	moveHome        = csi + "H"
+	clearLine       = csi + "K"
+	hideCursor      = csi + "?25l"
+	showCursor      = csi + "?25h"
+	enterAltScreen  = csi + "?1049h"
+	leaveAltScreen  = csi + "?1049l"
+	styleNone       = style("")
+	styleSelected   = style(csi + "7m")
+	styleStatus     = style(csi + "1;7m")
+	styleMatch      = style(csi + "1;31m")
+	styleLineNumber = style(csi + "35m")
+)
+
+// segment is a piece of a line drawn in one style.
+type segment struct {
+	text  string
+	style style
+}
+
+func styled(line string, s style) string {
+	if s == styleNone || line == "" {
+		return line
+	}
+
+	return string(s) + line + reset
+}
+
+// render draws segments in their styles, expanding tabs and control characters and stopping at a width.
+func render(segments []segment, width int) string {
+	sb := strings.Builder{}
+	column := 0
+
+	for _, s := range segments {
+		if column >= width {
+			break
+		}
+		if s.text == "" {
+			continue
+		}
+
+		text, end := expandText(s.text, column)
+		if end > width {
+			text = truncate(text, width-column)
+			end = width
+		}
+		column = end
+
+		sb.WriteString(styled(text, s.style))
+	}
+
+	return sb.String()
+}
+
+// truncate keeps at most the given number of characters.
+func truncate(text string, characters int) string {
+	if characters <= 0 {
+		return ""
+	}
+
+	count := 0
+	for idx := range text {
+		if count == characters {
+			return text[:idx]
+		}
+		count++
+	}
+
+	return text
+}
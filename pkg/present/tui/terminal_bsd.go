@@ -0,0 +1,12 @@
+//go:build darwin || freebsd
+
+package tui
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ioctlGetTermios = unix.TIOCGETA
+	ioctlSetTermios = unix.TIOCSETA
+)
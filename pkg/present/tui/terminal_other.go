@@ -0,0 +1,26 @@
+//go:build !(linux || darwin || freebsd)
+
+package tui
+
+import (
+	"errors"
+	"os"
+)
+
+var errUnsupported = errors.New("interactive mode is not supported on this platform")
+
+type terminalState struct{}
+
+func makeRaw(_ *os.File) (*terminalState, error) {
+	return nil, errUnsupported
+}
+
+func restore(_ *os.File, _ *terminalState) error {
+	return errUnsupported
+}
+
+func size(_ *os.File) (int, int, error) {
+	return 0, 0, errUnsupported
+}
+
+func notifyResize(_ chan<- os.Signal) {}
@@ -0,0 +1,59 @@
+//go:build linux || darwin || freebsd
+
+package tui
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// terminalState is how the terminal was set up before the interface took it over.
+type terminalState struct {
+	termios unix.Termios
+}
+
+// makeRaw turns off line buffering, echoing, and signals for keys like Ctrl-C, so every key is read as it is pressed.
+func makeRaw(f *os.File) (*terminalState, error) {
+	fd := int(f.Fd())
+
+	termios, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return nil, err
+	}
+	state := &terminalState{termios: *termios}
+
+	raw := *termios
+	raw.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	raw.Oflag &^= unix.OPOST
+	raw.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cflag &^= unix.CSIZE | unix.PARENB
+	raw.Cflag |= unix.CS8
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+
+	if err := unix.IoctlSetTermios(fd, ioctlSetTermios, &raw); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+func restore(f *os.File, state *terminalState) error {
+	return unix.IoctlSetTermios(int(f.Fd()), ioctlSetTermios, &state.termios)
+}
+
+func size(f *os.File) (int, int, error) {
+	ws, err := unix.IoctlGetWinsize(int(f.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int(ws.Col), int(ws.Row), nil
+}
+
+func notifyResize(resized chan<- os.Signal) {
+	signal.Notify(resized, syscall.SIGWINCH)
+}
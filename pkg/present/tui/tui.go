@@ -0,0 +1,219 @@
+package tui
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/agrski/greg/pkg/match"
+	"github.com/agrski/greg/pkg/present"
+	"github.com/agrski/greg/pkg/types"
+)
+
+const (
+	defaultWidth  = 80
+	defaultHeight = 24
+	readSize      = 256
+)
+
+type Config struct {
+	Source present.Source
+	// RedactSecrets hides most of the text of matches found by secret rules.
+	RedactSecrets bool
+}
+
+// TUI is an interactive view of results in the terminal, which are shown as they arrive.
+// It takes over the terminal from Start until Close, which waits for the user to quit.
+type TUI struct {
+	in    *os.File
+	out   *os.File
+	state *terminalState
+
+	// mu guards the model and drawing to the terminal.
+	mu    sync.Mutex
+	model *model
+
+	redraw   chan struct{}
+	resized  chan os.Signal
+	quit     chan struct{}
+	quitOnce sync.Once
+}
+
+var _ present.Presenter = (*TUI)(nil)
+
+func New(in *os.File, out *os.File, config Config) *TUI {
+	return &TUI{
+		in:      in,
+		out:     out,
+		model:   newModel(config.Source, config.RedactSecrets),
+		redraw:  make(chan struct{}, 1),
+		resized: make(chan os.Signal, 1),
+		quit:    make(chan struct{}),
+	}
+}
+
+// Start takes over the terminal, then reads keys and draws the interface in the background.
+func (t *TUI) Start() error {
+	state, err := makeRaw(t.in)
+	if err != nil {
+		return err
+	}
+	t.state = state
+
+	if _, err := t.out.WriteString(enterAltScreen + hideCursor + clearScreen); err != nil {
+		_ = restore(t.in, t.state)
+		return err
+	}
+
+	notifyResize(t.resized)
+	go t.readKeys()
+	go t.drawLoop()
+	t.requestRedraw()
+
+	return nil
+}
+
+// Quit is closed when the user has asked to stop, so no more results are needed.
+func (t *TUI) Quit() <-chan struct{} {
+	return t.quit
+}
+
+func (t *TUI) Write(fileInfo *types.FileInfo, m *match.Match) {
+	t.mu.Lock()
+	t.model.add(fileInfo, m)
+	t.mu.Unlock()
+
+	t.requestRedraw()
+}
+
+// Close marks the search as done, then waits for the user to quit before giving back the terminal.
+func (t *TUI) Close() error {
+	t.mu.Lock()
+	t.model.done = true
+	t.mu.Unlock()
+	t.requestRedraw()
+
+	<-t.quit
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, err := t.out.WriteString(showCursor + leaveAltScreen); err != nil {
+		_ = restore(t.in, t.state)
+		return err
+	}
+
+	return restore(t.in, t.state)
+}
+
+func (t *TUI) stop() {
+	t.quitOnce.Do(func() { close(t.quit) })
+}
+
+// requestRedraw asks for the interface to be drawn, without waiting and merging requests made in quick succession.
+func (t *TUI) requestRedraw() {
+	select {
+	case t.redraw <- struct{}{}:
+	default:
+	}
+}
+
+func (t *TUI) drawLoop() {
+	for {
+		select {
+		case <-t.quit:
+			return
+		case <-t.resized:
+			t.draw()
+		case <-t.redraw:
+			t.draw()
+		}
+	}
+}
+
+func (t *TUI) readKeys() {
+	b := make([]byte, readSize)
+
+	for {
+		n, err := t.in.Read(b)
+		if err != nil {
+			t.stop()
+			return
+		}
+
+		t.handleKeys(parseKeys(b[:n]))
+	}
+}
+
+func (t *TUI) handleKeys(keys []keyPress) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, height := t.size()
+	pageSize := listHeight(height)
+
+	for _, k := range keys {
+		switch t.model.update(k, pageSize) {
+		case actionQuit:
+			t.stop()
+			return
+		case actionOpen:
+			t.open()
+		case actionNone:
+		}
+	}
+
+	t.requestRedraw()
+}
+
+// open shows the selected match on the forge, saying whether that worked in the status line.
+func (t *TUI) open() {
+	url, ok := t.model.selectedPermalink()
+	if !ok {
+		return
+	}
+
+	if err := openURL(url); err != nil {
+		t.model.message = "cannot open " + url + ": " + err.Error()
+		return
+	}
+
+	t.model.message = "opened " + url
+}
+
+func (t *TUI) draw() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	select {
+	case <-t.quit:
+		// The terminal may already be given back
+		return
+	default:
+	}
+
+	width, height := t.size()
+	lines := t.model.view(width, height)
+
+	sb := strings.Builder{}
+	sb.WriteString(moveHome)
+	for idx, line := range lines {
+		if idx > 0 {
+			sb.WriteString("\r\n")
+		}
+		sb.WriteString(line)
+		sb.WriteString(clearLine)
+	}
+
+	_, _ = t.out.WriteString(sb.String())
+}
+
+// size is that of the terminal, or a common default if it cannot be found.
+func (t *TUI) size() (int, int) {
+	width, height, err := size(t.out)
+	if err != nil || width <= 0 || height <= 0 {
+		return defaultWidth, defaultHeight
+	}
+
+	return width, height
+}
@@ -0,0 +1,111 @@
+package vimgrep
+
+import (
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/agrski/greg/pkg/match"
+	"github.com/agrski/greg/pkg/present"
+	"github.com/agrski/greg/pkg/types"
+)
+
+const (
+	fieldSeparator = ':'
+	binaryMessage  = "binary file matches at byte offset "
+)
+
+type Config struct {
+	// LocalRoot is a local checkout of the repository, which paths are given within when set.
+	LocalRoot string
+	// RedactSecrets hides most of the text of matches found by secret rules.
+	RedactSecrets bool
+}
+
+// Vimgrep writes a line of path:line:column:text per match, as understood by Vim's quickfix list
+// and Emacs's grep-mode. Lines and columns count from 1, with columns in characters.
+type Vimgrep struct {
+	localRoot     string
+	redactSecrets bool
+	out           io.StringWriter
+}
+
+var _ present.Presenter = (*Vimgrep)(nil)
+
+func New(out io.StringWriter, config Config) *Vimgrep {
+	return &Vimgrep{
+		localRoot:     config.LocalRoot,
+		redactSecrets: config.RedactSecrets,
+		out:           out,
+	}
+}
+
+func (v *Vimgrep) Close() error {
+	return nil
+}
+
+func (v *Vimgrep) Write(fileInfo *types.FileInfo, m *match.Match) {
+	path := v.localPath(fileInfo.Path)
+	positionsByLine := present.PositionsByLine(m)
+
+	sb := strings.Builder{}
+	for _, p := range m.Positions {
+		line, column, text := v.describe(p, positionsByLine[p.Line])
+
+		sb.WriteString(path)
+		sb.WriteByte(fieldSeparator)
+		sb.WriteString(strconv.FormatUint(uint64(line), 10))
+		sb.WriteByte(fieldSeparator)
+		sb.WriteString(strconv.FormatUint(uint64(column), 10))
+		sb.WriteByte(fieldSeparator)
+		sb.WriteString(text)
+		sb.WriteString("\n")
+	}
+
+	_, _ = v.out.WriteString(sb.String())
+}
+
+// describe gives the line and column of a match, counting from 1, and the text of its first line.
+func (v *Vimgrep) describe(p *match.FilePosition, sameLine []*match.FilePosition) (uint, uint, string) {
+	if p.Binary {
+		return 1, 1, binaryMessage + strconv.FormatUint(uint64(p.ColumnStart), 10)
+	}
+
+	firstLine := p.Text
+	if idx := strings.IndexByte(firstLine, '\n'); idx != -1 {
+		firstLine = firstLine[:idx]
+	}
+	columnStart, _ := p.Span()
+	if int(columnStart) > len(firstLine) {
+		columnStart = uint(len(firstLine))
+	}
+
+	column := p.TextCharacters + uint(utf8.RuneCountInString(firstLine[:columnStart])) + 1
+
+	// Columns count characters in the original text, as redacting earlier secrets on the line moves the rest
+	text := p.Text
+	if v.redactSecrets && p.RuleID != "" {
+		text, _, _ = present.RedactSecrets(p, sameLine)
+	}
+	if idx := strings.IndexByte(text, '\n'); idx != -1 {
+		text = text[:idx]
+	}
+
+	return p.Line + 1, column, text
+}
+
+// localPath gives where a file would be in the local checkout, if any.
+// Files in archives cannot be opened by editors, so the archive itself is given instead.
+func (v *Vimgrep) localPath(path string) string {
+	if idx := strings.Index(path, types.MemberSeparator); idx != -1 {
+		path = path[:idx]
+	}
+
+	if v.localRoot == "" {
+		return path
+	}
+
+	return filepath.Join(v.localRoot, filepath.FromSlash(path))
+}
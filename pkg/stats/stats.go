@@ -0,0 +1,142 @@
+package stats
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats counts what happens over a search, from fetching files to matching them, so the run can be summarised.
+// It is safe for concurrent use, as fetchers work in the background.
+// A nil Stats records nothing, so components built without one need not check for it.
+type Stats struct {
+	started         time.Time
+	filesFetched    atomic.Uint64
+	filesMatched    atomic.Uint64
+	matches         atomic.Uint64
+	skippedBinary   atomic.Uint64
+	filteredByType  atomic.Uint64
+	filteredByPath  atomic.Uint64
+	bytesDownloaded atomic.Uint64
+	apiRequests     atomic.Uint64
+	apiCost         atomic.Uint64
+
+	mu       sync.Mutex
+	failures []Failure
+}
+
+// Failure is a path which could not be fetched or read, such as a directory whose listing failed.
+type Failure struct {
+	Path string
+	Err  error
+}
+
+// Summary is a snapshot of the counts of a search.
+type Summary struct {
+	FilesFetched uint64
+	FilesMatched uint64
+	Matches      uint64
+	// SkippedBinary counts binary files which were not searched.
+	SkippedBinary  uint64
+	FilteredByType uint64
+	// FilteredByPath counts files and directories excluded by path filters, before or after fetching.
+	FilteredByPath  uint64
+	Failures        []Failure
+	BytesDownloaded uint64
+	APIRequests     uint64
+	// APICost is the number of rate-limit points spent on queries which report their cost.
+	APICost uint64
+	Started time.Time
+	Elapsed time.Duration
+}
+
+func New() *Stats {
+	return &Stats{started: time.Now()}
+}
+
+func (s *Stats) RecordFetched() {
+	if s != nil {
+		s.filesFetched.Add(1)
+	}
+}
+
+func (s *Stats) RecordMatched(matches int) {
+	if s != nil {
+		s.filesMatched.Add(1)
+		s.matches.Add(uint64(matches))
+	}
+}
+
+func (s *Stats) RecordSkippedBinary() {
+	if s != nil {
+		s.skippedBinary.Add(1)
+	}
+}
+
+func (s *Stats) RecordFilteredByType() {
+	if s != nil {
+		s.filteredByType.Add(1)
+	}
+}
+
+func (s *Stats) RecordFilteredByPath() {
+	if s != nil {
+		s.filteredByPath.Add(1)
+	}
+}
+
+func (s *Stats) RecordFailure(path string, err error) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = append(s.failures, Failure{Path: path, Err: err})
+}
+
+func (s *Stats) RecordDownloaded(bytes int) {
+	if s != nil && bytes > 0 {
+		s.bytesDownloaded.Add(uint64(bytes))
+	}
+}
+
+func (s *Stats) RecordRequest() {
+	if s != nil {
+		s.apiRequests.Add(1)
+	}
+}
+
+func (s *Stats) RecordCost(points int) {
+	if s != nil && points > 0 {
+		s.apiCost.Add(uint64(points))
+	}
+}
+
+// Summary gives the counts so far, timing the search from when the stats were created.
+func (s *Stats) Summary() Summary {
+	if s == nil {
+		return Summary{}
+	}
+
+	s.mu.Lock()
+	failures := make([]Failure, len(s.failures))
+	copy(failures, s.failures)
+	s.mu.Unlock()
+
+	return Summary{
+		FilesFetched:    s.filesFetched.Load(),
+		FilesMatched:    s.filesMatched.Load(),
+		Matches:         s.matches.Load(),
+		SkippedBinary:   s.skippedBinary.Load(),
+		FilteredByType:  s.filteredByType.Load(),
+		FilteredByPath:  s.filteredByPath.Load(),
+		Failures:        failures,
+		BytesDownloaded: s.bytesDownloaded.Load(),
+		APIRequests:     s.apiRequests.Load(),
+		APICost:         s.apiCost.Load(),
+		Started:         s.started,
+		Elapsed:         time.Since(s.started),
+	}
+}
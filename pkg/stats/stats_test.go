@@ -0,0 +1,48 @@
+package stats
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummary(t *testing.T) {
+	s := New()
+
+	s.RecordFetched()
+	s.RecordFetched()
+	s.RecordMatched(3)
+	s.RecordSkippedBinary()
+	s.RecordFilteredByType()
+	s.RecordFilteredByPath()
+	s.RecordFailure("pkg", errors.New("timeout"))
+	s.RecordDownloaded(100)
+	s.RecordDownloaded(0)
+	s.RecordRequest()
+	s.RecordCost(1)
+	s.RecordCost(0)
+
+	summary := s.Summary()
+	require.Equal(t, uint64(2), summary.FilesFetched)
+	require.Equal(t, uint64(1), summary.FilesMatched)
+	require.Equal(t, uint64(3), summary.Matches)
+	require.Equal(t, uint64(1), summary.SkippedBinary)
+	require.Equal(t, uint64(1), summary.FilteredByType)
+	require.Equal(t, uint64(1), summary.FilteredByPath)
+	require.Equal(t, []Failure{{Path: "pkg", Err: errors.New("timeout")}}, summary.Failures)
+	require.Equal(t, uint64(100), summary.BytesDownloaded)
+	require.Equal(t, uint64(1), summary.APIRequests)
+	require.Equal(t, uint64(1), summary.APICost)
+	require.False(t, summary.Started.IsZero())
+}
+
+func TestNilRecordsNothing(t *testing.T) {
+	var s *Stats
+
+	s.RecordFetched()
+	s.RecordMatched(1)
+	s.RecordFailure("pkg", errors.New("timeout"))
+
+	require.Equal(t, Summary{}, s.Summary())
+}
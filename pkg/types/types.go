@@ -1,5 +1,13 @@
 package types
 
+import (
+	"io"
+	"strings"
+)
+
+// MemberSeparator joins the path of an archive to the path of a file within it, as in dist/src.zip!main.go.
+const MemberSeparator = "!"
+
 type FileExtension string
 
 type FileInfo struct {
@@ -7,4 +15,37 @@ type FileInfo struct {
 	Extension FileExtension
 	IsBinary  bool
 	Text      string
+	// Body streams the file's contents in place of Text when set.
+	// It can only be read once, so anything needing the contents more than once should use Contents.
+	Body io.Reader
+	// Encoding names the character encoding the file was transcoded from, such as utf-16le,
+	// or is empty when it was already taken to be UTF-8.
+	// UTF-8 with a byte order mark gives utf-8, as the mark is removed.
+	Encoding string
+}
+
+// Reader gives the file's contents, from Body if set or else from Text.
+func (f *FileInfo) Reader() io.Reader {
+	if f.Body != nil {
+		return f.Body
+	}
+
+	return strings.NewReader(f.Text)
+}
+
+// Contents reads any Body into Text, so the whole file is held in memory, and returns it.
+func (f *FileInfo) Contents() (string, error) {
+	if f.Body == nil {
+		return f.Text, nil
+	}
+
+	b, err := io.ReadAll(f.Body)
+	f.Body = nil
+	if err != nil {
+		return "", err
+	}
+
+	f.Text = string(b)
+
+	return f.Text, nil
 }